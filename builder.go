@@ -0,0 +1,77 @@
+package jsonmsg
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// A SpecBuilder constructs a spec programmatically, e.g. for tests or dynamic APIs.
+// The built spec is parsed from the equivalent JSON document, so it is resolved
+// like a parsed spec and its Raw document round-trips through Parse.
+type SpecBuilder struct {
+	endpoints   map[string]string
+	messages    map[string]interface{}
+	definitions map[string]json.RawMessage
+	err         error
+}
+
+// Returns an empty SpecBuilder
+func NewSpec() *SpecBuilder {
+	return &SpecBuilder{
+		endpoints:   make(map[string]string),
+		messages:    make(map[string]interface{}),
+		definitions: make(map[string]json.RawMessage),
+	}
+}
+
+// Adds an endpoint URL of a protocol, e.g. "http" and "https://api.example.com/v1"
+func (b *SpecBuilder) AddEndpoint(protocol string, url string) *SpecBuilder {
+	b.endpoints[protocol] = url
+	return b
+}
+
+// Adds a definition named name, referenced as #/definitions/{name}.
+// The schema is any value marshaling to a JSON Schema, e.g. a json.RawMessage or a map.
+func (b *SpecBuilder) AddDefinition(name string, schema interface{}) *SpecBuilder {
+	raw, err := json.Marshal(schema)
+	if err != nil && b.err == nil {
+		b.err = fmt.Errorf("jsonmsg: definition %q: %v", name, err)
+	}
+	b.definitions[name] = raw
+	return b
+}
+
+// Adds a message with JSON Pointers to its in and outs, e.g. "#/definitions/credentials".
+// An empty in or group is left out.
+func (b *SpecBuilder) AddMessage(name string, in string, outs []string, group string) *SpecBuilder {
+	m := make(map[string]interface{})
+	if in != "" {
+		m["in"] = in
+	}
+	if len(outs) > 0 {
+		m["outs"] = outs
+	}
+	if group != "" {
+		m["group"] = group
+	}
+	b.messages[name] = m
+	return b
+}
+
+// Builds the spec, resolving all pointers like Parse
+func (b *SpecBuilder) Build() (*Spec, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	doc := map[string]interface{}{
+		"endpoints":   b.endpoints,
+		"messages":    b.messages,
+		"definitions": b.definitions,
+	}
+	raw, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return Parse(raw)
+}
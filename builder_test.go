@@ -0,0 +1,67 @@
+package jsonmsg
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tfkhsr/jsonmsg/fixture"
+)
+
+func TestSpecBuilder(t *testing.T) {
+	object := func(props ...string) map[string]interface{} {
+		properties := make(map[string]interface{})
+		for _, p := range props {
+			properties[p] = map[string]string{"type": "string"}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	spec, err := NewSpec().
+		AddEndpoint("http", "http://api.specc.io/v1").
+		AddDefinition("credentials", object("name", "password")).
+		AddDefinition("session", object("id")).
+		AddDefinition("error", json.RawMessage(`{"type": "object", "properties": {"error": {"type": "string"}}}`)).
+		AddDefinition("message", object("message")).
+		AddMessage("loginWithCredentials", "#/definitions/credentials", []string{"#/definitions/session", "#/definitions/error"}, "login").
+		AddMessage("logout", "#/definitions/session", []string{"#/definitions/message"}, "logout").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := spec.Messages["loginWithCredentials"]
+	if login.InSchema != spec.Definitions["#/definitions/credentials"] {
+		t.Fatalf("in schema was %v", login.InSchema)
+	}
+	if len(login.OutSchemas) != 2 || login.OutSchemas[1].Name != "Error" {
+		t.Fatalf("out schemas were %v", login.OutSchemas)
+	}
+	if spec.GroupedMessages["logout"]["logout"] != spec.Messages["logout"] {
+		t.Fatalf("grouped messages were %v", spec.GroupedMessages)
+	}
+
+	// the built spec serves the same JSON spec as the parsed one
+	parsed, err := Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	built, err := spec.JSONSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := parsed.JSONSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(built) != string(expected) {
+		t.Fatalf("JSON spec was\n%s\nexpected\n%s", built, expected)
+	}
+
+	// invalid pointers fail like parsing
+	_, err = NewSpec().
+		AddEndpoint("http", "http://api.specc.io/v1").
+		AddMessage("logout", "#/definitions/session", nil, "").
+		Build()
+	if err == nil {
+		t.Fatal("message with unknown in should not build")
+	}
+}
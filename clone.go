@@ -0,0 +1,190 @@
+package jsonmsg
+
+import (
+	"encoding/json"
+
+	"github.com/tfkhsr/jsonschema"
+)
+
+// Returns a deep copy of the spec, which can be mutated without affecting s.
+// Messages of the copy reference the copy and the copied definitions.
+func (s *Spec) Clone() *Spec {
+	c := *s
+	schemas := make(map[*jsonschema.Schema]*jsonschema.Schema)
+
+	// endpoints
+	if s.Endpoints != nil {
+		c.Endpoints = make(map[string]*urlString, len(s.Endpoints))
+		for k, e := range s.Endpoints {
+			if e == nil {
+				c.Endpoints[k] = nil
+				continue
+			}
+			u := *e
+			c.Endpoints[k] = &u
+		}
+	}
+
+	// definitions
+	if s.Definitions != nil {
+		c.Definitions = make(jsonschema.Index, len(s.Definitions))
+		for k, d := range s.Definitions {
+			c.Definitions[k] = cloneSchema(d, schemas)
+		}
+	}
+	c.ErrorDefinition = cloneSchema(s.ErrorDefinition, schemas)
+
+	// messages
+	if s.Messages != nil {
+		c.Messages = make(map[string]*Message, len(s.Messages))
+		for k, m := range s.Messages {
+			if m == nil {
+				c.Messages[k] = nil
+				continue
+			}
+			cm := *m
+			cm.Outs = append([]string(nil), m.Outs...)
+			cm.OutDescriptions = append([]string(nil), m.OutDescriptions...)
+			cm.Examples = cloneRawMessages(m.Examples)
+			if m.Headers != nil {
+				cm.Headers = make(map[string]string, len(m.Headers))
+				for h, v := range m.Headers {
+					cm.Headers[h] = v
+				}
+			}
+			cm.InSchema = cloneSchema(m.InSchema, schemas)
+			cm.Ins = append([]string(nil), m.Ins...)
+			if m.InSchemas != nil {
+				cm.InSchemas = make([]*jsonschema.Schema, len(m.InSchemas))
+				for i, in := range m.InSchemas {
+					cm.InSchemas[i] = cloneSchema(in, schemas)
+				}
+			}
+			if m.OutSchemas != nil {
+				cm.OutSchemas = make([]*jsonschema.Schema, len(m.OutSchemas))
+				for i, o := range m.OutSchemas {
+					cm.OutSchemas[i] = cloneSchema(o, schemas)
+				}
+			}
+			cm.Spec = &c
+			c.Messages[k] = &cm
+		}
+	}
+	if s.GroupedMessages != nil {
+		c.GroupedMessages = make(map[string]map[string]*Message, len(s.GroupedMessages))
+		for g, msgs := range s.GroupedMessages {
+			c.GroupedMessages[g] = make(map[string]*Message, len(msgs))
+			for k, m := range msgs {
+				if cm, ok := c.Messages[k]; ok && s.Messages[k] == m {
+					c.GroupedMessages[g][k] = cm
+					continue
+				}
+				c.GroupedMessages[g][k] = m
+			}
+		}
+	}
+
+	if s.Groups != nil {
+		c.Groups = append([]string(nil), s.Groups...)
+	}
+
+	// raw spec and annotations
+	c.Raw = append([]byte(nil), s.Raw...)
+	c.Nullable = cloneFlags(s.Nullable)
+	c.ReadOnly = cloneFlags(s.ReadOnly)
+	c.WriteOnly = cloneFlags(s.WriteOnly)
+	c.Deprecated = cloneFlags(s.Deprecated)
+	if s.GoNames != nil {
+		c.GoNames = make(map[string]string, len(s.GoNames))
+		for k, v := range s.GoNames {
+			c.GoNames[k] = v
+		}
+	}
+	if s.Tuples != nil {
+		c.Tuples = make(map[string]*Tuple, len(s.Tuples))
+		for k, t := range s.Tuples {
+			ct := *t
+			ct.Items = append([]string(nil), t.Items...)
+			c.Tuples[k] = &ct
+		}
+	}
+	if s.EnumValues != nil {
+		c.EnumValues = make(map[string][]EnumValue, len(s.EnumValues))
+		for k, vs := range s.EnumValues {
+			c.EnumValues[k] = append([]EnumValue(nil), vs...)
+		}
+	}
+	if s.Defaults != nil {
+		c.Defaults = make(map[string]json.RawMessage, len(s.Defaults))
+		for k, d := range s.Defaults {
+			c.Defaults[k] = append(json.RawMessage(nil), d...)
+		}
+	}
+	if s.Examples != nil {
+		c.Examples = make(map[string][]json.RawMessage, len(s.Examples))
+		for k, e := range s.Examples {
+			c.Examples[k] = cloneRawMessages(e)
+		}
+	}
+	if s.aliases != nil {
+		c.aliases = make(map[string]string, len(s.aliases))
+		for k, v := range s.aliases {
+			c.aliases[k] = v
+		}
+	}
+
+	return &c
+}
+
+// copies a schema and all schemas it contains, schemas already copied are reused,
+// so schemas shared within the spec stay shared within the copy
+func cloneSchema(s *jsonschema.Schema, schemas map[*jsonschema.Schema]*jsonschema.Schema) *jsonschema.Schema {
+	if s == nil {
+		return nil
+	}
+	if c, ok := schemas[s]; ok {
+		return c
+	}
+	c := *s
+	schemas[s] = &c
+
+	c.Required = append([]string(nil), s.Required...)
+	c.Items = cloneSchema(s.Items, schemas)
+	if s.Properties != nil {
+		c.Properties = make(map[string]*jsonschema.Schema, len(s.Properties))
+		for k, p := range s.Properties {
+			c.Properties[k] = cloneSchema(p, schemas)
+		}
+	}
+	if s.Definitions != nil {
+		c.Definitions = make(map[string]*jsonschema.Schema, len(s.Definitions))
+		for k, d := range s.Definitions {
+			c.Definitions[k] = cloneSchema(d, schemas)
+		}
+	}
+	return &c
+}
+
+// copies raw messages including their bytes
+func cloneRawMessages(rs []json.RawMessage) []json.RawMessage {
+	if rs == nil {
+		return nil
+	}
+	c := make([]json.RawMessage, len(rs))
+	for i, r := range rs {
+		c[i] = append(json.RawMessage(nil), r...)
+	}
+	return c
+}
+
+// copies a set of pointers
+func cloneFlags(m map[string]bool) map[string]bool {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]bool, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,62 @@
+package jsonmsg
+
+import (
+	"testing"
+
+	"github.com/tfkhsr/jsonmsg/fixture"
+)
+
+func TestSpecClone(t *testing.T) {
+	spec, err := Parse([]byte(fixture.TestSchemaSimpleLoginHTTPandWebsocket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := spec.Clone()
+
+	// back-references point into the clone
+	for k, m := range c.Messages {
+		if m == spec.Messages[k] {
+			t.Fatalf("message %v is shared", k)
+		}
+		if m.Spec != c {
+			t.Fatalf("message %v references the original spec", k)
+		}
+		if m.InSchema != nil && m.InSchema != c.Definitions[c.resolveAlias(m.In)] {
+			t.Fatalf("in schema of message %v is not a definition of the clone", k)
+		}
+		if c.GroupedMessages[m.Group][k] != m {
+			t.Fatalf("grouped message %v is not the cloned message", k)
+		}
+	}
+
+	// mutating the clone leaves the original unchanged
+	m := c.Messages["loginWithCredentials"]
+	m.Title = "changed"
+	m.Outs[0] = "#/definitions/changed"
+	m.InSchema.Properties["name"].Type = "integer"
+	c.Definitions["#/definitions/session"].Name = "Changed"
+	c.Endpoints["http"].Path = "/changed"
+	delete(c.Messages, "logout")
+
+	o := spec.Messages["loginWithCredentials"]
+	if o.Title == "changed" || o.Outs[0] == "#/definitions/changed" {
+		t.Fatalf("original message was changed: %v", o)
+	}
+	if typ := o.InSchema.Properties["name"].Type; typ != "string" {
+		t.Fatalf("original property type was changed to %v", typ)
+	}
+	if name := spec.Definitions["#/definitions/session"].Name; name != "Session" {
+		t.Fatalf("original definition name was changed to %v", name)
+	}
+	if spec.Endpoints["http"].Path == "/changed" {
+		t.Fatal("original endpoint was changed")
+	}
+	if _, ok := spec.Messages["logout"]; !ok {
+		t.Fatal("original message was deleted")
+	}
+
+	// shared schemas stay shared in the clone
+	if c.Definitions["#/definitions/credentials/properties/name"] != c.Definitions["#/definitions/credentials"].Properties["name"] {
+		t.Fatal("property is not shared with the index")
+	}
+}
@@ -3,39 +3,77 @@ package main
 import (
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"strings"
 
 	"github.com/tfkhsr/jsonmsg"
 	"github.com/tfkhsr/jsonmsg/golang"
+	_ "github.com/tfkhsr/jsonmsg/python"
 )
 
 func main() {
 	file := flag.String("file", "spec.json", "spec schema file to load")
 	pack := flag.String("package", "main", "name for generated package")
-	gen := flag.String("generator", "go-server", "generator to use")
+	gen := flag.String("generator", "go-server", "generator to use: "+strings.Join(jsonmsg.Generators(), ", "))
+	strict := flag.Bool("strict", false, "reject messages with neither in nor outs unless marked \"empty\": true")
+	strictKeys := flag.Bool("strict-keys", false, "reject unknown top-level keys of the spec")
+	sessions := flag.Bool("sessions", false, "go-server: pass a per-connection *Conn to API methods")
+	withContext := flag.Bool("context", false, "go-server: pass a context.Context cancelled on request timeout to API methods")
+	specJSONPath := flag.String("spec-json-path", "", "go-server: path of the JSON spec route, \"-\" disables it")
+	specHTMLPath := flag.String("spec-html-path", "", "go-server: path of the HTML spec route, \"-\" disables it")
+	noEmbeddedSpec := flag.Bool("no-embedded-spec", false, "go-server: do not embed the spec, disables spec routes and fetchSpec")
+	stripComments := flag.Bool("strip-comments", false, "go-server: strip \"$comment\" annotations from the embedded spec")
+	streamArrays := flag.Bool("stream-arrays", false, "go-server: stream array outs over HTTP with chunked encoding")
+	versions := flag.String("versions", "", "go-server: comma separated versions to serve all routes under, e.g. v1,v2")
+	client := flag.Bool("client", false, "go-server: generate a Client next to the server")
+	health := flag.Bool("health", false, "go-server: serve a health check route at {base path}/health")
+	metrics := flag.Bool("metrics", false, "go-server: serve message counts and latencies at {base path}/metrics")
+	jsonrpc := flag.Bool("jsonrpc", false, "go-server: serve JSON-RPC 2.0 requests at {base path}/jsonrpc")
+	fastJSON := flag.Bool("fast-json", false, "go-server: generate MarshalJSON methods of the types encoding without reflection")
+	validateFormats := flag.Bool("validate-formats", false, "go-server: validate the string formats email, uri, uuid and ipv4 of inputs")
+	outsOnErrorStatus := flag.Int("outs-on-error-status", 0, "go-server: status code answering outs returned along with an error, 0 answers status 500")
+	allValidationErrors := flag.Bool("all-validation-errors", false, "go-server: report all failed validations of an input at once")
+	noFormat := flag.Bool("no-format", false, "go-server: do not format the generated sources")
 	flag.Parse()
 
-	// read spec
-	buf, err := ioutil.ReadFile(*file)
+	// parse spec, resolving refs to other files relative to it
+	spec, err := jsonmsg.ParseFileWithOptions(*file, jsonmsg.ParseOptions{Strict: *strict, DisallowUnknownKeys: *strictKeys})
 	if err != nil {
 		panic(err)
 	}
 
-	// parse spec
-	spec, err := jsonmsg.Parse(buf)
-	if err != nil {
-		panic(err)
+	// go-server and go-server-tests with options from flags
+	o := golang.Options{
+		Sessions:            *sessions,
+		Context:             *withContext,
+		SpecJSONPath:        *specJSONPath,
+		SpecHTMLPath:        *specHTMLPath,
+		NoEmbeddedSpec:      *noEmbeddedSpec,
+		StripComments:       *stripComments,
+		StreamArrays:        *streamArrays,
+		Health:              *health,
+		Metrics:             *metrics,
+		JSONRPC:             *jsonrpc,
+		Client:              *client,
+		FastJSON:            *fastJSON,
+		ValidateFormats:     *validateFormats,
+		AllValidationErrors: *allValidationErrors,
+		OutsOnErrorStatus:   *outsOnErrorStatus,
 	}
-
-	// generate src
-	var src []byte
-	switch *gen {
-	case "go-server":
-		src, err = golang.ServerPackageSrc(spec, *pack)
-	default:
-		err = fmt.Errorf("unknown generator: %s", *gen)
+	if *versions != "" {
+		o.Versions = strings.Split(*versions, ",")
+	}
+	if *noFormat {
+		o.Formatter = golang.NoFormat
 	}
+	jsonmsg.RegisterGenerator("go-server", func(s *jsonmsg.Spec, pack string) ([]byte, error) {
+		return golang.ServerPackageSrcWithOptions(s, pack, o)
+	})
+	jsonmsg.RegisterGenerator("go-server-tests", func(s *jsonmsg.Spec, pack string) ([]byte, error) {
+		return golang.ServerTestPackageSrcWithOptions(s, pack, o)
+	})
 
+	// generate src
+	src, err := jsonmsg.Generate(*gen, spec, *pack)
 	if err != nil {
 		panic(err)
 	}
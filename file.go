@@ -0,0 +1,346 @@
+package jsonmsg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Parses a spec from a file. $refs to definitions of other files, e.g. common.json#/definitions/error,
+// are resolved relative to the referencing file and inlined into the spec's definitions.
+// A spec extending a base spec, e.g. "extends": "base.json", is merged with it, see extendSpec.
+func ParseFile(path string) (*Spec, error) {
+	return ParseFileWithOptions(path, ParseOptions{})
+}
+
+// Parses a spec from a file with ParseOptions, resolving $refs to other files like ParseFile
+func ParseFileWithOptions(path string, o ParseOptions) (*Spec, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err = extendSpec(b, abs, map[string]bool{abs: true})
+	if err != nil {
+		return nil, err
+	}
+	b, err = inlineExternalRefs(b, abs)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseWithOptions(b, o)
+}
+
+// Returns the spec at path merged with the base spec it extends, resolved relative to path unless absolute,
+// or the unchanged spec if it extends none. Endpoints, definitions and messages of the base are merged
+// under those of the spec, which overrides base endpoints and definitions of the same name
+// but must not redeclare base messages. Other keys of the base are used if the spec lacks them.
+// Files already extended are given in seen to detect cycles.
+func extendSpec(b []byte, path string, seen map[string]bool) ([]byte, error) {
+	doc, err := decodeJSONObject(b)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := doc["extends"]
+	if !ok {
+		return b, nil
+	}
+	ext, ok := v.(string)
+	if !ok || ext == "" {
+		return nil, fmt.Errorf("jsonmsg: extends of %v must be the path of a base spec", path)
+	}
+	delete(doc, "extends")
+
+	base := relativePath(path, ext)
+	if seen[base] {
+		return nil, fmt.Errorf("jsonmsg: extends %v: %v is extended cyclically", ext, base)
+	}
+	seen[base] = true
+	bb, err := ioutil.ReadFile(base)
+	if err != nil {
+		return nil, fmt.Errorf("jsonmsg: extends %v: %v", ext, err)
+	}
+	bb, err = extendSpec(bb, base, seen)
+	if err != nil {
+		return nil, err
+	}
+	// refs of the base are relative to its own file
+	bb, err = inlineExternalRefs(bb, base)
+	if err != nil {
+		return nil, err
+	}
+	baseDoc, err := decodeJSONObject(bb)
+	if err != nil {
+		return nil, fmt.Errorf("jsonmsg: extends %v: %v", ext, err)
+	}
+
+	// base messages must not be redeclared
+	msgs, _ := doc["messages"].(map[string]interface{})
+	baseMsgs, _ := baseDoc["messages"].(map[string]interface{})
+	var conflicts []string
+	for k, _ := range msgs {
+		if _, ok := baseMsgs[k]; ok {
+			conflicts = append(conflicts, k)
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("jsonmsg: extends %v: messages %v are already declared by the base spec", ext, strings.Join(conflicts, ", "))
+	}
+
+	for k, bv := range baseDoc {
+		cv, ok := doc[k]
+		if !ok {
+			doc[k] = bv
+			continue
+		}
+		switch k {
+		case "definitions", "messages":
+			doc[k] = mergeObjects(bv, cv)
+		case "endpoints":
+			// endpoints given as host with protocols are replaced as a whole
+			if !hasKey(bv, "host") && !hasKey(cv, "host") {
+				doc[k] = mergeObjects(bv, cv)
+			}
+		}
+	}
+	return json.Marshal(doc)
+}
+
+// Returns the keys of the objects base and v, whose values override those of base.
+// v is returned if either is no object.
+func mergeObjects(base, v interface{}) interface{} {
+	bm, ok := base.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	merged := make(map[string]interface{}, len(bm)+len(m))
+	for k, e := range bm {
+		merged[k] = e
+	}
+	for k, e := range m {
+		merged[k] = e
+	}
+	return merged
+}
+
+// reports whether v is an object with key k
+func hasKey(v interface{}, k string) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = m[k]
+	return ok
+}
+
+// inlines definitions referenced from other files
+type refInliner struct {
+	// absolute path of the spec
+	spec string
+
+	// definitions of the spec, extended by inlined definitions
+	defs map[string]interface{}
+
+	// parsed files by absolute path
+	files map[string]map[string]interface{}
+
+	// file#pointer of inlined definitions by definition name
+	inlined map[string]string
+}
+
+// Returns the spec with all definitions referenced from other files inlined
+// or the unchanged spec if it has no external refs
+func inlineExternalRefs(b []byte, path string) ([]byte, error) {
+	doc, err := decodeJSONObject(b)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &refInliner{
+		spec:    path,
+		defs:    make(map[string]interface{}),
+		files:   map[string]map[string]interface{}{path: doc},
+		inlined: make(map[string]string),
+	}
+	if defs, ok := doc["definitions"].(map[string]interface{}); ok {
+		r.defs = defs
+	}
+
+	// error schema and message pointers
+	if e, ok := doc["errorSchema"].(string); ok {
+		doc["errorSchema"], err = r.resolve(path, e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if msgs, ok := doc["messages"].(map[string]interface{}); ok {
+		for _, v := range msgs {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if in, ok := m["in"].(string); ok {
+				m["in"], err = r.resolve(path, in)
+				if err != nil {
+					return nil, err
+				}
+			}
+			ins, _ := m["in"].([]interface{})
+			for i, o := range ins {
+				if in, ok := o.(string); ok {
+					ins[i], err = r.resolve(path, in)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+			outs, _ := m["outs"].([]interface{})
+			for i, o := range outs {
+				if out, ok := o.(string); ok {
+					outs[i], err = r.resolve(path, out)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	// $refs anywhere else, including inline message schemas and annotated outs
+	err = r.walk(path, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.inlined) == 0 {
+		return b, nil
+	}
+	doc["definitions"] = r.defs
+	return json.Marshal(doc)
+}
+
+// rewrites all $refs within v, which is part of the file at path
+func (r *refInliner) walk(path string, v interface{}) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if ref, ok := e.(string); ok && k == "$ref" {
+				resolved, err := r.resolve(path, ref)
+				if err != nil {
+					return err
+				}
+				t[k] = resolved
+				continue
+			}
+			err := r.walk(path, e)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range t {
+			err := r.walk(path, e)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the spec-local pointer of a pointer within the file at path,
+// inlining the referenced definition if it belongs to another file
+func (r *refInliner) resolve(path, ptr string) (string, error) {
+	i := strings.Index(ptr, "#")
+	if i < 0 {
+		// not a pointer, left to the spec validation
+		return ptr, nil
+	}
+	file, frag := ptr[:i], ptr[i:]
+	target := path
+	if file != "" {
+		target = relativePath(path, file)
+	}
+	if target == r.spec {
+		// pointer into the spec itself
+		return frag, nil
+	}
+
+	name := strings.TrimPrefix(frag, "#/definitions/")
+	if name == frag || name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("jsonmsg: $ref %v: only definitions of other files can be referenced", ptr)
+	}
+	key := target + "#/definitions/" + name
+	if k, ok := r.inlined[name]; ok {
+		if k != key {
+			return "", fmt.Errorf("jsonmsg: $ref %v: definition %q is already inlined from %v", ptr, name, k)
+		}
+		return "#/definitions/" + name, nil
+	}
+	if _, ok := r.defs[name]; ok {
+		return "", fmt.Errorf("jsonmsg: $ref %v: definition %q collides with a definition of the spec", ptr, name)
+	}
+
+	doc, ok := r.files[target]
+	if !ok {
+		b, err := ioutil.ReadFile(target)
+		if err != nil {
+			return "", fmt.Errorf("jsonmsg: $ref %v: %v", ptr, err)
+		}
+		doc, err = decodeJSONObject(b)
+		if err != nil {
+			return "", fmt.Errorf("jsonmsg: $ref %v: %v", ptr, err)
+		}
+		r.files[target] = doc
+	}
+	defs, _ := doc["definitions"].(map[string]interface{})
+	def, ok := defs[name]
+	if !ok {
+		return "", fmt.Errorf("jsonmsg: $ref %v: definition does not exist in %v", ptr, target)
+	}
+
+	// mark as inlined before walking, so cyclic refs resolve to the inlined definition
+	r.inlined[name] = key
+	r.defs[name] = def
+	err := r.walk(target, def)
+	if err != nil {
+		return "", err
+	}
+
+	return "#/definitions/" + name, nil
+}
+
+// Returns the path of file referenced from the file at path,
+// relative paths are resolved against the directory of path, absolute paths are kept
+func relativePath(path, file string) string {
+	if filepath.IsAbs(file) {
+		return filepath.Clean(file)
+	}
+	return filepath.Join(filepath.Dir(path), file)
+}
+
+// decodes a JSON object, keeping numbers as written and ignoring a leading byte order mark
+func decodeJSONObject(b []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(cleanSpec(b)))
+	dec.UseNumber()
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
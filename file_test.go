@@ -0,0 +1,265 @@
+package jsonmsg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tfkhsr/jsonmsg/fixture"
+)
+
+func TestParseFileExternalRefs(t *testing.T) {
+	dir := writeSpecFiles(t, map[string]string{
+		"spec.json":   fixture.TestSchemaExternalRefs,
+		"common.json": fixture.TestSchemaExternalRefsCommon,
+	})
+	defer os.RemoveAll(dir)
+
+	spec, err := ParseFile(filepath.Join(dir, "spec.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// inlined definitions, including internal and cyclic refs of common.json
+	for _, k := range []string{"#/definitions/error", "#/definitions/errorDetails", "#/definitions/address"} {
+		if _, ok := spec.Definitions[k]; !ok {
+			t.Fatalf("%v should be inlined", k)
+		}
+	}
+	if ref := spec.Definitions["#/definitions/errorDetails/properties/cause"].Ref; ref != "#/definitions/error" {
+		t.Fatalf("cyclic ref was rewritten to %q", ref)
+	}
+	if ref := spec.Definitions["#/definitions/user/properties/address"].Ref; ref != "#/definitions/address" {
+		t.Fatalf("ref was rewritten to %q", ref)
+	}
+
+	m := spec.Messages["findUser"]
+	if len(m.OutSchemas) != 2 || m.OutSchemas[1] != spec.Definitions["#/definitions/error"] {
+		t.Fatalf("outs were %v", m.OutSchemas)
+	}
+
+	// the raw spec is self-contained
+	if strings.Contains(string(spec.Raw), "common.json") {
+		t.Fatalf("raw spec still references common.json: %s", spec.Raw)
+	}
+}
+
+func TestParseFileAbsoluteRefs(t *testing.T) {
+	common := writeSpecFiles(t, map[string]string{
+		"common.json": fixture.TestSchemaExternalRefsCommon,
+	})
+	defer os.RemoveAll(common)
+	dir := writeSpecFiles(t, map[string]string{
+		"spec.json": strings.Replace(fixture.TestSchemaExternalRefs, "common.json", filepath.Join(common, "common.json"), -1),
+	})
+	defer os.RemoveAll(dir)
+
+	// absolute refs are not resolved relative to the spec
+	spec, err := ParseFile(filepath.Join(dir, "spec.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"#/definitions/error", "#/definitions/address"} {
+		if _, ok := spec.Definitions[k]; !ok {
+			t.Fatalf("%v should be inlined", k)
+		}
+	}
+}
+
+func TestParseFileByteOrderMark(t *testing.T) {
+	dir := writeSpecFiles(t, map[string]string{
+		"spec.json":   "\xef\xbb\xbf" + fixture.TestSchemaExternalRefs,
+		"common.json": "\xef\xbb\xbf" + fixture.TestSchemaExternalRefsCommon,
+	})
+	defer os.RemoveAll(dir)
+
+	spec, err := ParseFile(filepath.Join(dir, "spec.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := spec.Definitions["#/definitions/error"]; !ok {
+		t.Fatal("#/definitions/error should be inlined")
+	}
+}
+
+func TestParseFileExternalRefErrors(t *testing.T) {
+	table := []struct {
+		Name   string
+		From   string
+		To     string
+		Common string
+		Error  string
+	}{
+		{
+			"missing file",
+			`"common.json#/definitions/error"`,
+			`"missing.json#/definitions/error"`,
+			fixture.TestSchemaExternalRefsCommon,
+			`jsonmsg: $ref missing.json#/definitions/error: open {dir}/missing.json: no such file or directory`,
+		},
+		{
+			"missing definition",
+			`"common.json#/definitions/error"`,
+			`"common.json#/definitions/eror"`,
+			fixture.TestSchemaExternalRefsCommon,
+			`jsonmsg: $ref common.json#/definitions/eror: definition does not exist in {dir}/common.json`,
+		},
+		{
+			"no definition pointer",
+			`"common.json#/definitions/error"`,
+			`"common.json#/messages/error"`,
+			fixture.TestSchemaExternalRefsCommon,
+			`jsonmsg: $ref common.json#/messages/error: only definitions of other files can be referenced`,
+		},
+		{
+			"colliding definition",
+			`"common.json#/definitions/address"`,
+			`"common.json#/definitions/user"`,
+			strings.Replace(fixture.TestSchemaExternalRefsCommon, `"address": {`, `"user": {`, 1),
+			`jsonmsg: $ref common.json#/definitions/user: definition "user" collides with a definition of the spec`,
+		},
+	}
+	for _, ts := range table {
+		dir := writeSpecFiles(t, map[string]string{
+			"spec.json":   strings.Replace(fixture.TestSchemaExternalRefs, ts.From, ts.To, 1),
+			"common.json": ts.Common,
+		})
+		_, err := ParseFile(filepath.Join(dir, "spec.json"))
+		os.RemoveAll(dir)
+		if err == nil {
+			t.Fatalf("%v: should not parse", ts.Name)
+		}
+		expected := strings.Replace(ts.Error, "{dir}", dir, -1)
+		if err.Error() != expected {
+			t.Fatalf("%v: error was %q, expected %q", ts.Name, err.Error(), expected)
+		}
+	}
+}
+
+func TestParseFileExtends(t *testing.T) {
+	dir := writeSpecFiles(t, map[string]string{
+		"base.json": fixture.TestSchemaExtendsBase,
+		"spec.json": fixture.TestSchemaExtendsChild,
+	})
+	defer os.RemoveAll(dir)
+
+	spec, err := ParseFile(filepath.Join(dir, "spec.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// merged messages and definitions
+	for _, k := range []string{"ping", "findUser"} {
+		if _, ok := spec.Messages[k]; !ok {
+			t.Fatalf("message %v should be merged", k)
+		}
+	}
+	for _, k := range []string{"#/definitions/message", "#/definitions/user"} {
+		if _, ok := spec.Definitions[k]; !ok {
+			t.Fatalf("%v should be merged", k)
+		}
+	}
+
+	// the spec overrides the base
+	if _, ok := spec.Definitions["#/definitions/error/properties/code"]; !ok {
+		t.Fatal("error definition of the spec should override the base")
+	}
+	if e := spec.Endpoints["websocket"].String(); e != "wss://users.specc.io/v1/websocket" {
+		t.Fatalf("websocket endpoint was %v", e)
+	}
+	if e := spec.Endpoints["http"].String(); e != "https://api.specc.io/v1/http" {
+		t.Fatalf("http endpoint was %v", e)
+	}
+	if strings.Contains(string(spec.Raw), "extends") {
+		t.Fatalf("raw spec still extends base.json: %s", spec.Raw)
+	}
+
+	// only ParseFile resolves base specs
+	_, err = Parse([]byte(fixture.TestSchemaExtendsChild))
+	expected := "jsonmsg: spec extends base.json, which only ParseFile resolves"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("error was %v, expected %v", err, expected)
+	}
+}
+
+func TestParseFileExtendsAbsolutePath(t *testing.T) {
+	base := writeSpecFiles(t, map[string]string{
+		"base.json": fixture.TestSchemaExtendsBase,
+	})
+	defer os.RemoveAll(base)
+	dir := writeSpecFiles(t, map[string]string{
+		"spec.json": strings.Replace(fixture.TestSchemaExtendsChild, `"base.json"`, strconv.Quote(filepath.Join(base, "base.json")), 1),
+	})
+	defer os.RemoveAll(dir)
+
+	// absolute base paths are not resolved relative to the spec
+	spec, err := ParseFile(filepath.Join(dir, "spec.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := spec.Messages["ping"]; !ok {
+		t.Fatal("message ping of the base should be merged")
+	}
+}
+
+func TestParseFileExtendsErrors(t *testing.T) {
+	table := []struct {
+		Name  string
+		Base  string
+		Spec  string
+		Error string
+	}{
+		{
+			"conflicting message",
+			fixture.TestSchemaExtendsBase,
+			strings.Replace(fixture.TestSchemaExtendsChild, `"findUser": {`, `"ping": {`, 1),
+			"jsonmsg: extends base.json: messages ping are already declared by the base spec",
+		},
+		{
+			"missing base",
+			fixture.TestSchemaExtendsBase,
+			strings.Replace(fixture.TestSchemaExtendsChild, `"base.json"`, `"missing.json"`, 1),
+			"jsonmsg: extends missing.json: open {dir}/missing.json: no such file or directory",
+		},
+		{
+			"cyclic base",
+			strings.Replace(fixture.TestSchemaExtendsBase, `"endpoints"`, `"extends": "spec.json", "endpoints"`, 1),
+			fixture.TestSchemaExtendsChild,
+			"jsonmsg: extends spec.json: {dir}/spec.json is extended cyclically",
+		},
+	}
+	for _, ts := range table {
+		dir := writeSpecFiles(t, map[string]string{
+			"base.json": ts.Base,
+			"spec.json": ts.Spec,
+		})
+		_, err := ParseFile(filepath.Join(dir, "spec.json"))
+		os.RemoveAll(dir)
+		expected := strings.Replace(ts.Error, "{dir}", dir, -1)
+		if err == nil || err.Error() != expected {
+			t.Fatalf("%v: error was %v, expected %q", ts.Name, err, expected)
+		}
+	}
+}
+
+// writes files to a new temporary directory and returns its absolute path
+func writeSpecFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "jsonmsg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
@@ -157,6 +157,962 @@ const (
 	}
 }
 `
+	// A schema with an intended empty message and a message missing in and outs
+	TestSchemaStrictEmptyMessages = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"ping": {
+			"empty": true
+		},
+		"logout": {
+			"description": "Ends the session"
+		}
+	}
+}
+`
+	// A schema with examples of a message and of a definition
+	TestSchemaExamples = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"findUser": {
+			"in": "#/definitions/userQuery",
+			"outs": [
+				"#/definitions/user"
+			],
+			"examples": [
+				{"id": "42"}
+			]
+		}
+	},
+	"definitions": {
+		"userQuery": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			},
+			"required": ["id"],
+			"examples": [
+				{"id": "7"}
+			]
+		},
+		"user": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				},
+				"name": {
+					"type": "string"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with a writeOnly password and a readOnly id, nested in a referencing definition
+	TestSchemaReadWriteOnly = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"updateCredentials": {
+			"in": "#/definitions/credentials",
+			"outs": [
+				"#/definitions/credentials"
+			]
+		},
+		"updateAccount": {
+			"in": "#/definitions/account",
+			"outs": [
+				"#/definitions/account"
+			]
+		}
+	},
+	"definitions": {
+		"credentials": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"password": {
+					"type": "string",
+					"writeOnly": true
+				}
+			},
+			"required": ["name"]
+		},
+		"account": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string",
+					"readOnly": true
+				},
+				"credentials": {
+					"$ref": "#/definitions/credentials"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with a message accepting alternative in schemas
+	TestSchemaAlternativeIns = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"login": {
+			"in": [
+				"#/definitions/credentials",
+				"#/definitions/token"
+			],
+			"outs": [
+				"#/definitions/session"
+			]
+		}
+	},
+	"definitions": {
+		"credentials": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"password": {
+					"type": "string"
+				}
+			},
+			"required": ["name", "password"]
+		},
+		"token": {
+			"type": "object",
+			"properties": {
+				"token": {
+					"type": "string"
+				}
+			},
+			"required": ["token"]
+		},
+		"session": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			},
+			"required": ["id"]
+		}
+	}
+}
+`
+	// A schema overriding go field names of properties with x-go-name
+	TestSchemaGoNames = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"updateUser": {
+			"in": "#/definitions/user",
+			"outs": [
+				"#/definitions/user"
+			]
+		}
+	},
+	"definitions": {
+		"user": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string",
+					"x-go-name": "UserID"
+				},
+				"url_slug": {
+					"type": "string",
+					"x-go-name": "Slug"
+				},
+				"name": {
+					"type": "string"
+				}
+			},
+			"required": ["id", "url_slug"]
+		}
+	}
+}
+`
+	// A schema with two outs of a message sharing the json name user
+	TestSchemaOutNameCollision = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"findUser": {
+			"in": "#/definitions/userQuery",
+			"outs": [
+				"#/definitions/user",
+				"#/definitions/account/properties/user"
+			]
+		}
+	},
+	"definitions": {
+		"userQuery": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		},
+		"user": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		},
+		"account": {
+			"type": "object",
+			"properties": {
+				"user": {
+					"type": "string"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with endpoints given as host and protocols
+	TestSchemaHostEndpoints = `
+{
+	"endpoints": {
+		"host": "api.specc.io/v1",
+		"protocols": ["http", "websocket"],
+		"tls": true
+	},
+	"messages": {
+		"logout": {
+			"outs": [
+				"#/definitions/session"
+			]
+		}
+	},
+	"definitions": {
+		"session": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with a deprecated message and a deprecated definition
+	TestSchemaDeprecated = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"findUser": {
+			"in": "#/definitions/userQuery",
+			"outs": [
+				"#/definitions/user"
+			]
+		},
+		"findUserByName": {
+			"in": "#/definitions/nameQuery",
+			"outs": [
+				"#/definitions/user"
+			],
+			"deprecated": true
+		}
+	},
+	"definitions": {
+		"userQuery": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		},
+		"nameQuery": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				}
+			},
+			"deprecated": true
+		},
+		"user": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with defaults of properties
+	TestSchemaDefaults = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"search": {
+			"in": "#/definitions/query",
+			"outs": [
+				"#/definitions/result"
+			]
+		}
+	},
+	"definitions": {
+		"query": {
+			"type": "object",
+			"properties": {
+				"term": {
+					"type": "string"
+				},
+				"limit": {
+					"type": "integer",
+					"default": 10
+				},
+				"order": {
+					"type": "string",
+					"default": "relevance"
+				},
+				"exact": {
+					"type": "boolean",
+					"default": true
+				},
+				"page": {
+					"$ref": "#/definitions/page"
+				}
+			},
+			"required": ["term"]
+		},
+		"page": {
+			"type": "object",
+			"properties": {
+				"size": {
+					"type": "number",
+					"default": 2.5
+				}
+			}
+		},
+		"result": {
+			"type": "object",
+			"properties": {
+				"count": {
+					"type": "integer"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with a free-form object property bounded by minProperties and maxProperties
+	TestSchemaPropertyCounts = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"tagItem": {
+			"in": "#/definitions/item",
+			"outs": [
+				"#/definitions/item"
+			]
+		}
+	},
+	"definitions": {
+		"item": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				},
+				"labels": {
+					"type": "object",
+					"additionalProperties": {
+						"type": "string"
+					},
+					"minProperties": 1,
+					"maxProperties": 2
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with static response headers of a message
+	TestSchemaResponseHeaders = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"findUser": {
+			"in": "#/definitions/userQuery",
+			"outs": [
+				"#/definitions/user"
+			],
+			"headers": {
+				"cache-control": "max-age=60, public",
+				"X-Api-Hint": "\"cached\" & <stable>"
+			}
+		},
+		"deleteUser": {
+			"in": "#/definitions/userQuery"
+		}
+	},
+	"definitions": {
+		"userQuery": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		},
+		"user": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with a user of all property types, nested objects and arrays of objects
+	TestSchemaUserProfile = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"findUser": {
+			"in": "#/definitions/userQuery",
+			"outs": [
+				"#/definitions/user"
+			]
+		}
+	},
+	"definitions": {
+		"userQuery": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		},
+		"user": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				},
+				"name": {
+					"type": "string"
+				},
+				"age": {
+					"type": "integer"
+				},
+				"score": {
+					"type": "number"
+				},
+				"active": {
+					"type": "boolean"
+				},
+				"tags": {
+					"type": "array",
+					"items": {
+						"type": "string"
+					}
+				},
+				"address": {
+					"type": "object",
+					"properties": {
+						"street": {
+							"type": "string"
+						},
+						"lat": {
+							"type": "number"
+						}
+					}
+				},
+				"contacts": {
+					"type": "array",
+					"items": {
+						"$ref": "#/definitions/contact"
+					}
+				},
+				"attributes": {
+					"type": "object"
+				}
+			}
+		},
+		"contact": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				},
+				"primary": {
+					"type": "boolean"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with an endpoint of a protocol generated servers do not support
+	TestSchemaUnsupportedProtocol = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1",
+		"grpc": "grpc://api.specc.io/v1"
+	},
+	"messages": {
+		"ping": {
+			"empty": true
+		}
+	}
+}
+`
+	// A schema annotated with $comment on the spec, a message, a definition and a property
+	TestSchemaComments = `
+{
+	"$comment": "internal: owned by the identity team",
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"findUser": {
+			"$comment": "internal: backed by the legacy user service",
+			"in": "#/definitions/userQuery",
+			"outs": [
+				"#/definitions/user"
+			]
+		}
+	},
+	"definitions": {
+		"userQuery": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		},
+		"user": {
+			"$comment": "internal: mirrors table users",
+			"type": "object",
+			"properties": {
+				"id": {
+					"$comment": "internal: primary key",
+					"type": "string"
+				}
+			},
+			"examples": [
+				{"id": "42", "$comment": "example data is kept"}
+			]
+		}
+	}
+}
+`
+	// A schema with a message accepting multipart forms
+	TestSchemaFormUpload = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"uploadAvatar": {
+			"in": "#/definitions/avatarUpload",
+			"outs": [
+				"#/definitions/avatar"
+			],
+			"form": true
+		},
+		"findAvatar": {
+			"in": "#/definitions/avatar",
+			"outs": [
+				"#/definitions/avatar"
+			]
+		}
+	},
+	"definitions": {
+		"avatarUpload": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"width": {
+					"type": "integer"
+				},
+				"tags": {
+					"type": "array",
+					"items": {
+						"type": "string"
+					}
+				},
+				"image": {
+					"type": "string",
+					"contentEncoding": "base64"
+				}
+			},
+			"required": ["name", "image"]
+		},
+		"avatar": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"size": {
+					"type": "integer"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with enum properties, the role values with descriptions
+	TestSchemaEnumDescriptions = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"updateUser": {
+			"in": "#/definitions/user",
+			"outs": [
+				"#/definitions/user"
+			]
+		}
+	},
+	"definitions": {
+		"user": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"role": {
+					"type": "string",
+					"enum": ["admin", "member", "guest"],
+					"x-enum-descriptions": [
+						"Manages all users",
+						"Reads and writes own data",
+						"Reads public data only"
+					]
+				},
+				"status": {
+					"type": "string",
+					"enum": ["active", "blocked"]
+				}
+			},
+			"required": ["name"]
+		}
+	}
+}
+`
+	// A schema with endpoints relative to the serving host
+	TestSchemaRelativeEndpoints = `
+{
+	"endpoints": {
+		"http": "/v1",
+		"websocket": "/v1"
+	},
+	"messages": {
+		"loginWithCredentials": {
+			"in": "#/definitions/credentials",
+			"outs": [
+				"#/definitions/session"
+			]
+		}
+	},
+	"definitions": {
+		"credentials": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"password": {
+					"type": "string"
+				}
+			},
+			"required": ["name", "password"]
+		},
+		"session": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			},
+			"required": ["id"]
+		}
+	}
+}
+`
+	// A schema with string formats of properties and array items
+	TestSchemaFormats = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"signup": {
+			"in": "#/definitions/signup",
+			"outs": [
+				"#/definitions/signup"
+			]
+		}
+	},
+	"definitions": {
+		"signup": {
+			"type": "object",
+			"properties": {
+				"email": {
+					"type": "string",
+					"format": "email"
+				},
+				"homepage": {
+					"type": "string",
+					"format": "uri"
+				},
+				"id": {
+					"type": "string",
+					"format": "uuid"
+				},
+				"ips": {
+					"type": "array",
+					"items": {
+						"type": "string",
+						"format": "ipv4"
+					}
+				},
+				"birthday": {
+					"type": "string",
+					"format": "date"
+				}
+			},
+			"required": ["email"]
+		}
+	}
+}
+`
+	// A schema with declared groups and a message with a mistyped group
+	TestSchemaMistypedGroup = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"groups": ["login", "logout"],
+	"messages": {
+		"loginWithCredentials": {
+			"in": "#/definitions/credentials",
+			"outs": [
+				"#/definitions/session"
+			],
+			"group": "logn"
+		},
+		"logout": {
+			"in": "#/definitions/session",
+			"group": "logout"
+		},
+		"ping": {}
+	},
+	"definitions": {
+		"credentials": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"password": {
+					"type": "string"
+				}
+			}
+		},
+		"session": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema requiring properties depending on another property
+	TestSchemaConditionalRequired = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"pay": {
+			"in": "#/definitions/payment",
+			"outs": [
+				"#/definitions/payment"
+			]
+		}
+	},
+	"definitions": {
+		"payment": {
+			"type": "object",
+			"properties": {
+				"type": {
+					"type": "string",
+					"enum": ["card", "transfer"]
+				},
+				"cardNumber": {
+					"type": "string"
+				},
+				"iban": {
+					"type": "string"
+				}
+			},
+			"required": ["type"],
+			"if": {
+				"properties": {
+					"type": {
+						"const": "card"
+					}
+				}
+			},
+			"then": {
+				"required": ["cardNumber"]
+			},
+			"else": {
+				"required": ["iban"]
+			}
+		}
+	}
+}
+`
+
+	// A schema with a message answered with all its outs at once
+	TestSchemaBatchOuts = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1",
+		"websocket": "ws://api.specc.io/v1"
+	},
+	"messages": {
+		"search": {
+			"in": "#/definitions/query",
+			"outs": [
+				"#/definitions/results",
+				"#/definitions/meta"
+			],
+			"batch": true
+		}
+	},
+	"definitions": {
+		"query": {
+			"type": "object",
+			"properties": {
+				"term": {
+					"type": "string"
+				}
+			}
+		},
+		"results": {
+			"type": "object",
+			"properties": {
+				"names": {
+					"type": "array",
+					"items": {
+						"type": "string"
+					}
+				}
+			}
+		},
+		"meta": {
+			"type": "object",
+			"properties": {
+				"total": {
+					"type": "integer"
+				}
+			}
+		}
+	}
+}
+`
+
+	// A schema with a tuple definition of coordinates
+	TestSchemaTuples = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"locate": {
+			"in": "#/definitions/place",
+			"outs": [
+				"#/definitions/place"
+			]
+		}
+	},
+	"definitions": {
+		"place": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"location": {
+					"$ref": "#/definitions/coordinates"
+				}
+			}
+		},
+		"coordinates": {
+			"description": "Latitude and longitude",
+			"type": "array",
+			"items": [
+				{
+					"type": "number"
+				},
+				{
+					"type": "number"
+				}
+			],
+			"additionalItems": false
+		}
+	}
+}
+`
+
 	// A schema with validations
 	TestSchemaValidationSpec = `
 {
@@ -164,8 +1120,669 @@ const (
 		"http": "http://api.specc.io/v1"
 	},
 	"messages": {
-		"sayHello": {
-			"in": "#/definitions/message",
+		"sayHello": {
+			"in": "#/definitions/message",
+			"outs": [
+				"#/definitions/message"
+			]
+		}
+	},
+	"definitions": {
+		"message": {
+			"type": "object",
+			"properties": {
+				"message": {
+					"type": "string"
+				}
+			},
+			"required": ["message"]
+		}
+	}
+}
+`
+	// A schema with required credentials and a message without in and outs
+	TestSchemaRequiredCredentials = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"loginWithCredentials": {
+			"in": "#/definitions/credentials",
+			"outs": [
+				"#/definitions/session"
+			]
+		},
+		"logout": {}
+	},
+	"definitions": {
+		"credentials": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"password": {
+					"type": "string"
+				}
+			},
+			"required": ["name", "password"]
+		},
+		"session": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			},
+			"required": ["id"]
+		}
+	}
+}
+`
+	// A schema with a custom error schema
+	TestSchemaCustomErrorSchema = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"errorSchema": "#/definitions/apiError",
+	"messages": {
+		"sayHello": {
+			"in": "#/definitions/message",
+			"outs": [
+				"#/definitions/message"
+			]
+		}
+	},
+	"definitions": {
+		"message": {
+			"type": "object",
+			"properties": {
+				"message": {
+					"type": "string"
+				}
+			},
+			"required": ["message"]
+		},
+		"apiError": {
+			"type": "object",
+			"properties": {
+				"code": {
+					"type": "string"
+				},
+				"reason": {
+					"type": "string"
+				}
+			},
+			"required": ["reason"]
+		}
+	}
+}
+`
+	// A schema with inline nested object properties
+	TestSchemaInlineObjectProperties = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"updateUser": {
+			"in": "#/definitions/user",
+			"outs": [
+				"#/definitions/user"
+			]
+		}
+	},
+	"definitions": {
+		"user": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"address": {
+					"type": "object",
+					"properties": {
+						"street": {
+							"type": "string"
+						},
+						"geo": {
+							"type": "object",
+							"properties": {
+								"lat": {
+									"type": "number"
+								},
+								"lng": {
+									"type": "number"
+								}
+							},
+							"required": ["lat", "lng"]
+						}
+					},
+					"required": ["street"]
+				}
+			},
+			"required": ["name"]
+		}
+	}
+}
+`
+	// A schema with integer and number properties
+	TestSchemaIntegerAndNumber = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"record": {
+			"in": "#/definitions/measurement",
+			"outs": [
+				"#/definitions/measurement"
+			]
+		}
+	},
+	"definitions": {
+		"measurement": {
+			"type": "object",
+			"properties": {
+				"count": {
+					"type": "integer",
+					"minimum": 0
+				},
+				"value": {
+					"type": "number",
+					"maximum": 100.5
+				}
+			},
+			"required": ["count", "value"]
+		}
+	}
+}
+`
+	// A schema with a const discriminator property
+	TestSchemaConstDiscriminator = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"draw": {
+			"in": "#/definitions/drawing",
+			"outs": [
+				"#/definitions/circle"
+			]
+		}
+	},
+	"definitions": {
+		"drawing": {
+			"type": "object",
+			"properties": {
+				"shape": {
+					"$ref": "#/definitions/circle"
+				},
+				"version": {
+					"type": "integer",
+					"const": 2
+				}
+			},
+			"required": ["shape"]
+		},
+		"circle": {
+			"type": "object",
+			"properties": {
+				"kind": {
+					"type": "string",
+					"const": "circle"
+				},
+				"radius": {
+					"type": "number"
+				},
+				"empty": {
+					"type": "null"
+				}
+			},
+			"required": ["kind"]
+		}
+	}
+}
+`
+	// A schema with an optional enum property and an array of enum values
+	TestSchemaEnumValues = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"updateUser": {
+			"in": "#/definitions/user",
+			"outs": [
+				"#/definitions/user"
+			]
+		}
+	},
+	"definitions": {
+		"user": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"status": {
+					"type": "string",
+					"enum": ["active", "blocked"]
+				},
+				"roles": {
+					"type": "array",
+					"items": {
+						"type": "string",
+						"enum": ["admin", "member"]
+					}
+				}
+			},
+			"required": ["name"]
+		}
+	}
+}
+`
+	// A schema with a message returning an array definition
+	TestSchemaArrayOuts = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"listUsers": {
+			"in": "#/definitions/listQuery",
+			"outs": [
+				"#/definitions/users"
+			]
+		}
+	},
+	"definitions": {
+		"listQuery": {
+			"type": "object",
+			"properties": {
+				"limit": {
+					"type": "integer"
+				}
+			},
+			"required": ["limit"]
+		},
+		"users": {
+			"type": "array",
+			"items": {
+				"$ref": "#/definitions/user"
+			}
+		},
+		"user": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				},
+				"name": {
+					"type": "string"
+				}
+			},
+			"required": ["id"]
+		}
+	}
+}
+`
+	// A schema with annotated message outs
+	TestSchemaAnnotatedOuts = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"loginWithCredentials": {
+			"in": "#/definitions/credentials",
+			"outs": [
+				{
+					"$ref": "#/definitions/session",
+					"description": "Returned when the credentials are valid"
+				},
+				{
+					"$ref": "#/definitions/error",
+					"description": "Returned when the credentials are invalid"
+				},
+				"#/definitions/message"
+			]
+		}
+	},
+	"definitions": {
+		"credentials": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"password": {
+					"type": "string"
+				}
+			}
+		},
+		"session": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		},
+		"error": {
+			"type": "object",
+			"properties": {
+				"error": {
+					"type": "string"
+				}
+			}
+		},
+		"message": {
+			"type": "object",
+			"properties": {
+				"message": {
+					"type": "string"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with messages and properties named after go keywords and predeclared identifiers
+	TestSchemaKeywordNames = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"type": {
+			"in": "#/definitions/item",
+			"outs": [
+				"#/definitions/item"
+			]
+		},
+		"range": {
+			"outs": [
+				"#/definitions/item"
+			]
+		},
+		"2fa.verify": {
+			"in": "#/definitions/item"
+		}
+	},
+	"definitions": {
+		"item": {
+			"type": "object",
+			"properties": {
+				"type": {
+					"type": "string"
+				},
+				"func": {
+					"type": "string"
+				},
+				"string": {
+					"type": "string"
+				},
+				"len": {
+					"type": "integer"
+				},
+				"nil": {
+					"type": "boolean"
+				}
+			},
+			"required": ["type", "func", "string", "len", "nil"]
+		}
+	}
+}
+`
+	// A schema referencing definitions of the adjacent file common.json (TestSchemaExternalRefsCommon)
+	TestSchemaExternalRefs = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"findUser": {
+			"in": "#/definitions/userQuery",
+			"outs": [
+				"#/definitions/user",
+				"common.json#/definitions/error"
+			]
+		}
+	},
+	"definitions": {
+		"userQuery": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		},
+		"user": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				},
+				"address": {
+					"$ref": "common.json#/definitions/address"
+				}
+			}
+		}
+	}
+}
+`
+	// Definitions shared across specs, referenced by TestSchemaExternalRefs as common.json
+	TestSchemaExternalRefsCommon = `
+{
+	"definitions": {
+		"error": {
+			"type": "object",
+			"properties": {
+				"error": {
+					"type": "string"
+				},
+				"details": {
+					"$ref": "#/definitions/errorDetails"
+				}
+			}
+		},
+		"errorDetails": {
+			"type": "object",
+			"properties": {
+				"message": {
+					"type": "string"
+				},
+				"cause": {
+					"$ref": "common.json#/definitions/error"
+				}
+			}
+		},
+		"address": {
+			"type": "object",
+			"properties": {
+				"street": {
+					"type": "string"
+				}
+			}
+		}
+	}
+}
+`
+	// A base spec with endpoints and common definitions, extended by TestSchemaExtendsChild as base.json
+	TestSchemaExtendsBase = `
+{
+	"endpoints": {
+		"http": "https://api.specc.io/v1",
+		"websocket": "wss://api.specc.io/v1"
+	},
+	"messages": {
+		"ping": {
+			"outs": [
+				"#/definitions/message"
+			]
+		}
+	},
+	"definitions": {
+		"message": {
+			"type": "object",
+			"properties": {
+				"message": {
+					"type": "string"
+				}
+			}
+		},
+		"error": {
+			"type": "object",
+			"properties": {
+				"error": {
+					"type": "string"
+				}
+			}
+		}
+	}
+}
+`
+	// A service spec extending TestSchemaExtendsBase, overriding its error definition and websocket endpoint
+	TestSchemaExtendsChild = `
+{
+	"extends": "base.json",
+	"endpoints": {
+		"websocket": "wss://users.specc.io/v1"
+	},
+	"messages": {
+		"findUser": {
+			"in": "#/definitions/userQuery",
+			"outs": [
+				"#/definitions/user",
+				"#/definitions/error"
+			]
+		}
+	},
+	"definitions": {
+		"userQuery": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		},
+		"user": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				}
+			}
+		},
+		"error": {
+			"type": "object",
+			"properties": {
+				"error": {
+					"type": "string"
+				},
+				"code": {
+					"type": "integer"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema with nullable properties using type arrays
+	TestSchemaNullableTypes = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"updateProfile": {
+			"in": "#/definitions/profile",
+			"outs": [
+				"#/definitions/profile"
+			]
+		}
+	},
+	"definitions": {
+		"profile": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"nickname": {
+					"type": ["string", "null"]
+				},
+				"email": {
+					"type": ["string", "null"]
+				}
+			},
+			"required": ["name", "email"]
+		}
+	}
+}
+`
+	// A schema with a message listing the same out twice
+	TestSchemaDuplicateOuts = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"fetchUser": {
+			"in": "#/definitions/user-query",
+			"outs": [
+				"#/definitions/user-info",
+				"#/definitions/user-info"
+			]
+		}
+	},
+	"definitions": {
+		"user-query": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				}
+			}
+		},
+		"user-info": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				}
+			}
+		},
+		"user_info": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				}
+			}
+		}
+	}
+}
+`
+	// A schema defining a message with the reserved name fetchSpec
+	TestSchemaReservedFetchSpec = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"fetchSpec": {
+			"outs": [
+				"#/definitions/message"
+			]
+		},
+		"ping": {
 			"outs": [
 				"#/definitions/message"
 			]
@@ -178,8 +1795,48 @@ const (
 				"message": {
 					"type": "string"
 				}
+			}
+		}
+	}
+}
+`
+	// A schema with an inline message in schema referenced by another message
+	TestSchemaMessageLocalSchemas = `
+{
+	"endpoints": {
+		"http": "http://api.specc.io/v1"
+	},
+	"messages": {
+		"findUser": {
+			"in": {
+				"type": "object",
+				"properties": {
+					"id": {
+						"type": "string"
+					}
+				},
+				"required": ["id"]
+			},
+			"outs": [
+				"#/definitions/user"
+			]
+		},
+		"deleteUser": {
+			"in": "#/messages/findUser/in"
+		}
+	},
+	"definitions": {
+		"user": {
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string"
+				},
+				"name": {
+					"type": "string"
+				}
 			},
-			"required": ["message"]
+			"required": ["id", "name"]
 		}
 	}
 }
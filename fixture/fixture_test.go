@@ -11,6 +11,45 @@ func TestFixtureUnmarshal(t *testing.T) {
 		"TestSchemaSimpleLoginHTTPandWebsocket": TestSchemaSimpleLoginHTTPandWebsocket,
 		"TestSchemaEmptyMessages":               TestSchemaEmptyMessages,
 		"TestSchemaValidationSpec":              TestSchemaValidationSpec,
+		"TestSchemaMessageLocalSchemas":         TestSchemaMessageLocalSchemas,
+		"TestSchemaCustomErrorSchema":           TestSchemaCustomErrorSchema,
+		"TestSchemaInlineObjectProperties":      TestSchemaInlineObjectProperties,
+		"TestSchemaIntegerAndNumber":            TestSchemaIntegerAndNumber,
+		"TestSchemaConstDiscriminator":          TestSchemaConstDiscriminator,
+		"TestSchemaReservedFetchSpec":           TestSchemaReservedFetchSpec,
+		"TestSchemaEnumValues":                  TestSchemaEnumValues,
+		"TestSchemaKeywordNames":                TestSchemaKeywordNames,
+		"TestSchemaAnnotatedOuts":               TestSchemaAnnotatedOuts,
+		"TestSchemaArrayOuts":                   TestSchemaArrayOuts,
+		"TestSchemaDuplicateOuts":               TestSchemaDuplicateOuts,
+		"TestSchemaRequiredCredentials":         TestSchemaRequiredCredentials,
+		"TestSchemaStrictEmptyMessages":         TestSchemaStrictEmptyMessages,
+		"TestSchemaNullableTypes":               TestSchemaNullableTypes,
+		"TestSchemaExternalRefs":                TestSchemaExternalRefs,
+		"TestSchemaExternalRefsCommon":          TestSchemaExternalRefsCommon,
+		"TestSchemaExamples":                    TestSchemaExamples,
+		"TestSchemaReadWriteOnly":               TestSchemaReadWriteOnly,
+		"TestSchemaAlternativeIns":              TestSchemaAlternativeIns,
+		"TestSchemaGoNames":                     TestSchemaGoNames,
+		"TestSchemaOutNameCollision":            TestSchemaOutNameCollision,
+		"TestSchemaHostEndpoints":               TestSchemaHostEndpoints,
+		"TestSchemaDeprecated":                  TestSchemaDeprecated,
+		"TestSchemaDefaults":                    TestSchemaDefaults,
+		"TestSchemaPropertyCounts":              TestSchemaPropertyCounts,
+		"TestSchemaResponseHeaders":             TestSchemaResponseHeaders,
+		"TestSchemaUserProfile":                 TestSchemaUserProfile,
+		"TestSchemaUnsupportedProtocol":         TestSchemaUnsupportedProtocol,
+		"TestSchemaComments":                    TestSchemaComments,
+		"TestSchemaFormUpload":                  TestSchemaFormUpload,
+		"TestSchemaEnumDescriptions":            TestSchemaEnumDescriptions,
+		"TestSchemaRelativeEndpoints":           TestSchemaRelativeEndpoints,
+		"TestSchemaFormats":                     TestSchemaFormats,
+		"TestSchemaMistypedGroup":               TestSchemaMistypedGroup,
+		"TestSchemaConditionalRequired":         TestSchemaConditionalRequired,
+		"TestSchemaBatchOuts":                   TestSchemaBatchOuts,
+		"TestSchemaExtendsBase":                 TestSchemaExtendsBase,
+		"TestSchemaExtendsChild":                TestSchemaExtendsChild,
+		"TestSchemaTuples":                      TestSchemaTuples,
 	}
 	for k, v := range fs {
 		var o interface{}
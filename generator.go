@@ -0,0 +1,71 @@
+package jsonmsg
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Version of jsonmsg, stamped into generated sources
+const Version = "0.1.0"
+
+var (
+	generatorsMu sync.RWMutex
+	generators   = make(map[string]func(*Spec, string) ([]byte, error))
+)
+
+func init() {
+	RegisterGenerator("json-schema", func(s *Spec, pack string) ([]byte, error) {
+		return s.JSONSchema()
+	})
+	RegisterGenerator("asyncapi", func(s *Spec, pack string) ([]byte, error) {
+		return s.AsyncAPI()
+	})
+	RegisterGenerator("openapi", func(s *Spec, pack string) ([]byte, error) {
+		return s.OpenAPI()
+	})
+}
+
+// Registers a generator under a name, e.g. go-server.
+// The generator receives the spec and a package name, which it may ignore.
+// A generator registered under the same name is replaced.
+func RegisterGenerator(name string, fn func(*Spec, string) ([]byte, error)) {
+	generatorsMu.Lock()
+	defer generatorsMu.Unlock()
+	if fn == nil {
+		panic("jsonmsg: RegisterGenerator generator is nil")
+	}
+	generators[name] = fn
+}
+
+// Returns the sorted names of all registered generators
+func Generators() []string {
+	generatorsMu.RLock()
+	defer generatorsMu.RUnlock()
+	var names []string
+	for n, _ := range generators {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generates src for a spec with the generator registered under name
+func Generate(name string, s *Spec, pack string) ([]byte, error) {
+	generatorsMu.RLock()
+	fn, ok := generators[name]
+	generatorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("jsonmsg: unknown generator %q, registered generators: %v", name, strings.Join(Generators(), ", "))
+	}
+	return fn(s, pack)
+}
+
+// Returns the stamp of sources generated from a spec, following the convention
+// of generated code, e.g. "Code generated by jsonmsg v0.1.0 from spec sha256:ab12...; DO NOT EDIT."
+// Generators prefix it with their language's line comment.
+func GeneratedStamp(s *Spec) string {
+	return fmt.Sprintf("Code generated by jsonmsg v%v from spec sha256:%x; DO NOT EDIT.", Version, sha256.Sum256(s.Raw))
+}
@@ -0,0 +1,48 @@
+package jsonmsg
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tfkhsr/jsonmsg/fixture"
+)
+
+func TestGeneratorRegistry(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	RegisterGenerator("dummy", func(s *Spec, pack string) ([]byte, error) {
+		var msgs []string
+		for k, _ := range s.Messages {
+			msgs = append(msgs, k)
+		}
+		sort.Strings(msgs)
+		return []byte(pack + ":" + strings.Join(msgs, ",")), nil
+	})
+
+	names := strings.Join(Generators(), " ")
+	for _, n := range []string{"asyncapi", "dummy", "json-schema", "openapi"} {
+		if !strings.Contains(names, n) {
+			t.Fatalf("generator %v is not registered: %v", n, names)
+		}
+	}
+
+	src, err := Generate("dummy", spc, "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != "api:loginWithCredentials,logout" {
+		t.Fatalf("dummy generated: %s", src)
+	}
+
+	_, err = Generate("unknown", spc, "api")
+	if err == nil {
+		t.Fatal("unknown generator should fail")
+	}
+	if !strings.Contains(err.Error(), "dummy") {
+		t.Fatalf("error should list registered generators: %v", err)
+	}
+}
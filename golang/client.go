@@ -0,0 +1,503 @@
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tfkhsr/jsonmsg"
+	"github.com/tfkhsr/jsonschema"
+)
+
+// Returns the path of the http endpoint and a constructor of clients resolving it against a base URL,
+// e.g. for relative endpoints served by any host
+func clientWithBase(s *jsonmsg.Spec) string {
+	e, ok := s.Endpoints["http"]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(`
+// Path of the http endpoint, e.g. to resolve it against the serving host of a relative endpoint
+const HTTPEndpointPath = %q
+
+// Returns a client of the http endpoint served at base, e.g. https://api.example.com
+func NewClientWithBase(base string) *Client {
+	return NewClient(strings.TrimSuffix(base, "/") + HTTPEndpointPath)
+}
+`, e.EscapedPath())
+}
+
+// Returns the sending of batch messages if the spec has any, whose responses are arrays of out messages.
+// Over websocket each out arrives as a frame of its own, which the WebsocketTransport collects into an array.
+func clientBatch(s *jsonmsg.Spec) string {
+	if !(serverData{Spec: s}).BatchMessages() {
+		return ""
+	}
+	return `
+// sends a batch message and returns its response messages
+func (c *Client) sendBatch(msg string, data interface{}) ([]*message, error) {
+	body, err := c.do(msg, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var outs []*message
+	err = json.Unmarshal(body, &outs)
+	if err != nil {
+		return nil, err
+	}
+	return outs, nil
+}
+`
+}
+
+// Generates a Client with a method per message if the client is enabled
+func generateClient(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	if !o.Client {
+		return nil, nil
+	}
+
+	checks, err := valueChecks(s, o)
+	if err != nil {
+		return nil, err
+	}
+	validated := valueValidated(s, checks)
+	readOnly := accessRestricted(s, s.ReadOnly)
+
+	w := bytes.NewBufferString("\n")
+	fmt.Fprintf(w, `
+// Client sends messages to the API over a Transport, HTTP by default.
+// Inputs are validated before sending, invalid inputs fail without a request.
+type Client struct {
+	// URL of the http endpoint, e.g. https://api.example.com/v1/http
+	Endpoint string
+
+	// HTTP client sending the requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+
+	// Optional transport sending the messages, an HTTPTransport to Endpoint if nil
+	Transport Transport
+}
+
+func NewClient(endpoint string) *Client {
+	return &Client{Endpoint: endpoint}
+}
+%v
+// Transport sends a message with its JSON data and returns the JSON response message, empty for messages without outs.
+// Responses with a status other than 200 are returned as *ResponseError.
+type Transport interface {
+	Do(msg string, data []byte) ([]byte, error)
+}
+
+// HTTPTransport posts messages to the http endpoint
+type HTTPTransport struct {
+	// URL of the http endpoint, e.g. https://api.example.com/v1/http
+	Endpoint string
+
+	// HTTP client sending the requests, http.DefaultClient if nil
+	Client *http.Client
+}
+
+func (t *HTTPTransport) Do(msg string, data []byte) ([]byte, error) {
+	in, err := json.Marshal(message{msg, data})
+	if err != nil {
+		return nil, err
+	}
+
+	hc := t.Client
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	res, err := hc.Post(t.Endpoint, "application/json", bytes.NewReader(in))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		e := &ResponseError{StatusCode: res.StatusCode}
+		var out *message
+		if json.Unmarshal(body, &out) == nil && out != nil {
+			e.Msg, e.Data = out.Msg, out.Data
+		}
+		return nil, e
+	}
+	return body, nil
+}
+
+// ResponseError is returned by Client methods for responses with a status other than 200
+// and for error messages over websocket, which carry no status, so their StatusCode is 0
+type ResponseError struct {
+	StatusCode int
+	Msg        string
+	Data       json.RawMessage
+}
+
+func (e *ResponseError) Error() string {
+	if e.StatusCode == 0 {
+		return e.Msg + " " + string(e.Data)
+	}
+	return "status " + strconv.Itoa(e.StatusCode) + ": " + e.Msg + " " + string(e.Data)
+}
+
+// sends a message and returns the response body
+func (c *Client) do(msg string, data interface{}) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	t := c.Transport
+	if t == nil {
+		t = &HTTPTransport{Endpoint: c.Endpoint, Client: c.HTTPClient}
+	}
+	return t.Do(msg, raw)
+}
+
+// sends a message and returns the response message, which is nil for messages without outs
+func (c *Client) send(msg string, data interface{}) (*message, error) {
+	body, err := c.do(msg, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+
+	var out *message
+	err = json.Unmarshal(body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+%v`, clientWithBase(s), clientBatch(s))
+
+	var keys []string
+	for k, _ := range s.Messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		m := s.Messages[k]
+
+		var param, arg string
+		if t := inType(m); t != "" {
+			param, arg = "in *"+t, "in"
+		} else {
+			arg = "nil"
+		}
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "// %v sends the %v message\n", m.Name, m.Msg)
+		if m.Deprecated {
+			fmt.Fprintf(w, "//\n// Deprecated: the %v message is deprecated.\n", m.Msg)
+		}
+		if len(m.OutSchemas) > 0 {
+			fmt.Fprintf(w, "func (c *Client) %v(%v) (*%vOuts, error) {\n", m.Name, param, m.Name)
+		} else {
+			fmt.Fprintf(w, "func (c *Client) %v(%v) error {\n", m.Name, param)
+		}
+
+		// zero values of the results besides the error
+		zero := "nil, "
+		if len(m.OutSchemas) == 0 {
+			zero = ""
+		}
+
+		if inType(m) != "" {
+			fmt.Fprintf(w, "\tif err := in.Validate(); err != nil {\n")
+			fmt.Fprintf(w, "\t\treturn %verr\n", zero)
+			fmt.Fprintf(w, "\t}\n")
+			if k, ok := definitionKey(s, m.InSchema); ok && validated[k] {
+				fmt.Fprintf(w, "\tif err := in.validateValues(); err != nil {\n")
+				fmt.Fprintf(w, "\t\treturn %verr\n", zero)
+				fmt.Fprintf(w, "\t}\n")
+			}
+			if k, ok := definitionKey(s, m.InSchema); ok && readOnly[k] {
+				fmt.Fprintf(w, "\tif err := in.validateReadOnly(); err != nil {\n")
+				fmt.Fprintf(w, "\t\treturn %verr\n", zero)
+				fmt.Fprintf(w, "\t}\n")
+			}
+		}
+
+		if len(m.OutSchemas) == 0 {
+			fmt.Fprintf(w, "\t_, err := c.send(Msg%v, %v)\n", m.Name, arg)
+			fmt.Fprintf(w, "\treturn err\n")
+			fmt.Fprintf(w, "}\n")
+			continue
+		}
+
+		// a batch response sets all outs it contains
+		if m.Batch {
+			fmt.Fprintf(w, "\tbatch, err := c.sendBatch(Msg%v, %v)\n", m.Name, arg)
+			fmt.Fprintf(w, "\tif err != nil {\n")
+			fmt.Fprintf(w, "\t\treturn nil, err\n")
+			fmt.Fprintf(w, "\t}\n")
+			fmt.Fprintf(w, "\tif len(batch) == 0 {\n")
+		} else {
+			fmt.Fprintf(w, "\tout, err := c.send(Msg%v, %v)\n", m.Name, arg)
+			fmt.Fprintf(w, "\tif err != nil {\n")
+			fmt.Fprintf(w, "\t\treturn nil, err\n")
+			fmt.Fprintf(w, "\t}\n")
+			fmt.Fprintf(w, "\tif out == nil {\n")
+		}
+		fmt.Fprintf(w, "\t\treturn nil, errors.New(\"empty response to message %v\")\n", m.Msg)
+		fmt.Fprintf(w, "\t}\n")
+		fmt.Fprintf(w, "\touts := &%vOuts{}\n", m.Name)
+		if m.Batch {
+			fmt.Fprintf(w, "\tfor _, out := range batch {\n")
+		}
+		fmt.Fprintf(w, "\tswitch out.Msg {\n")
+		for _, sch := range m.OutSchemas {
+			fmt.Fprintf(w, "\tcase %q:\n", sch.JSONName)
+			fmt.Fprintf(w, "\t\touts.%v = new(%v)\n", sch.Name, sch.Name)
+			fmt.Fprintf(w, "\t\terr = json.Unmarshal(out.Data, outs.%v)\n", sch.Name)
+		}
+		fmt.Fprintf(w, "\tdefault:\n")
+		fmt.Fprintf(w, "\t\treturn nil, errors.New(\"unexpected response message \" + out.Msg)\n")
+		fmt.Fprintf(w, "\t}\n")
+		fmt.Fprintf(w, "\tif err != nil {\n")
+		fmt.Fprintf(w, "\t\treturn nil, err\n")
+		fmt.Fprintf(w, "\t}\n")
+		if m.Batch {
+			fmt.Fprintf(w, "\t}\n")
+		}
+		fmt.Fprintf(w, "\treturn outs, nil\n")
+		fmt.Fprintf(w, "}\n")
+	}
+
+	if _, ok := s.Endpoints["websocket"]; ok {
+		w.WriteString(websocketClientSrc)
+		generateWebsocketResponses(w, s, keys)
+	}
+
+	return format.Source(w.Bytes())
+}
+
+// websocket transport of the client, receiving server-pushed messages
+const websocketClientSrc = `
+// WebsocketTransport sends messages over a websocket connection, one request at a time.
+// Server-pushed messages, e.g. Hub broadcasts, are dispatched to the handlers registered with On.
+// A request takes the first message that is one of its outs or an error message as response,
+// so pushed messages of these msgs need a handler. Error messages are returned as *ResponseError.
+type WebsocketTransport struct {
+	conn *websocket.Conn
+
+	// serializes requests, whose responses arrive in order
+	request sync.Mutex
+
+	// guards handlers, expected and batch
+	mu       sync.Mutex
+	handlers map[string]func(data json.RawMessage)
+	expected map[string]bool
+	batch    bool
+
+	responses chan websocketResponse
+	done      chan struct{}
+	err       error
+}
+
+// Dials the websocket endpoint, e.g. wss://api.example.com/v1/websocket, and starts reading messages
+func DialWebsocket(endpoint string) (*WebsocketTransport, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	t := &WebsocketTransport{
+		conn:      conn,
+		handlers:  make(map[string]func(data json.RawMessage)),
+		responses: make(chan websocketResponse, 1),
+		done:      make(chan struct{}),
+	}
+	go t.read()
+	return t, nil
+}
+
+// Returns a Client sending messages over a websocket connection to the endpoint
+func NewWebsocketClient(endpoint string) (*Client, error) {
+	t, err := DialWebsocket(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Endpoint: endpoint, Transport: t}, nil
+}
+
+// Registers the handler of server-pushed messages of msg, replacing any previous handler.
+// Messages of msg are never taken as response to a request. Handlers run on the read loop,
+// so they must not send messages over the same transport.
+func (t *WebsocketTransport) On(msg string, h func(data json.RawMessage)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers[msg] = h
+}
+
+func (t *WebsocketTransport) Do(msg string, data []byte) ([]byte, error) {
+	in, err := json.Marshal(message{msg, data})
+	if err != nil {
+		return nil, err
+	}
+
+	// responses are the outs of msg or an error message
+	expected := map[string]bool{newErrorMessage("").Msg: true}
+	for _, out := range websocketResponses[msg] {
+		expected[out] = true
+	}
+
+	t.request.Lock()
+	defer t.request.Unlock()
+	batch := websocketBatches[msg]
+	t.mu.Lock()
+	t.expected = expected
+	t.batch = batch
+	t.mu.Unlock()
+	err = t.conn.WriteMessage(websocket.TextMessage, in)
+	if err != nil {
+		t.mu.Lock()
+		t.expected = nil
+		t.mu.Unlock()
+		return nil, err
+	}
+	var frames [][]byte
+	var failed error
+	for {
+		select {
+		case r := <-t.responses:
+			// an error message fails the request once all its frames arrived
+			if r.err != nil && failed == nil {
+				failed = r.err
+			}
+			frames = append(frames, r.data)
+			switch {
+			case !r.last:
+			case failed != nil:
+				return nil, failed
+			case !batch:
+				return r.data, nil
+			default:
+				// a JSON array of out messages like http batch responses
+				return append(append([]byte("["), bytes.Join(frames, []byte(","))...), ']'), nil
+			}
+		case <-t.done:
+			return nil, t.err
+		}
+	}
+}
+
+// Closes the connection
+func (t *WebsocketTransport) Close() error {
+	return t.conn.Close()
+}
+
+// a response frame, which is the last one unless more frames of a batch follow,
+// with the *ResponseError of an error message
+type websocketResponse struct {
+	data []byte
+	last bool
+	err  error
+}
+
+// a received frame, frames of a batch tell how many frames of the batch follow
+type websocketFrame struct {
+	Msg       string
+	Data      json.RawMessage
+	Remaining int
+}
+
+// reads messages, dispatching them to handlers or as response to the waiting request
+// if they are one of its expected responses. Other messages are dropped.
+func (t *WebsocketTransport) read() {
+	defer close(t.done)
+	for {
+		_, data, err := t.conn.ReadMessage()
+		if err != nil {
+			t.err = err
+			return
+		}
+
+		var f websocketFrame
+		json.Unmarshal(data, &f)
+		t.mu.Lock()
+		h, ok := t.handlers[f.Msg]
+		response := !ok && t.expected[f.Msg]
+		last := !t.batch || f.Remaining == 0
+		if response && last {
+			t.expected = nil
+		}
+		t.mu.Unlock()
+
+		switch {
+		case ok:
+			h(f.Data)
+		case response && f.Msg == newErrorMessage("").Msg:
+			t.responses <- websocketResponse{data, last, &ResponseError{Msg: f.Msg, Data: f.Data}}
+		case response:
+			t.responses <- websocketResponse{data, last, nil}
+		}
+	}
+}
+
+// Registers the handler of server-pushed messages of msg, which requires a client of NewWebsocketClient
+func (c *Client) On(msg string, h func(data json.RawMessage)) error {
+	t, ok := c.Transport.(*WebsocketTransport)
+	if !ok {
+		return errors.New("client transport does not receive pushed messages")
+	}
+	t.On(msg, h)
+	return nil
+}
+
+// Closes the connection of a client of NewWebsocketClient, other clients have nothing to close
+func (c *Client) Close() error {
+	if t, ok := c.Transport.(*WebsocketTransport); ok {
+		return t.Close()
+	}
+	return nil
+}
+`
+
+// Generates the msgs of the out messages answering each message over websocket and the batch messages
+func generateWebsocketResponses(w *bytes.Buffer, s *jsonmsg.Spec, keys []string) {
+	fmt.Fprintf(w, "\n// msgs of the outs answering each message over websocket besides error messages,\n")
+	fmt.Fprintf(w, "// messages without outs are answered with null, whose msg is empty\n")
+	fmt.Fprintf(w, "var websocketResponses = map[string][]string{\n")
+	for _, k := range keys {
+		m := s.Messages[k]
+		var outs []string
+		for _, o := range m.OutSchemas {
+			outs = append(outs, strconv.Quote(o.JSONName))
+		}
+		if len(outs) == 0 {
+			outs = append(outs, `""`)
+		}
+		fmt.Fprintf(w, "\tMsg%v: {%v},\n", m.Name, strings.Join(outs, ", "))
+	}
+	fmt.Fprintf(w, "}\n")
+
+	fmt.Fprintf(w, "\n// batch messages, whose outs arrive over websocket as a frame each\n")
+	fmt.Fprintf(w, "var websocketBatches = map[string]bool{\n")
+	for _, k := range keys {
+		if m := s.Messages[k]; m.Batch {
+			fmt.Fprintf(w, "\tMsg%v: true,\n", m.Name)
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
+// Returns the definition pointer of a schema
+func definitionKey(s *jsonmsg.Spec, sch *jsonschema.Schema) (string, bool) {
+	for _, k := range definitionKeys(s) {
+		if s.Definitions[k] == sch {
+			return k, true
+		}
+	}
+	return "", false
+}
@@ -78,7 +78,11 @@ First, define and parse a spec into a go file:
 		panic(err)
 	}
 
-The api.gen.go file now contains all types, the API interface and NewAPIMux function:
+The api.gen.go file now contains all types, the API interface and NewAPIMux function.
+It starts with a stamp of the jsonmsg version and the spec hash, marking it as generated code:
+
+	// Code generated by jsonmsg v0.1.0 from spec sha256:...; DO NOT EDIT.
+
 
 	type API interface {
 		FindUser(*UserQuery) (*FindUserOuts, error)
@@ -88,6 +92,14 @@ The api.gen.go file now contains all types, the API interface and NewAPIMux func
 		...
 	}
 
+	func NewAPIMuxWithOptions(i API, o APIOptions) *http.ServeMux {
+		...
+	}
+
+	func RegisterRoutes(mux Router, i API) {
+		...
+	}
+
 	type Error struct {
 		Message *string `json:"message,omitempty"`
 	}
@@ -142,12 +154,275 @@ To run a server with the API you need to implement the API interface, e.g. in ma
 		log.Fatal(http.ListenAndServe("localhost:8000", h))
 	}
 
+NewAPIMuxWithOptions accepts APIOptions, e.g. a RequestTimeout after which a message dispatch is answered with a 503 timeout error.
+The timed out API method is abandoned, not stopped: it runs on until it returns, while metrics and payload logs record the 503.
+With Options.Context the API methods receive a context.Context as first parameter, which is cancelled on timeout
+or when the request is gone, so methods can stop their work:
+
+	func (s *Server) FindUser(ctx context.Context, q *UserQuery) (*FindUserOuts, error) {
+		rows, err := s.db.QueryContext(ctx, ...)
+		...
+	}
+
+ProcessMessage then takes the context as first parameter, Handlers pass a background context.
+Messages of a websocket connection are handled one at a time, so the next message waits until an abandoned method returned.
+
+APIOptions.Limiter is consulted for every message, denied messages are answered with status 429.
+NewRateLimiter limits messages per second by msg:
+
+	h := NewAPIMuxWithOptions(&Server{}, APIOptions{
+		Limiter: NewRateLimiter(map[string]int{"loginWithCredentials": 5}),
+	})
+
+Messages with an array of alternative pointers for in, e.g. "in": ["#/definitions/credentials", "#/definitions/token"],
+receive a wrapper type named after the message holding the first alternative the data is valid for:
+
+	func (s *Server) Login(in *LoginIn) (*LoginOuts, error) {
+		if in.Token != nil {
+			...
+		}
+		...
+	}
+
+Free-form objects, e.g. {"type": "object", "additionalProperties": {"type": "string"}}, are generated as maps.
+Their "minProperties" and "maxProperties" are validated, violating inputs are rejected with status 422.
+
+Options.ValidateFormats validates the string formats "email", "uri", "uuid" and "ipv4" of properties and array items,
+rejecting invalid inputs with status 422. The checks are lightweight, e.g. an email needs only a local part, an @ and a domain with a dot.
+Other formats are not validated.
+
+Failed validations are answered with the first failure. Options.AllValidationErrors reports all failures of an input
+in one error message joined by "; ", e.g. "invalid signup: email must be formatted as email; invalid signup: id must be formatted as uuid",
+so clients can fix them at once. The Validate methods of the types still stop at their first failure.
+
+Object definitions may require properties depending on others with "if", "then" and "else",
+e.g. a cardNumber only for payments of type card:
+
+	"if": {"properties": {"type": {"const": "card"}}},
+	"then": {"required": ["cardNumber"]},
+	"else": {"required": ["iban"]}
+
+The if schema may constrain properties by "const" or "enum" and list "required" properties,
+then and else may list "required" properties. Inputs missing them are rejected with status 422.
+
+Definitions of arrays with items given as array are tuples of fixed positions, e.g. coordinates:
+
+	"coordinates": {
+		"type": "array",
+		"items": [{"type": "number"}, {"type": "number"}],
+		"additionalItems": false
+	}
+
+They are generated as struct with a field per position, Item0, Item1 and so on, encoding as JSON array.
+Inputs missing a position are rejected with status 422, as are additional items if "additionalItems" is false.
+Tuples are only supported as definitions, other schemas reference them.
+
+Properties with a type and null, e.g. "type": ["string", "null"], decode an explicit null as nil.
+If they are required, inputs must still give them, possibly as null, and nil encodes as null in outs.
+
+Properties marked "readOnly" are rejected in inputs with status 422.
+Properties marked "writeOnly", e.g. passwords, are accepted in inputs but left out of all out messages.
+
+Object definitions get a constructor taking their required fields, which initializes optional fields
+with the "default" of their property:
+
+	q := NewQuery("term") // q.Limit is 10 with "limit": {"type": "integer", "default": 10}
+
+Messages and definitions marked "deprecated": true generate a "// Deprecated:" comment
+on their API and Client methods and types, so linters flag their use.
+
+Generated field names can be overridden with "x-go-name" on a property, the json tag keeps the property name:
+
+	"url_slug": {
+	  "type": "string",
+	  "x-go-name": "Slug"
+	}
+
+generates the field
+
+	Slug *string `json:"url_slug,omitempty"`
+
+Generated servers serve http and websocket endpoints, generating a spec with endpoints of other protocols, e.g. grpc, fails.
+
+Messages marked "form": true also accept multipart/form-data at the http endpoint, with the msg as query parameter,
+e.g. POST /v1/http?msg=uploadAvatar. Form fields bind to string, number, integer and boolean properties of the in schema,
+repeated fields to arrays. Files bind base64-encoded to string properties, e.g. {"type": "string", "contentEncoding": "base64"}.
+Multipart forms of other messages are answered with status 415.
+
+Static "headers" of a message are set on its successful http responses, failed responses lack them.
+
+The http endpoint decodes request bodies sent with Content-Encoding gzip or deflate.
+Corrupt bodies are answered with status 400, other encodings with status 415.
+
+Preflight responses allow browsers to cache them for APIOptions.PreflightMaxAge, DefaultPreflightMaxAge by default.
+
+Options.FastJSON generates MarshalJSON methods encoding the object types without reflection,
+for services marshaling outs at high throughput. Decoding keeps encoding/json.
+
+Options.Metrics serves counts by msg and status code and latencies by msg of dispatched messages
+at {base path}/metrics in Prometheus text format, ready to be scraped without wiring a registry.
+
+For debugging, APIOptions.PayloadLogger receives the inbound and outbound payloads of every message
+with writeOnly properties redacted. Payloads are not captured without a PayloadLogger.
+
+The http endpoint echoes the X-Request-ID header of a request on its response, generating a random ID if it is missing,
+e.g. to trace a message across services. A PayloadLogger implementing RequestPayloadLogger receives the request ID with the payloads.
+
+To mount the routes on another router, pass any Router with a HandleFunc(string, func(http.ResponseWriter, *http.Request)) method
+to RegisterRoutes or RegisterRoutesWithOptions instead of creating a mux.
+
+Generation can be adjusted with Options passed to ServerPackageSrcWithOptions.
+With Options.Sessions all API methods receive a *Conn as first parameter, which stores session values of a websocket connection across messages:
+
+	func (s *Server) FindUser(c *Conn, q *UserQuery) (*FindUserOuts, error) {
+		c.Set("lastQuery", *q.ID)
+		...
+	}
+
+The spec routes default to /spec.json and /spec next to the http endpoint.
+Options.SpecJSONPath and Options.SpecHTMLPath override their paths, "-" disables a route.
+While the JSON spec route is enabled, the built-in message fetchSpec is answered with a spec message holding the spec,
+so specs must not define a message named fetchSpec.
+Options.StripComments removes "$comment" annotations from the embedded spec, so internal notes are not served.
+Options.NoEmbeddedSpec leaves the spec out of the generated sources, which disables the spec routes and fetchSpec.
+
+By default, an API method returning an error discards its outs and the message is answered with status 500.
+Options.OutsOnErrorStatus answers outs returned along with an error with the given status instead, e.g. partial data with 206:
+
+	return &FindUserOuts{User: partial}, errors.New("address service unavailable")
+
+A message is answered with its first non-nil out. Messages marked "batch": true are answered with all non-nil outs,
+e.g. results and their meta data, over HTTP as a JSON array of out messages and over websocket with a frame each.
+Each websocket frame of a batch tells how many frames of the batch follow, e.g. "remaining": 0 for the last one.
+The Client sets all outs of a batch response, its WebsocketTransport collects the frames of a batch.
+
+Options.Versions serves all routes under several versions, replacing the last segment of the endpoint base path,
+e.g. with "v1" and "v2" the http endpoint /v1/http is served at /v1/http and /v2/http.
+
+Options.Health adds a liveness probe at /health next to the http endpoint, answering GET with status 200 and {"status":"ok"}.
+
+Options.JSONRPC serves JSON-RPC 2.0 requests at /jsonrpc next to the http endpoint for existing JSON-RPC tooling.
+The method is the message name and params its data, the result is the out message:
+
+	--> {"jsonrpc": "2.0", "method": "findUser", "params": {"id": "visurgif"}, "id": 1}
+	<-- {"jsonrpc": "2.0", "result": {"msg": "user", "data": {...}}, "id": 1}
+
+Failed messages are answered with a JSON-RPC error holding the error message as data,
+with code -32601 for unknown messages, -32602 for invalid data, -32603 for internal errors and -32000 otherwise.
+Batches of requests and notifications are supported.
+
+Options.StreamArrays writes outs of array definitions over HTTP element by element using chunked encoding,
+flushing periodically instead of encoding the whole array before sending it.
+
+Options.Formatter replaces go/format for formatting the generated sources, e.g. with an external formatter.
+NoFormat skips formatting, which speeds up generating huge specs while still emitting valid Go.
+Options.Transform receives the AST of the generated package before formatting,
+e.g. to add build tags, comments or methods without string manipulation.
+
+For specs with a websocket endpoint a Hub is generated, which pushes server-initiated messages to connected clients.
+Pass the same Hub to the mux and to the API implementation:
+
+	hub := NewHub()
+	h := NewAPIMuxWithOptions(&Server{hub}, APIOptions{Hub: hub})
+
+	// in any API method
+	hub.Broadcast("notification", &Notification{...})
+
+Received websocket messages are limited to APIOptions.MaxMessageSize bytes, DefaultMaxMessageSize by default.
+Oversized messages close the connection with status 1009 (message too big).
+
+APIOptions.Subprotocols lists the accepted websocket subprotocols in order of preference.
+The first of them requested by the client is selected and echoed in the upgrade response.
+
+Unparsable websocket messages are answered with an error message, then the connection is closed with status 1007.
+Errors of single messages, e.g. invalid data or failing API methods, keep the connection open.
+
+Binary websocket frames are answered with binary frames. APIOptions.BinaryCodec decodes and encodes them,
+e.g. with a msgpack library, otherwise binary frames hold JSON like text frames:
+
+	h := NewAPIMuxWithOptions(&Server{}, APIOptions{BinaryCodec: msgpackCodec{}})
+
 When updating the schema.json, api.gen.go is overriden with the latest interface definitions.
 The implemented server then needs to be adapted to conform to the latest interface.
 Testing of the server implementation does not involve any HTTP/websocket stack.
+ProcessMessage runs a message through the same dispatching as the handlers and returns the out and status code:
+
+	out, status := ProcessMessage(&Server{}, "loginWithCredentials", &Credentials{...})
+	if status != http.StatusOK {
+		...
+	}
+
+Handlers returns the same processing as a handler per msg taking the raw data,
+for wiring single messages into other transports, e.g. a lambda function:
+
+	h := Handlers(&Server{})["loginWithCredentials"]
+	out, status := h(json.RawMessage(`{"name": "abc", "password": "secret"}`))
+
+Envelope holds a message on the wire for code outside of the mux and Client, e.g. a queue consumer:
+
+	e, err := NewEnvelope(MsgFindUser, &UserQuery{ID: newString("123")})
+	...
+	var u User
+	err = reply.UnmarshalData(&u)
+
+The msg names are generated as constants, e.g. MsgLoginWithCredentials for "loginWithCredentials".
+Messages returns the sorted msg names of the spec, e.g. to pre-register metric vectors labelled by msg:
+
+	for _, msg := range Messages() {
+		requests.WithLabelValues(msg)
+	}
 
 Client
 
-Client is not implemented yet.
+Options.Client generates a Client next to the server, sending messages over HTTP with a method per message.
+Inputs are validated before sending, so invalid inputs fail without a request:
+
+	c := NewClient("https://api.example.com/v1/http")
+	outs, err := c.FindUser(&UserQuery{ID: newString("123")})
+
+For relative endpoints, e.g. {"http": "/v1"}, NewClientWithBase resolves HTTPEndpointPath against the serving host:
+
+	c := NewClientWithBase("https://api.example.com")
+
+Responses with a status other than 200 are returned as *ResponseError.
+
+For specs with a websocket endpoint, NewWebsocketClient sends messages over a websocket connection.
+Its On registers handlers of server-pushed messages, e.g. Hub broadcasts, which are never taken as responses.
+A request takes the first message of its outs or an error message as response, unhandled pushed messages of other msgs are dropped.
+Error messages are returned as *ResponseError with StatusCode 0, as websocket messages carry no status:
+
+	c, err := NewWebsocketClient("wss://api.example.com/v1/websocket")
+	...
+	defer c.Close()
+	c.On("notification", func(data json.RawMessage) {
+		...
+	})
+
+Client.Transport replaces HTTP, e.g. with an in-memory transport dispatching to Handlers of a server in tests:
+
+	type memoryTransport struct {
+		handlers map[string]func(json.RawMessage) (interface{}, int)
+	}
+
+	func (t *memoryTransport) Do(msg string, data []byte) ([]byte, error) {
+		out, status := t.handlers[msg](data)
+		...
+	}
+
+	c := &Client{Transport: &memoryTransport{Handlers(&Server{})}}
+
+Tests
+
+ServerTestPackageSrc generates a test file next to the server package with a table-driven test per message,
+calling the API method with a sample in and validating the returned outs.
+The tests are skipped until testAPI is set, e.g. in another test file:
+
+	func init() {
+		testAPI = &Server{}
+	}
+
+The jsonmsgc generator go-server-tests prints the scaffold:
+
+	jsonmsgc -file spec.json -generator go-server-tests > api_test.go
 */
 package golang
@@ -0,0 +1,277 @@
+package golang
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Generates MarshalJSON methods encoding the struct types of src without reflection, along with their helpers.
+// Strings and numbers are encoded like encoding/json does. Structs with fields the fast path cannot encode
+// the same way, e.g. without omitempty, keep the default encoding, as do the struct types named in skip.
+func generateMarshalers(src []byte, skip map[string]bool) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", append([]byte("package types\n"), src...), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// struct types in order of declaration
+	var names []string
+	structs := make(map[string]*ast.StructType)
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if st, ok := ts.Type.(*ast.StructType); ok && !skip[ts.Name.Name] {
+				names = append(names, ts.Name.Name)
+				structs[ts.Name.Name] = st
+			}
+		}
+	}
+
+	// encodable structs, dropping structs with unsupported fields
+	fields := make(map[string][]jsonField)
+	for _, n := range names {
+		fs, ok := jsonFields(structs[n])
+		if ok {
+			fields[n] = fs
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	w := bytes.NewBufferString("\n")
+	for _, n := range names {
+		fs, ok := fields[n]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "\n// MarshalJSON encodes %v without reflection\n", n)
+		fmt.Fprintf(w, "func (t *%v) MarshalJSON() ([]byte, error) {\n", n)
+		fmt.Fprintf(w, "\treturn t.appendJSON(make([]byte, 0, 256))\n")
+		fmt.Fprintf(w, "}\n")
+
+		fmt.Fprintf(w, "\n// appends the JSON encoding of %v to b\n", n)
+		fmt.Fprintf(w, "func (t *%v) appendJSON(b []byte) ([]byte, error) {\n", n)
+		fmt.Fprintf(w, "\tif t == nil {\n\t\treturn append(b, \"null\"...), nil\n\t}\n")
+		fmt.Fprintf(w, "\tvar err error\n")
+		fmt.Fprintf(w, "\tb = append(b, '{')\n")
+		fmt.Fprintf(w, "\tn := len(b)\n")
+		for _, fl := range fs {
+			key, err := json.Marshal(fl.Key)
+			if err != nil {
+				return nil, err
+			}
+			v := "t." + fl.Name
+			if _, ok := fl.Type.(*ast.StarExpr); ok {
+				fmt.Fprintf(w, "\tif %v != nil {\n", v)
+			} else {
+				fmt.Fprintf(w, "\tif len(%v) > 0 {\n", v)
+			}
+			fmt.Fprintf(w, "\t\tb = appendJSONKey(b, n, %v)\n", strconv.Quote(string(key)))
+			writeAppendValue(w, v, fl.Type, fields, "\t\t")
+			fmt.Fprintf(w, "\t}\n")
+		}
+		fmt.Fprintf(w, "\treturn append(b, '}'), err\n")
+		fmt.Fprintf(w, "}\n")
+	}
+	w.WriteString(marshalHelpers)
+
+	return format.Source(w.Bytes())
+}
+
+// A field of a struct encoded by the fast path
+type jsonField struct {
+	Name string
+	Key  string
+	Type ast.Expr
+}
+
+// Returns the fields of a struct in order, false if a field is not encoded by the fast path
+func jsonFields(st *ast.StructType) ([]jsonField, bool) {
+	var fs []jsonField
+	for _, fl := range st.Fields.List {
+		if len(fl.Names) != 1 || fl.Tag == nil || !ast.IsExported(fl.Names[0].Name) {
+			return nil, false
+		}
+		tag, err := strconv.Unquote(fl.Tag.Value)
+		if err != nil {
+			return nil, false
+		}
+		opts := strings.Split(reflect.StructTag(tag).Get("json"), ",")
+		if len(opts) != 2 || opts[1] != "omitempty" || opts[0] == "" || opts[0] == "-" {
+			return nil, false
+		}
+		switch fl.Type.(type) {
+		case *ast.StarExpr, *ast.ArrayType, *ast.MapType:
+		default:
+			return nil, false
+		}
+		fs = append(fs, jsonField{fl.Names[0].Name, opts[0], fl.Type})
+	}
+	return fs, true
+}
+
+// Writes statements appending the JSON encoding of the non-empty field value v of type t to b,
+// falling back to encoding/json for types without fast path
+func writeAppendValue(w *bytes.Buffer, v string, t ast.Expr, fields map[string][]jsonField, indent string) {
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		if id, ok := t.X.(*ast.Ident); ok {
+			if _, ok := fields[id.Name]; ok {
+				writeAppendElement(w, v, id.Name, indent)
+				return
+			}
+			if elementEncoded(id.Name, fields) {
+				writeAppendElement(w, "*"+v, id.Name, indent)
+				return
+			}
+		}
+	case *ast.ArrayType:
+		if id, ok := t.Elt.(*ast.Ident); ok && t.Len == nil && elementEncoded(id.Name, fields) {
+			fmt.Fprintf(w, "%vb = append(b, '[')\n", indent)
+			fmt.Fprintf(w, "%vfor i := range %v {\n", indent, v)
+			fmt.Fprintf(w, "%v\tif i > 0 {\n%v\t\tb = append(b, ',')\n%v\t}\n", indent, indent, indent)
+			writeAppendElement(w, v+"[i]", id.Name, indent+"\t")
+			fmt.Fprintf(w, "%v}\n", indent)
+			fmt.Fprintf(w, "%vb = append(b, ']')\n", indent)
+			return
+		}
+	}
+
+	// default encoding
+	fmt.Fprintf(w, "%vb, err = appendJSONValue(b, %v)\n", indent, v)
+	fmt.Fprintf(w, "%vif err != nil {\n%v\treturn nil, err\n%v}\n", indent, indent, indent)
+}
+
+// Writes statements appending the JSON encoding of v, a value of a basic type or a struct (pointer) with fast path
+func writeAppendElement(w *bytes.Buffer, v string, typ string, indent string) {
+	switch typ {
+	case "string":
+		fmt.Fprintf(w, "%vb = appendJSONString(b, %v)\n", indent, v)
+	case "int64":
+		fmt.Fprintf(w, "%vb = strconv.AppendInt(b, %v, 10)\n", indent, v)
+	case "bool":
+		fmt.Fprintf(w, "%vb = strconv.AppendBool(b, %v)\n", indent, v)
+	case "float64":
+		fmt.Fprintf(w, "%vb, err = appendJSONFloat(b, %v)\n", indent, v)
+		fmt.Fprintf(w, "%vif err != nil {\n%v\treturn nil, err\n%v}\n", indent, indent, indent)
+	default:
+		fmt.Fprintf(w, "%vb, err = %v.appendJSON(b)\n", indent, v)
+		fmt.Fprintf(w, "%vif err != nil {\n%v\treturn nil, err\n%v}\n", indent, indent, indent)
+	}
+}
+
+// Reports whether array elements of the named type are encoded by the fast path
+func elementEncoded(name string, fields map[string][]jsonField) bool {
+	switch name {
+	case "string", "int64", "bool", "float64":
+		return true
+	}
+	_, ok := fields[name]
+	return ok
+}
+
+// helpers of generated MarshalJSON methods, following the encoding of encoding/json
+const marshalHelpers = `
+// appends a JSON object key, preceded by a comma unless it is the first key after n
+func appendJSONKey(b []byte, n int, key string) []byte {
+	if len(b) > n {
+		b = append(b, ',')
+	}
+	b = append(b, key...)
+	return append(b, ':')
+}
+
+const jsonHex = "0123456789abcdef"
+
+// appends a JSON string, escaping HTML characters like encoding/json
+func appendJSONString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', jsonHex[c>>4], jsonHex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', jsonHex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
+
+// appends a JSON number, formatted like encoding/json
+func appendJSONFloat(b []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("json: unsupported value: %v", strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, nil
+}
+
+// appends the JSON encoding of a value without fast path by encoding/json
+func appendJSONValue(b []byte, v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, raw...), nil
+}
+`
@@ -0,0 +1,127 @@
+package golang
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tfkhsr/jsonmsg"
+)
+
+func init() {
+	jsonmsg.RegisterGenerator("go-server-tests", ServerTestPackageSrc)
+}
+
+// Generates a go test file scaffolding tests of an API implementation, to be placed next to the server package
+func ServerTestPackageSrc(s *jsonmsg.Spec, pack string) ([]byte, error) {
+	return ServerTestPackageSrcWithOptions(s, pack, Options{})
+}
+
+// Generates a go test file scaffolding tests of an API implementation with Options matching the server package.
+// Each message gets a table-driven test calling its API method with a sample in and validating the returned outs.
+// Tests are skipped until testAPI is set to the implementation under test.
+func ServerTestPackageSrcWithOptions(s *jsonmsg.Spec, pack string, o Options) ([]byte, error) {
+	var keys []string
+	for k, _ := range s.Messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w := &bytes.Buffer{}
+	var imports []string
+	if o.Context {
+		imports = append(imports, `"context"`)
+	}
+	for _, k := range keys {
+		if inType(s.Messages[k]) != "" {
+			imports = append(imports, `"encoding/json"`)
+			break
+		}
+	}
+	imports = append(imports, `"testing"`)
+	// scaffolds are meant to be edited, so they lack the generated code stamp
+	fmt.Fprintf(w, `// Test scaffold generated by jsonmsg v%v, extend the cases of each table as needed.
+
+package %v
+
+import (
+	%v
+)
+
+// API implementation under test, set e.g. in an init func of another test file.
+// Tests are skipped while it is nil.
+var testAPI API
+`, jsonmsg.Version, pack, strings.Join(imports, "\n\t"))
+
+	for _, k := range keys {
+		m := s.Messages[k]
+		var args []string
+		if o.Context {
+			args = append(args, "context.Background()")
+		}
+		if o.Sessions {
+			args = append(args, "newConn()")
+		}
+
+		fmt.Fprintf(w, "\n// %v\nfunc Test%v(t *testing.T) {\n", m.Name, m.Name)
+		fmt.Fprintf(w, "\tif testAPI == nil {\n\t\tt.Skip(\"testAPI is not set\")\n\t}\n\n")
+
+		t := inType(m)
+		if t == "" {
+			fmt.Fprintf(w, "\tcases := []struct {\n\t\tname string\n\t}{\n\t\t{\"sample\"},\n\t}\n")
+		} else {
+			in, err := sampleData(m)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(w, "\tcases := []struct {\n\t\tname string\n\t\tin   string\n\t}{\n\t\t{\"sample\", %v},\n\t}\n", in)
+			args = append(args, "&in")
+		}
+
+		fmt.Fprintf(w, "\tfor _, c := range cases {\n\t\tt.Run(c.name, func(t *testing.T) {\n")
+		if t != "" {
+			fmt.Fprintf(w, "\t\t\tvar in %v\n", t)
+			fmt.Fprintf(w, "\t\t\terr := json.Unmarshal([]byte(c.in), &in)\n\t\t\tif err != nil {\n\t\t\t\tt.Fatal(err)\n\t\t\t}\n\n")
+		}
+		call := fmt.Sprintf("testAPI.%v(%v)", m.Name, strings.Join(args, ", "))
+		if len(m.OutSchemas) == 0 {
+			fmt.Fprintf(w, "\t\t\terr %v %v\n\t\t\tif err != nil {\n\t\t\t\tt.Fatal(err)\n\t\t\t}\n", declare(t != ""), call)
+		} else {
+			fmt.Fprintf(w, "\t\t\touts, err := %v\n\t\t\tif err != nil {\n\t\t\t\tt.Fatal(err)\n\t\t\t}\n", call)
+			fmt.Fprintf(w, "\t\t\tif outs == nil {\n\t\t\t\tt.Fatal(\"no outs\")\n\t\t\t}\n")
+			for _, out := range m.OutSchemas {
+				fmt.Fprintf(w, "\t\t\tif outs.%v != nil {\n\t\t\t\terr = outs.%v.Validate()\n\t\t\t\tif err != nil {\n\t\t\t\t\tt.Fatal(err)\n\t\t\t\t}\n\t\t\t}\n", out.Name, out.Name)
+			}
+		}
+		fmt.Fprintf(w, "\t\t})\n\t}\n}\n")
+	}
+
+	return o.format(w.Bytes())
+}
+
+// Returns the data of a sample in of the message as go string literal
+func sampleData(m *jsonmsg.Message) (string, error) {
+	inst, err := m.NewInstance()
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(inst.(map[string]interface{})["data"])
+	if err != nil {
+		return "", err
+	}
+	if bytes.ContainsAny(b, "`\r") {
+		return strconv.Quote(string(b)), nil
+	}
+	return "`" + string(b) + "`", nil
+}
+
+// Returns the assignment operator for err, which is declared already if it was
+func declare(declared bool) string {
+	if declared {
+		return "="
+	}
+	return ":="
+}
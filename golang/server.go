@@ -2,89 +2,468 @@ package golang
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"go/ast"
 	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
 	"html/template"
+	"net/textproto"
+	"reflect"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/tfkhsr/jsonmsg"
 	"github.com/tfkhsr/jsonschema"
 	"github.com/tfkhsr/jsonschema/golang"
 )
 
-// Generates go src for a server from a jsonmsg.Spec without imports and package
-func ServerSrc(s *jsonmsg.Spec) ([]byte, error) {
-	ifc, err := generateInterfaceType(s)
-	if err != nil {
-		return nil, err
+// Options configure the generation of server sources
+type Options struct {
+	// Pass a *Conn holding session values as first parameter to all API methods.
+	// A Conn is unique to a websocket connection, HTTP requests get a fresh Conn each.
+	Sessions bool
+
+	// Pass a context.Context as first parameter to all API methods, cancelled when APIOptions.RequestTimeout expires
+	// or the request is gone, so methods can stop work that is no longer answered.
+	Context bool
+
+	// Path of the JSON spec route, defaults to {http path}/spec.json. "-" disables the route.
+	SpecJSONPath string
+
+	// Path of the HTML spec route, defaults to {http path}/spec. "-" disables the route.
+	SpecHTMLPath string
+
+	// Do not embed the spec into the generated sources, which disables the spec routes and the fetchSpec message
+	NoEmbeddedSpec bool
+
+	// Strip "$comment" annotations from the embedded spec, so internal notes are not served
+	StripComments bool
+
+	// Stream array outs over HTTP element by element with chunked encoding instead of encoding them at once
+	StreamArrays bool
+
+	// Register all routes under each version, replacing the last segment of the endpoint base path,
+	// e.g. with v1 and v2 the http endpoint /v1/http is served at /v1/http and /v2/http
+	Versions []string
+
+	// Generate a Client sending messages over HTTP next to the server
+	Client bool
+
+	// Serve a health check route at {base path}/health answering GET with status 200
+	Health bool
+
+	// Serve message counts and latencies at {base path}/metrics in Prometheus text format
+	Metrics bool
+
+	// Serve JSON-RPC 2.0 requests at {base path}/jsonrpc next to the http endpoint,
+	// whose method is the message name and params the message data
+	JSONRPC bool
+
+	// Generate MarshalJSON methods encoding the types without reflection, for services marshaling at high throughput.
+	// Decoding keeps encoding/json.
+	FastJSON bool
+
+	// Validate the string formats email, uri, uuid and ipv4, rejecting invalid inputs with status 422.
+	// Other formats are not validated.
+	ValidateFormats bool
+
+	// Report all failed validations of an input in one error, joined by "; ", instead of the first.
+	// Validate methods of the types still return their first failure, which is reported along with the others.
+	AllValidationErrors bool
+
+	// Status code answering outs an API method returns along with a non-nil error, e.g. 206 for partial data.
+	// Zero discards the outs and answers with status 500 like any other error, which is the default.
+	OutsOnErrorStatus int
+
+	// Formats the generated sources, defaults to go/format. NoFormat skips formatting.
+	Formatter func(src []byte) ([]byte, error)
+
+	// Modifies the AST of the generated package before formatting, e.g. adding build tags or methods.
+	// Applies to ServerPackageSrcWithOptions, as ServerSrcWithOptions lacks a package clause.
+	Transform func(fset *token.FileSet, f *ast.File) error
+}
+
+// Formatter returning the generated sources unformatted
+func NoFormat(src []byte) ([]byte, error) {
+	return src, nil
+}
+
+// Formats src with the configured formatter
+func (o Options) format(src []byte) ([]byte, error) {
+	if o.Formatter == nil {
+		return format.Source(src)
+	}
+	return o.Formatter(src)
+}
+
+// Parses the package sources, applies the configured transform and prints the modified AST
+func (o Options) transform(src []byte) ([]byte, error) {
+	if o.Transform == nil {
+		return src, nil
 	}
 
-	outs, err := generateOutTypes(s)
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
 	if err != nil {
 		return nil, err
 	}
-
-	hlp, err := generateHelper(s)
+	err = o.Transform(fset, f)
 	if err != nil {
 		return nil, err
 	}
 
-	httph, err := generateHTTPHandler(s)
+	w := &bytes.Buffer{}
+	err = printer.Fprint(w, fset, f)
 	if err != nil {
 		return nil, err
 	}
+	return w.Bytes(), nil
+}
+
+func init() {
+	jsonmsg.RegisterGenerator("go-server", ServerPackageSrc)
+}
+
+// Endpoint protocols served by generated handlers
+var serverProtocols = []string{"http", "websocket"}
+
+// Checks that a handler is generated for every endpoint of the spec, so no endpoint goes silently unserved
+func checkProtocols(s *jsonmsg.Spec) error {
+	var keys []string
+	for k, _ := range s.Endpoints {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !stringsContain(serverProtocols, k) {
+			return fmt.Errorf("golang: endpoint protocol %q is not supported by generated servers, supported protocols are %v", k, strings.Join(serverProtocols, ", "))
+		}
+	}
+	return nil
+}
+
+// Built-in message answered with the embedded spec while the JSON spec route is enabled
+const fetchSpecMessage = "fetchSpec"
+
+// template data of server sources
+type serverData struct {
+	*jsonmsg.Spec
+	Options Options
+}
+
+// Returns the path of the JSON spec route or an empty string if disabled
+func (d serverData) SpecJSONRoute() string {
+	return specRoute(d.Spec, d.Options, d.Options.SpecJSONPath, "/spec.json")
+}
+
+// Returns the path of the HTML spec route or an empty string if disabled
+func (d serverData) SpecHTMLRoute() string {
+	return specRoute(d.Spec, d.Options, d.Options.SpecHTMLPath, "/spec")
+}
+
+// Returns the path of the health check route or an empty string if disabled
+func (d serverData) HealthRoute() string {
+	if !d.Options.Health {
+		return ""
+	}
+	for _, p := range []string{"http", "websocket"} {
+		if e, ok := d.Endpoints[p]; ok {
+			return strings.TrimSuffix(e.EscapedPath(), "/"+p) + "/health"
+		}
+	}
+	return ""
+}
+
+// Returns the path of the JSON-RPC route next to the http endpoint or an empty string if disabled
+func (d serverData) JSONRPCRoute() string {
+	e, ok := d.Endpoints["http"]
+	if !d.Options.JSONRPC || !ok {
+		return ""
+	}
+	return strings.TrimSuffix(e.EscapedPath(), "/http") + "/jsonrpc"
+}
+
+// Returns the path of the metrics route or an empty string if disabled
+func (d serverData) MetricsRoute() string {
+	if !d.Options.Metrics {
+		return ""
+	}
+	for _, p := range []string{"http", "websocket"} {
+		if e, ok := d.Endpoints[p]; ok {
+			return strings.TrimSuffix(e.EscapedPath(), "/"+p) + "/metrics"
+		}
+	}
+	return ""
+}
+
+// Reports whether responses of the http endpoint carry static headers of messages
+func (d serverData) MessageHeaders() bool {
+	if _, ok := d.Endpoints["http"]; !ok {
+		return false
+	}
+	for _, m := range d.Messages {
+		if len(m.Headers) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether the http endpoint accepts multipart forms of form messages
+func (d serverData) FormMessages() bool {
+	if _, ok := d.Endpoints["http"]; !ok {
+		return false
+	}
+	for _, m := range d.Messages {
+		if m.Form {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether any message answers with a batch of all its non-nil outs
+func (d serverData) BatchMessages() bool {
+	for _, m := range d.Messages {
+		if m.Batch && len(m.OutSchemas) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a configured spec route path, "" if disabled or the default path next to the http endpoint
+func specRoute(s *jsonmsg.Spec, o Options, path string, def string) string {
+	if o.NoEmbeddedSpec {
+		return ""
+	}
+	switch path {
+	case "-":
+		return ""
+	case "":
+		e, ok := s.Endpoints["http"]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSuffix(e.EscapedPath(), "/http") + def
+	}
+	return path
+}
+
+// Generates go src for a server from a jsonmsg.Spec without imports and package
+func ServerSrc(s *jsonmsg.Spec) ([]byte, error) {
+	return ServerSrcWithOptions(s, Options{})
+}
+
+// Generates go src for a server from a jsonmsg.Spec and Options without imports and package
+func ServerSrcWithOptions(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	return serverSrc(s, o, true)
+}
+
+// Generates the server sources, the independent sections concurrently if parallel.
+// The sources are identical either way.
+func serverSrc(s *jsonmsg.Spec, o Options, parallel bool) ([]byte, error) {
+	if _, ok := s.Messages[fetchSpecMessage]; ok && (serverData{s, o}).SpecJSONRoute() != "" {
+		return nil, fmt.Errorf("golang: message %q collides with the built-in message returning the spec, rename it or disable the spec routes", fetchSpecMessage)
+	}
 
-	idx, err := jsonschema.Parse(s.Raw)
+	err := checkProtocols(s)
 	if err != nil {
 		return nil, err
 	}
 
-	typ, err := golang.Src(idx)
+	err = checkTypeNames(s, o)
 	if err != nil {
 		return nil, err
 	}
 
-	espc, err := generateEmbeddedJSONSpec(s)
+	err = checkOutNames(s)
 	if err != nil {
 		return nil, err
 	}
 
-	ehspc, err := generateEmbeddedHTMLSpec(s)
+	d := serverData{s, o}
+	if h := d.HealthRoute(); h != "" && (h == d.SpecJSONRoute() || h == d.SpecHTMLRoute()) {
+		return nil, fmt.Errorf("golang: health check route %v collides with a spec route", h)
+	}
+	if m := d.MetricsRoute(); m != "" && (m == d.SpecJSONRoute() || m == d.SpecHTMLRoute()) {
+		return nil, fmt.Errorf("golang: metrics route %v collides with a spec route", m)
+	}
+	if _, ok := s.Endpoints["http"]; o.JSONRPC && !ok {
+		return nil, fmt.Errorf("golang: JSON-RPC route requires an http endpoint")
+	}
+	if j := d.JSONRPCRoute(); j != "" && (j == d.SpecJSONRoute() || j == d.SpecHTMLRoute()) {
+		return nil, fmt.Errorf("golang: JSON-RPC route %v collides with a spec route", j)
+	}
+	if c := o.OutsOnErrorStatus; c != 0 && (c < 200 || c > 599) {
+		return nil, fmt.Errorf("golang: status %v of outs on error is not a HTTP status code of a response", c)
+	}
+
+	// independent sections in order of the sources
+	sections := []func() ([]byte, error){
+		func() ([]byte, error) { return generateInterfaceType(s, o) },
+		func() ([]byte, error) { return generateOutTypes(s) },
+		func() ([]byte, error) { return generateInTypes(s, o) },
+		func() ([]byte, error) { return generateHelper(s) },
+		func() ([]byte, error) { return generateConn(o) },
+		func() ([]byte, error) { return generateHub(s) },
+		func() ([]byte, error) { return generateStreamers(s, o) },
+		func() ([]byte, error) { return generateVersionedRouter(s, o) },
+		func() ([]byte, error) { return generateMetrics(o) },
+		func() ([]byte, error) { return generateHTTPHandler(s, o) },
+		func() ([]byte, error) { return generateClient(s, o) },
+		func() ([]byte, error) { return generateTypes(s, o) },
+		func() ([]byte, error) { return generateStringers(s) },
+		func() ([]byte, error) { return generateTuples(s) },
+		func() ([]byte, error) { return generateValueValidators(s, o) },
+		func() ([]byte, error) { return generateAccessModifiers(s, o) },
+		func() ([]byte, error) { return generatePresenceValidators(s, o) },
+		func() ([]byte, error) { return generateConstructors(s) },
+		func() ([]byte, error) { return generateEmbeddedJSONSpec(s, o) },
+		func() ([]byte, error) { return generateEmbeddedHTMLSpec(s, o) },
+	}
+	srcs, err := generateSections(sections, parallel)
 	if err != nil {
 		return nil, err
 	}
 
 	w := &bytes.Buffer{}
-	fmt.Fprintf(w, "%s", ifc)
-	fmt.Fprintf(w, "%s", outs)
-	fmt.Fprintf(w, "%s", hlp)
-	fmt.Fprintf(w, "%s", httph)
-	fmt.Fprintf(w, "%s", typ)
-	fmt.Fprintf(w, "%s", espc)
-	fmt.Fprintf(w, "%s", ehspc)
+	for _, src := range srcs {
+		w.Write(src)
+	}
 
-	return format.Source(w.Bytes())
+	return o.format(w.Bytes())
+}
+
+// Runs the generators of sections, concurrently if parallel, and returns their sources in order.
+// The error of the first failing section in order is returned, so errors are deterministic as well.
+// A panicking generator fails its section instead of the caller.
+func generateSections(sections []func() ([]byte, error), parallel bool) ([][]byte, error) {
+	srcs := make([][]byte, len(sections))
+	errs := make([]error, len(sections))
+	if parallel {
+		var wg sync.WaitGroup
+		for i, gen := range sections {
+			wg.Add(1)
+			go func(i int, gen func() ([]byte, error)) {
+				defer wg.Done()
+				srcs[i], errs[i] = generateSection(i, gen)
+			}(i, gen)
+		}
+		wg.Wait()
+	} else {
+		for i, gen := range sections {
+			srcs[i], errs[i] = generateSection(i, gen)
+			if errs[i] != nil {
+				break
+			}
+		}
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return srcs, nil
+}
+
+// Runs the generator of section i, recovering a panic as error
+func generateSection(i int, gen func() ([]byte, error)) (src []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			src, err = nil, fmt.Errorf("golang: section %d: %v", i, r)
+		}
+	}()
+	return gen()
+}
+
+// Generates the types of all definitions with overridden field names and deprecations,
+// followed by their reflection-free marshalers if enabled
+func generateTypes(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	typ, err := golang.Src(goTypesIndex(s))
+	if err != nil {
+		return nil, err
+	}
+	typ, err = renameFields(s, typ)
+	if err != nil {
+		return nil, err
+	}
+	typ, err = presentFields(s, typ)
+	if err != nil {
+		return nil, err
+	}
+	typ = deprecateTypes(s, typ)
+	if !o.FastJSON {
+		return typ, nil
+	}
+
+	// tuples encode as arrays with their own MarshalJSON
+	tuples := make(map[string]bool)
+	for k := range s.Tuples {
+		tuples[s.Definitions[k].Name] = true
+	}
+	m, err := generateMarshalers(typ, tuples)
+	if err != nil {
+		return nil, err
+	}
+	return append(typ, m...), nil
 }
 
 // Generates go src for a server from a jsonmsg.Spec as a complete package with imports
 func ServerPackageSrc(s *jsonmsg.Spec, pack string) ([]byte, error) {
-	src, err := ServerSrc(s)
+	return ServerPackageSrcWithOptions(s, pack, Options{})
+}
+
+// Generates go src for a server from a jsonmsg.Spec and Options as a complete package with imports
+func ServerPackageSrcWithOptions(s *jsonmsg.Spec, pack string, o Options) ([]byte, error) {
+	src, err := ServerSrcWithOptions(s, o)
 	if err != nil {
 		return nil, err
 	}
 
 	w := &bytes.Buffer{}
-	fmt.Fprintf(w, `package %v
+	fmt.Fprintf(w, `// %v
+
+package %v
 
 import (
-`, pack)
+`, jsonmsg.GeneratedStamp(s), pack)
+	// stdlib and third-party imports in separate groups
+	var std, ext []string
 	for _, i := range ServerImports(src) {
+		if strings.Contains(strings.Split(i, "/")[0], ".") {
+			ext = append(ext, i)
+		} else {
+			std = append(std, i)
+		}
+	}
+	for _, i := range std {
+		fmt.Fprintf(w, "\t\"%s\"\n", i)
+	}
+	if len(std) > 0 && len(ext) > 0 {
+		fmt.Fprintln(w, "")
+	}
+	for _, i := range ext {
 		fmt.Fprintf(w, "\t\"%s\"\n", i)
 	}
 	fmt.Fprintf(w, ")\n%s", src)
 
-	return format.Source(w.Bytes())
+	src, err = o.transform(w.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return o.format(src)
 }
 
 // Returns a list of required imports
@@ -95,7 +474,7 @@ func ServerImports(src []byte) []string {
 	)
 }
 
-func generateInterfaceType(s *jsonmsg.Spec) ([]byte, error) {
+func generateInterfaceType(s *jsonmsg.Spec, o Options) ([]byte, error) {
 	w := bytes.NewBufferString("\n")
 	fmt.Fprintf(w, "type API interface {\n")
 	var keys []string
@@ -105,18 +484,23 @@ func generateInterfaceType(s *jsonmsg.Spec) ([]byte, error) {
 	sort.Strings(keys)
 	for _, k := range keys {
 		m := s.Messages[k]
-		if m.InSchema != nil {
-			if len(m.OutSchemas) > 0 {
-				fmt.Fprintf(w, "\t%s(*%s) (*%vOuts, error)\n", m.Name, m.InSchema.Name, m.Name)
-			} else {
-				fmt.Fprintf(w, "\t%s(*%s) error\n", m.Name, m.InSchema.Name)
-			}
+		var params []string
+		if o.Context {
+			params = append(params, "context.Context")
+		}
+		if o.Sessions {
+			params = append(params, "*Conn")
+		}
+		if t := inType(m); t != "" {
+			params = append(params, "*"+t)
+		}
+		if m.Deprecated {
+			fmt.Fprintf(w, "\t// Deprecated: the %v message is deprecated.\n", m.Msg)
+		}
+		if len(m.OutSchemas) > 0 {
+			fmt.Fprintf(w, "\t%s(%s) (*%vOuts, error)\n", m.Name, strings.Join(params, ", "), m.Name)
 		} else {
-			if len(m.OutSchemas) > 0 {
-				fmt.Fprintf(w, "\t%s() (*%vOuts, error)\n", m.Name, m.Name)
-			} else {
-				fmt.Fprintf(w, "\t%s() error\n", m.Name)
-			}
+			fmt.Fprintf(w, "\t%s(%s) error\n", m.Name, strings.Join(params, ", "))
 		}
 	}
 	fmt.Fprintf(w, "}\n")
@@ -144,80 +528,2077 @@ func generateOutTypes(s *jsonmsg.Spec) ([]byte, error) {
 	return format.Source(w.Bytes())
 }
 
-// Generates an HTTP and HTTPS handler
-func generateHTTPHandler(s *jsonmsg.Spec) ([]byte, error) {
-	tmpl, err := template.New("handler").Funcs(template.FuncMap{
-		"Contains": stringsContain,
-		"SubstringRight": func(a string, n int) string {
-			return a[0 : len(a)-n]
-		},
-	}).Parse(httpHandlerTemplate)
+// Returns the go type of the data of a message, a wrapper type for alternative in schemas, "" for messages without in
+func inType(m *jsonmsg.Message) string {
+	if m.InSchema != nil {
+		return m.InSchema.Name
+	}
+	if len(m.InSchemas) > 0 {
+		return m.Name + "In"
+	}
+	return ""
+}
+
+// Generates wrapper types of messages with alternative in schemas, holding the first alternative
+// the data is valid for
+func generateInTypes(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	checks, err := valueChecks(s, o)
 	if err != nil {
 		return nil, err
 	}
+	validated := valueValidated(s, checks)
+	readOnly := accessRestricted(s, s.ReadOnly)
+	present := accessRestricted(s, requiredNullable(s))
 
 	w := bytes.NewBufferString("\n")
-	err = tmpl.Execute(w, s)
-	if err != nil {
-		return nil, err
+	var keys []string
+	for k, _ := range s.Messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m := s.Messages[k]
+		if len(m.InSchemas) == 0 {
+			continue
+		}
+		t := inType(m)
+
+		var names []string
+		for _, in := range m.InSchemas {
+			names = append(names, in.JSONName)
+		}
+		none := fmt.Sprintf("invalid in of %v: data matches none of %v", m.Msg, strings.Join(names, ", "))
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "// %v holds one of the alternative in schemas of %v\n", t, m.Msg)
+		fmt.Fprintf(w, "type %v struct {\n", t)
+		for _, in := range m.InSchemas {
+			fmt.Fprintf(w, "\t%v *%v\n", in.Name, in.Name)
+		}
+		fmt.Fprintf(w, "}\n")
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "// Unmarshals the first alternative the data is valid for, Validate fails if there is none\n")
+		fmt.Fprintf(w, "func (t *%v) UnmarshalJSON(b []byte) error {\n", t)
+		fmt.Fprintf(w, "\t*t = %v{}\n", t)
+		for _, in := range m.InSchemas {
+			fmt.Fprintf(w, "\t{\n")
+			fmt.Fprintf(w, "\t\tv := &%v{}\n", in.Name)
+			fmt.Fprintf(w, "\t\tif json.Unmarshal(b, v) == nil && v.Validate() == nil {\n")
+			fmt.Fprintf(w, "\t\t\tt.%v = v\n", in.Name)
+			fmt.Fprintf(w, "\t\t\treturn nil\n")
+			fmt.Fprintf(w, "\t\t}\n")
+			fmt.Fprintf(w, "\t}\n")
+		}
+		fmt.Fprintf(w, "\treturn nil\n")
+		fmt.Fprintf(w, "}\n")
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "// Marshals the set alternative\n")
+		fmt.Fprintf(w, "func (t %v) MarshalJSON() ([]byte, error) {\n", t)
+		fmt.Fprintf(w, "\tswitch {\n")
+		for _, in := range m.InSchemas {
+			fmt.Fprintf(w, "\tcase t.%v != nil:\n", in.Name)
+			fmt.Fprintf(w, "\t\treturn json.Marshal(t.%v)\n", in.Name)
+		}
+		fmt.Fprintf(w, "\t}\n")
+		fmt.Fprintf(w, "\treturn []byte(\"null\"), nil\n")
+		fmt.Fprintf(w, "}\n")
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "// Validates the set alternative\n")
+		fmt.Fprintf(w, "func (t *%v) Validate() error {\n", t)
+		fmt.Fprintf(w, "\tswitch {\n")
+		for _, in := range m.InSchemas {
+			fmt.Fprintf(w, "\tcase t.%v != nil:\n", in.Name)
+			k, _ := definitionKey(s, in)
+			if !validated[k] && !readOnly[k] && !present[k] {
+				fmt.Fprintf(w, "\t\treturn t.%v.Validate()\n", in.Name)
+				continue
+			}
+			fmt.Fprintf(w, "\t\tif err := t.%v.Validate(); err != nil {\n", in.Name)
+			fmt.Fprintf(w, "\t\t\treturn err\n")
+			fmt.Fprintf(w, "\t\t}\n")
+			if validated[k] {
+				fmt.Fprintf(w, "\t\tif err := t.%v.validateValues(); err != nil {\n", in.Name)
+				fmt.Fprintf(w, "\t\t\treturn err\n")
+				fmt.Fprintf(w, "\t\t}\n")
+			}
+			if readOnly[k] {
+				fmt.Fprintf(w, "\t\tif err := t.%v.validateReadOnly(); err != nil {\n", in.Name)
+				fmt.Fprintf(w, "\t\t\treturn err\n")
+				fmt.Fprintf(w, "\t\t}\n")
+			}
+			if present[k] {
+				fmt.Fprintf(w, "\t\tif err := t.%v.validatePresence(); err != nil {\n", in.Name)
+				fmt.Fprintf(w, "\t\t\treturn err\n")
+				fmt.Fprintf(w, "\t\t}\n")
+			}
+			fmt.Fprintf(w, "\t\treturn nil\n")
+		}
+		fmt.Fprintf(w, "\t}\n")
+		fmt.Fprintf(w, "\treturn errors.New(%q)\n", none)
+		fmt.Fprintf(w, "}\n")
 	}
 
 	return format.Source(w.Bytes())
 }
 
-// Generates helper
-func generateHelper(s *jsonmsg.Spec) ([]byte, error) {
+// Generates validateValues methods checking const and enum properties of object definitions,
+// elements of arrays with const or enum items, the number of properties of free-form objects,
+// string formats if enabled and properties of referenced definitions
+func generateValueValidators(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	checks, err := valueChecks(s, o)
+	if err != nil {
+		return nil, err
+	}
+	validated := valueValidated(s, checks)
+
+	// formats checked by any definition
+	formats := make(map[string]bool)
+	fails := failures{o.AllValidationErrors}
+
 	w := bytes.NewBufferString("\n")
-	fmt.Fprintf(w, `
-// jsonmsg message schema
-type message struct {
-	Msg string 
-	Data json.RawMessage
-}
+	for _, k := range definitionKeys(s) {
+		if !validated[k] {
+			continue
+		}
+		d := s.Definitions[k]
 
-func newMessageReader(msg string, data interface{}) io.Reader {
-	b, err := json.Marshal(data)
-	if err != nil {
-		panic(err)
+		var keys []string
+		for p, _ := range d.Properties {
+			keys = append(keys, p)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "// validateValues checks const, enum, property count, format and conditional constraints of %v\n", d.Name)
+		fmt.Fprintf(w, "func (t *%v) validateValues() error {\n", d.Name)
+		w.WriteString(fails.declare())
+		for _, p := range keys {
+			ps := d.Properties[p]
+			f := goFieldName(s, k, p)
+			for _, c := range checks[k+"/properties/"+p] {
+				if c.Counts != nil {
+					if c.Counts.Min >= 0 {
+						fmt.Fprintf(w, "\tif t.%v != nil && len(t.%v) < %v {\n", f, f, c.Counts.Min)
+						fmt.Fprintf(w, "\t\t%v\n", fails.fail(fmt.Sprintf("invalid %v: %v must have at least %v properties", d.JSONName, p, c.Counts.Min)))
+						fmt.Fprintf(w, "\t}\n")
+					}
+					if c.Counts.Max >= 0 {
+						fmt.Fprintf(w, "\tif len(t.%v) > %v {\n", f, c.Counts.Max)
+						fmt.Fprintf(w, "\t\t%v\n", fails.fail(fmt.Sprintf("invalid %v: %v must have at most %v properties", d.JSONName, p, c.Counts.Max)))
+						fmt.Fprintf(w, "\t}\n")
+					}
+					continue
+				}
+				if c.Format != "" {
+					formats[c.Format] = true
+					valid := stringFormats[c.Format].Func
+					if c.Items {
+						fmt.Fprintf(w, "\tfor _, e := range t.%v {\n", f)
+						fmt.Fprintf(w, "\t\tif !%v(e) {\n", valid)
+						fmt.Fprintf(w, "\t\t\t%v\n", fails.failItem(fmt.Sprintf("invalid %v: %v items must be formatted as %v", d.JSONName, p, c.Format)))
+						fmt.Fprintf(w, "\t\t}\n")
+						fmt.Fprintf(w, "\t}\n")
+						continue
+					}
+					fmt.Fprintf(w, "\tif t.%v != nil && !%v(*t.%v) {\n", f, valid, f)
+					fmt.Fprintf(w, "\t\t%v\n", fails.fail(fmt.Sprintf("invalid %v: %v must be formatted as %v", d.JSONName, p, c.Format)))
+					fmt.Fprintf(w, "\t}\n")
+					continue
+				}
+				if c.Items {
+					fmt.Fprintf(w, "\tfor _, e := range t.%v {\n", f)
+					fmt.Fprintf(w, "\t\tif e != %v {\n", strings.Join(c.Literals, " && e != "))
+					fmt.Fprintf(w, "\t\t\t%v\n", fails.failItem(fmt.Sprintf("invalid %v: %v items %v", d.JSONName, p, c.must())))
+					fmt.Fprintf(w, "\t\t}\n")
+					fmt.Fprintf(w, "\t}\n")
+					continue
+				}
+				fmt.Fprintf(w, "\tif t.%v != nil && *t.%v != %v {\n", f, f, strings.Join(c.Literals, " && *t."+f+" != "))
+				fmt.Fprintf(w, "\t\t%v\n", fails.fail(fmt.Sprintf("invalid %v: %v %v", d.JSONName, p, c.must())))
+				fmt.Fprintf(w, "\t}\n")
+			}
+			if ps.Type == "ref" && validated[ps.Ref] {
+				fmt.Fprintf(w, "\tif t.%v != nil {\n", f)
+				fmt.Fprintf(w, "\t\tif err := t.%v.validateValues(); err != nil {\n", f)
+				fmt.Fprintf(w, "\t\t\t%v\n", fails.nested())
+				fmt.Fprintf(w, "\t\t}\n")
+				fmt.Fprintf(w, "\t}\n")
+			}
+		}
+		for _, c := range checks[k] {
+			cc := c.Conditional
+			if len(cc.Then) == 0 && len(cc.Else) == 0 {
+				continue
+			}
+			if len(cc.Then) > 0 {
+				fmt.Fprintf(w, "\tif %v {\n", cc.Cond)
+			} else {
+				fmt.Fprintf(w, "\tif !(%v) {\n", cc.Cond)
+			}
+			for _, p := range cc.Then {
+				fmt.Fprintf(w, "\t\tif t.%v == nil {\n", goFieldName(s, k, p))
+				fmt.Fprintf(w, "\t\t\t%v\n", fails.fail(fmt.Sprintf("invalid %v: %v is required if %v", d.JSONName, p, cc.Desc)))
+				fmt.Fprintf(w, "\t\t}\n")
+			}
+			if len(cc.Then) > 0 && len(cc.Else) > 0 {
+				fmt.Fprintf(w, "\t} else {\n")
+			}
+			for _, p := range cc.Else {
+				fmt.Fprintf(w, "\t\tif t.%v == nil {\n", goFieldName(s, k, p))
+				fmt.Fprintf(w, "\t\t\t%v\n", fails.fail(fmt.Sprintf("invalid %v: %v is required unless %v", d.JSONName, p, cc.Desc)))
+				fmt.Fprintf(w, "\t\t}\n")
+			}
+			fmt.Fprintf(w, "\t}\n")
+		}
+		fmt.Fprintf(w, "\t%v\n", fails.ret())
+		fmt.Fprintf(w, "}\n")
 	}
-	m := &message{msg, b}
-	raw, err := json.Marshal(m)
-	if err != nil {
-		panic(err)
+
+	// checks of the used formats
+	var names []string
+	for f, _ := range formats {
+		names = append(names, f)
 	}
-	return bytes.NewBuffer(raw)
+	sort.Strings(names)
+	for _, f := range names {
+		w.WriteString(stringFormats[f].Src)
+	}
+	if o.AllValidationErrors {
+		w.WriteString(validationErrorsSrc)
+	}
+
+	return format.Source(w.Bytes())
 }
 
-type outMessage struct {
-	Msg string
-	Data interface{}
+// Emits the failing of generated validation checks, which return the first failure
+// or collect all failures with Options.AllValidationErrors
+type failures struct {
+	all bool
 }
 
-func newValueMessage(msg string, data interface{}) outMessage {
-	return outMessage{
-		Msg: msg,
-		Data: data,
+// declares the collected failures at the start of a validation method
+func (f failures) declare() string {
+	if f.all {
+		return "\tvar errs validationErrors\n"
 	}
+	return ""
 }
 
-type errorData struct {
-	Error string
+// fails a check with msg
+func (f failures) fail(msg string) string {
+	if f.all {
+		return fmt.Sprintf("errs = append(errs, %q)", msg)
+	}
+	return fmt.Sprintf("return errors.New(%q)", msg)
 }
 
-func newErrorMessage(e string) outMessage {
-	return outMessage{
-		Msg: "error",
-		Data: errorData{e},
+// fails a check of array items with msg, once per array
+func (f failures) failItem(msg string) string {
+	if f.all {
+		return fmt.Sprintf("errs = append(errs, %q)\nbreak", msg)
 	}
+	return f.fail(msg)
 }
 
-var (
+// fails with err of a nested validation
+func (f failures) nested() string {
+	if f.all {
+		return "errs = errs.add(err)"
+	}
+	return "return err"
+}
+
+// ends a validation method
+func (f failures) ret() string {
+	if f.all {
+		return "return errs.err()"
+	}
+	return "return nil"
+}
+
+// collected failures of validations with Options.AllValidationErrors
+const validationErrorsSrc = `
+// failures of all validations of an input, reported together as one error
+type validationErrors []string
+
+func (e validationErrors) Error() string {
+	return strings.Join(e, "; ")
+}
+
+// adds the failures of err, which may be nil or validationErrors itself
+func (e validationErrors) add(err error) validationErrors {
+	if err == nil {
+		return e
+	}
+	if errs, ok := err.(validationErrors); ok {
+		return append(e, errs...)
+	}
+	return append(e, err.Error())
+}
+
+// returns the failures as error, nil if there are none
+func (e validationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+`
+
+// Checks of string formats validated with Options.ValidateFormats, other formats are not validated
+var stringFormats = map[string]struct {
+	// name of the generated func reporting whether a string is valid
+	Func string
+	Src  string
+}{
+	"email": {"validEmailFormat", `
+var emailFormat = regexp.MustCompile(` + "`" + `^[^@\s]+@[^@\s]+\.[^@\s]+$` + "`" + `)
+
+// reports whether s is an email address, checking only its rough shape
+func validEmailFormat(s string) bool {
+	return emailFormat.MatchString(s)
+}
+`},
+	"uri": {"validURIFormat", `
+// reports whether s is an absolute URI
+func validURIFormat(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != ""
+}
+`},
+	"uuid": {"validUUIDFormat", `
+var uuidFormat = regexp.MustCompile(` + "`" + `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$` + "`" + `)
+
+// reports whether s is a UUID in its hyphenated form
+func validUUIDFormat(s string) bool {
+	return uuidFormat.MatchString(s)
+}
+`},
+	"ipv4": {"validIPv4Format", `
+// reports whether s is an IPv4 address in dotted decimal notation
+func validIPv4Format(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+}
+`},
+}
+
+// Generates validateReadOnly methods rejecting readOnly properties in inputs and
+// withoutWriteOnly methods returning copies without writeOnly properties for outputs.
+// Both cover properties of referenced definitions.
+func generateAccessModifiers(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	readOnly := accessRestricted(s, s.ReadOnly)
+	writeOnly := accessRestricted(s, s.WriteOnly)
+	fails := failures{o.AllValidationErrors}
+
+	w := bytes.NewBufferString("\n")
+	for _, k := range definitionKeys(s) {
+		d := s.Definitions[k]
+
+		var keys []string
+		for p, _ := range d.Properties {
+			keys = append(keys, p)
+		}
+		sort.Strings(keys)
+
+		if readOnly[k] {
+			fmt.Fprintln(w, "")
+			fmt.Fprintf(w, "// validateReadOnly rejects readOnly properties of %v\n", d.Name)
+			fmt.Fprintf(w, "func (t *%v) validateReadOnly() error {\n", d.Name)
+			w.WriteString(fails.declare())
+			for _, p := range keys {
+				f := goFieldName(s, k, p)
+				if s.ReadOnly[k+"/properties/"+p] {
+					fmt.Fprintf(w, "\tif t.%v != nil {\n", f)
+					fmt.Fprintf(w, "\t\t%v\n", fails.fail(fmt.Sprintf("invalid %v: %v is read-only", d.JSONName, p)))
+					fmt.Fprintf(w, "\t}\n")
+				}
+				if ps := d.Properties[p]; ps.Type == "ref" && readOnly[ps.Ref] {
+					fmt.Fprintf(w, "\tif t.%v != nil {\n", f)
+					fmt.Fprintf(w, "\t\tif err := t.%v.validateReadOnly(); err != nil {\n", f)
+					fmt.Fprintf(w, "\t\t\t%v\n", fails.nested())
+					fmt.Fprintf(w, "\t\t}\n")
+					fmt.Fprintf(w, "\t}\n")
+				}
+			}
+			fmt.Fprintf(w, "\t%v\n", fails.ret())
+			fmt.Fprintf(w, "}\n")
+		}
+
+		if writeOnly[k] {
+			fmt.Fprintln(w, "")
+			fmt.Fprintf(w, "// withoutWriteOnly returns a copy of %v without writeOnly properties\n", d.Name)
+			fmt.Fprintf(w, "func (t *%v) withoutWriteOnly() interface{} {\n", d.Name)
+			fmt.Fprintf(w, "\tif t == nil {\n")
+			fmt.Fprintf(w, "\t\treturn t\n")
+			fmt.Fprintf(w, "\t}\n")
+			fmt.Fprintf(w, "\tc := *t\n")
+			for _, p := range keys {
+				f := goFieldName(s, k, p)
+				if s.WriteOnly[k+"/properties/"+p] {
+					fmt.Fprintf(w, "\tc.%v = nil\n", f)
+				}
+				if ps := d.Properties[p]; ps.Type == "ref" && writeOnly[ps.Ref] {
+					fmt.Fprintf(w, "\tc.%v = t.%v.withoutWriteOnly().(*%v)\n", f, f, s.Definitions[ps.Ref].Name)
+				}
+			}
+			fmt.Fprintf(w, "\treturn &c\n")
+			fmt.Fprintf(w, "}\n")
+		}
+	}
+
+	return format.Source(w.Bytes())
+}
+
+// Generates UnmarshalJSON methods recording which required nullable properties are present, even if null,
+// and validatePresence methods rejecting absent ones in inputs, also through referenced definitions
+func generatePresenceValidators(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	nullable := requiredNullable(s)
+	validated := accessRestricted(s, nullable)
+	fails := failures{o.AllValidationErrors}
+
+	w := bytes.NewBufferString("\n")
+	for _, k := range definitionKeys(s) {
+		if !validated[k] {
+			continue
+		}
+		d := s.Definitions[k]
+
+		var keys, present []string
+		for p, _ := range d.Properties {
+			keys = append(keys, p)
+		}
+		sort.Strings(keys)
+		for _, p := range keys {
+			if nullable[k+"/properties/"+p] {
+				present = append(present, p)
+			}
+		}
+
+		if len(present) > 0 {
+			fmt.Fprintln(w, "")
+			fmt.Fprintf(w, "// UnmarshalJSON decodes %v and records which of its required nullable properties are present\n", d.Name)
+			fmt.Fprintf(w, "func (t *%v) UnmarshalJSON(b []byte) error {\n", d.Name)
+			fmt.Fprintf(w, "\ttype fields %v\n", d.Name)
+			fmt.Fprintf(w, "\tvar v fields\n")
+			fmt.Fprintf(w, "\tif err := json.Unmarshal(b, &v); err != nil {\n")
+			fmt.Fprintf(w, "\t\treturn err\n")
+			fmt.Fprintf(w, "\t}\n")
+			fmt.Fprintf(w, "\tvar props map[string]json.RawMessage\n")
+			fmt.Fprintf(w, "\tif err := json.Unmarshal(b, &props); err != nil {\n")
+			fmt.Fprintf(w, "\t\treturn err\n")
+			fmt.Fprintf(w, "\t}\n")
+			fmt.Fprintf(w, "\t*t = %v(v)\n", d.Name)
+			fmt.Fprintf(w, "\tt.present = make(map[string]bool)\n")
+			fmt.Fprintf(w, "\tfor _, p := range %#v {\n", present)
+			fmt.Fprintf(w, "\t\tif _, ok := props[p]; ok {\n")
+			fmt.Fprintf(w, "\t\t\tt.present[p] = true\n")
+			fmt.Fprintf(w, "\t\t}\n")
+			fmt.Fprintf(w, "\t}\n")
+			fmt.Fprintf(w, "\treturn nil\n")
+			fmt.Fprintf(w, "}\n")
+		}
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "// validatePresence rejects absent required nullable properties of %v\n", d.Name)
+		fmt.Fprintf(w, "func (t *%v) validatePresence() error {\n", d.Name)
+		w.WriteString(fails.declare())
+		for _, p := range keys {
+			f := goFieldName(s, k, p)
+			if nullable[k+"/properties/"+p] {
+				fmt.Fprintf(w, "\tif t.%v == nil && !t.present[%q] {\n", f, p)
+				fmt.Fprintf(w, "\t\t%v\n", fails.fail(fmt.Sprintf("invalid %v: missing %v", d.JSONName, p)))
+				fmt.Fprintf(w, "\t}\n")
+			}
+			if ps := d.Properties[p]; ps.Type == "ref" && validated[ps.Ref] {
+				fmt.Fprintf(w, "\tif t.%v != nil {\n", f)
+				fmt.Fprintf(w, "\t\tif err := t.%v.validatePresence(); err != nil {\n", f)
+				fmt.Fprintf(w, "\t\t\t%v\n", fails.nested())
+				fmt.Fprintf(w, "\t\t}\n")
+				fmt.Fprintf(w, "\t}\n")
+			}
+		}
+		fmt.Fprintf(w, "\t%v\n", fails.ret())
+		fmt.Fprintf(w, "}\n")
+	}
+
+	return format.Source(w.Bytes())
+}
+
+// Returns the pointers of required nullable properties of object definitions, an explicit null decodes as nil
+// like an absent value, so their types record presence and inputs are validated by validatePresence
+func requiredNullable(s *jsonmsg.Spec) map[string]bool {
+	props := make(map[string]bool)
+	for _, k := range definitionKeys(s) {
+		d := s.Definitions[k]
+		if d.Type != "object" || s.Tuples[k] != nil {
+			continue
+		}
+		for _, r := range d.Required {
+			if s.Nullable[k+"/properties/"+r] {
+				props[k+"/properties/"+r] = true
+			}
+		}
+	}
+	return props
+}
+
+// Returns the top-level object definitions having any of the given properties directly or through referenced definitions
+func accessRestricted(s *jsonmsg.Spec, props map[string]bool) map[string]bool {
+	restricted := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, k := range definitionKeys(s) {
+			d := s.Definitions[k]
+			if restricted[k] || d.Type != "object" {
+				continue
+			}
+			for p, ps := range d.Properties {
+				if props[k+"/properties/"+p] || (ps.Type == "ref" && restricted[ps.Ref]) {
+					restricted[k] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return restricted
+}
+
+// A check of a property value (or of each element of an array property) against allowed go literals
+type valueCheck struct {
+	Literals []string
+	Items    bool
+
+	// bounds of the number of properties of a free-form object instead of literals
+	Counts *propertyCounts
+
+	// string format the value must be valid in instead of literals, e.g. email
+	Format string
+
+	// properties of a definition required depending on a conditional schema instead of literals,
+	// checks of definitions are keyed by the definition pointer
+	Conditional *conditionalCheck
+}
+
+// An if/then/else schema of a definition with the go condition of the if schema
+type conditionalCheck struct {
+	Cond string
+
+	// describes when the condition matches, e.g. kind is "card"
+	Desc string
+
+	// properties required if the condition matches and otherwise
+	Then []string
+	Else []string
+}
+
+// minProperties and maxProperties of a free-form object, -1 if not given
+type propertyCounts struct {
+	Min int
+	Max int
+}
+
+// describes the allowed values of a check
+func (c valueCheck) must() string {
+	if len(c.Literals) == 1 {
+		return "must be " + c.Literals[0]
+	}
+	return "must be one of " + strings.Join(c.Literals, ", ")
+}
+
+// Returns the value checks of all top-level definition properties by property pointer,
+// including checks of string formats with Options.ValidateFormats
+func valueChecks(s *jsonmsg.Spec, o Options) (map[string][]valueCheck, error) {
+	consts, err := s.Consts()
+	if err != nil {
+		return nil, err
+	}
+	enums, err := s.Enums()
+	if err != nil {
+		return nil, err
+	}
+	mins, err := s.MinProperties()
+	if err != nil {
+		return nil, err
+	}
+	maxs, err := s.MaxProperties()
+	if err != nil {
+		return nil, err
+	}
+	formats := make(map[string]string)
+	if o.ValidateFormats {
+		formats, err = s.Formats()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	checks := make(map[string][]valueCheck)
+	for _, k := range definitionKeys(s) {
+		d := s.Definitions[k]
+		if d.Type != "object" {
+			continue
+		}
+		for p, ps := range d.Properties {
+			pp := k + "/properties/" + p
+			var cs []valueCheck
+			if lits, ok := valueLiterals(ps, []interface{}{consts[pp]}); ok {
+				cs = append(cs, valueCheck{Literals: lits})
+			}
+			if lits, ok := valueLiterals(ps, enums[pp]); ok {
+				cs = append(cs, valueCheck{Literals: lits})
+			}
+			if ps.Type == "array" && ps.Items != nil {
+				if lits, ok := valueLiterals(ps.Items, []interface{}{consts[pp+"/items"]}); ok {
+					cs = append(cs, valueCheck{Literals: lits, Items: true})
+				}
+				if lits, ok := valueLiterals(ps.Items, enums[pp+"/items"]); ok {
+					cs = append(cs, valueCheck{Literals: lits, Items: true})
+				}
+				if _, ok := stringFormats[formats[pp+"/items"]]; ok && ps.Items.Type == "string" {
+					cs = append(cs, valueCheck{Items: true, Format: formats[pp+"/items"]})
+				}
+			}
+			if _, ok := stringFormats[formats[pp]]; ok && ps.Type == "string" {
+				cs = append(cs, valueCheck{Format: formats[pp]})
+			}
+			// free-form objects are generated as maps
+			if ps.Type == "object" && len(ps.Properties) == 0 {
+				counts := &propertyCounts{-1, -1}
+				if n, ok := mins[pp]; ok {
+					counts.Min = n
+				}
+				if n, ok := maxs[pp]; ok {
+					counts.Max = n
+				}
+				if counts.Min >= 0 || counts.Max >= 0 {
+					cs = append(cs, valueCheck{Counts: counts})
+				}
+			}
+			if len(cs) > 0 {
+				checks[pp] = cs
+			}
+		}
+	}
+
+	conds, err := s.Conditionals()
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range definitionKeys(s) {
+		c, ok := conds[k]
+		if !ok || s.Definitions[k].Type != "object" {
+			continue
+		}
+		cc, err := conditionalChecks(s, k, c)
+		if err != nil {
+			return nil, err
+		}
+		checks[k] = []valueCheck{{Conditional: cc}}
+	}
+	return checks, nil
+}
+
+// Returns the check of a conditional schema of the object definition k
+func conditionalChecks(s *jsonmsg.Spec, k string, c *jsonmsg.Conditional) (*conditionalCheck, error) {
+	d := s.Definitions[k]
+	required := make(map[string]bool)
+	for _, p := range c.IfRequired {
+		required[p] = true
+	}
+	props := append([]string(nil), c.IfRequired...)
+	for p, _ := range c.If {
+		if !required[p] {
+			props = append(props, p)
+		}
+	}
+	sort.Strings(props)
+
+	var conds, descs []string
+	for _, p := range props {
+		ps, ok := d.Properties[p]
+		if !ok {
+			return nil, fmt.Errorf("golang: if of %v constrains %v, which is not a property", k, p)
+		}
+		f := goFieldName(s, k, p)
+		vs, ok := c.If[p]
+		if !ok {
+			conds = append(conds, fmt.Sprintf("t.%v != nil", f))
+			descs = append(descs, p+" is set")
+			continue
+		}
+		lits, ok := valueLiterals(ps, vs)
+		if !ok {
+			return nil, fmt.Errorf("golang: if of %v constrains %v by values not representable as %v", k, p, ps.Type)
+		}
+		eq := "*t." + f + " == " + strings.Join(lits, " || *t."+f+" == ")
+		if required[p] {
+			conds = append(conds, fmt.Sprintf("t.%v != nil && (%v)", f, eq))
+		} else {
+			conds = append(conds, fmt.Sprintf("(t.%v == nil || %v)", f, eq))
+		}
+		if len(lits) == 1 {
+			descs = append(descs, p+" is "+lits[0])
+		} else {
+			descs = append(descs, p+" is one of "+strings.Join(lits, ", "))
+		}
+	}
+	if len(conds) == 0 {
+		conds = []string{"true"}
+		descs = []string{"always"}
+	}
+
+	for _, p := range append(append([]string(nil), c.Then...), c.Else...) {
+		if _, ok := d.Properties[p]; !ok {
+			return nil, fmt.Errorf("golang: then or else of %v requires %v, which is not a property", k, p)
+		}
+	}
+	return &conditionalCheck{
+		Cond: strings.Join(conds, " && "),
+		Desc: strings.Join(descs, " and "),
+		Then: c.Then,
+		Else: c.Else,
+	}, nil
+}
+
+// Returns the top-level object definitions having value checks directly or through referenced definitions
+func valueValidated(s *jsonmsg.Spec, checks map[string][]valueCheck) map[string]bool {
+	validated := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, k := range definitionKeys(s) {
+			d := s.Definitions[k]
+			if validated[k] || d.Type != "object" {
+				continue
+			}
+			if len(checks[k]) > 0 {
+				validated[k] = true
+				changed = true
+				continue
+			}
+			for p, ps := range d.Properties {
+				if len(checks[k+"/properties/"+p]) > 0 || (ps.Type == "ref" && validated[ps.Ref]) {
+					validated[k] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return validated
+}
+
+// Returns the go literals of values for simple schema types, false if any value is not representable
+func valueLiterals(p *jsonschema.Schema, vs []interface{}) ([]string, bool) {
+	var lits []string
+	for _, c := range vs {
+		lit, ok := valueLiteral(p, c)
+		if !ok {
+			return nil, false
+		}
+		lits = append(lits, lit)
+	}
+	return lits, len(lits) > 0
+}
+
+// Returns the go literal of a value for simple schema types
+func valueLiteral(p *jsonschema.Schema, c interface{}) (string, bool) {
+	switch v := c.(type) {
+	case string:
+		if p.Type == "string" {
+			return fmt.Sprintf("%q", v), true
+		}
+	case float64:
+		if p.Type == "number" || (p.Type == "integer" && v == float64(int64(v))) {
+			return fmt.Sprintf("%v", v), true
+		}
+	case bool:
+		if p.Type == "boolean" {
+			return fmt.Sprintf("%v", v), true
+		}
+	}
+	return "", false
+}
+
+// Generates a Router registering routes under each version if versions are configured
+func generateVersionedRouter(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	if len(o.Versions) == 0 {
+		return nil, nil
+	}
+
+	// base path of the endpoints and its parent, whose last segment is replaced by the versions
+	base := ""
+	for _, p := range []string{"http", "websocket"} {
+		if e, ok := s.Endpoints[p]; ok {
+			base = strings.TrimSuffix(e.EscapedPath(), "/"+p)
+			break
+		}
+	}
+	parent := base
+	if i := strings.LastIndex(base, "/"); i >= 0 {
+		parent = base[:i]
+	}
+
+	var prefixes []string
+	seen := make(map[string]bool)
+	for _, v := range o.Versions {
+		v = strings.Trim(v, "/")
+		if v == "" || strings.Contains(v, "/") {
+			return nil, fmt.Errorf("golang: version %q must be a single path segment", v)
+		}
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		prefixes = append(prefixes, fmt.Sprintf("%q", parent+"/"+v))
+	}
+
+	w := bytes.NewBufferString("\n")
+	fmt.Fprintf(w, `
+// base path of the routes as generated
+const routeBasePath = %q
+
+// base paths every route under routeBasePath is registered at
+var routeVersionPaths = []string{%s}
+
+// registers routes under each version
+type versionedRouter struct {
+	Router
+}
+
+func (r versionedRouter) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	if !strings.HasPrefix(pattern, routeBasePath+"/") {
+		r.Router.HandleFunc(pattern, handler)
+		return
+	}
+	for _, p := range routeVersionPaths {
+		r.Router.HandleFunc(p+strings.TrimPrefix(pattern, routeBasePath), handler)
+	}
+}
+`, base, strings.Join(prefixes, ", "))
+
+	return format.Source(w.Bytes())
+}
+
+// Generates the collector of message metrics if the metrics route is enabled
+func generateMetrics(o Options) ([]byte, error) {
+	if !o.Metrics {
+		return nil, nil
+	}
+
+	w := bytes.NewBufferString("\n")
+	fmt.Fprintf(w, `
+// collects counts by msg and status code and latencies by msg of dispatched messages for the metrics route
+type metricsCollector struct {
+	mu        sync.Mutex
+	counts    map[metricsKey]uint64
+	durations map[string]*metricsDuration
+}
+
+type metricsKey struct {
+	msg  string
+	code int
+}
+
+type metricsDuration struct {
+	sum   float64
+	count uint64
+}
+
+// collector of all muxes, only known messages are collected, keeping the label cardinality bounded
+var metrics = &metricsCollector{
+	counts:    make(map[metricsKey]uint64),
+	durations: make(map[string]*metricsDuration),
+}
+
+// records a dispatched message
+func (c *metricsCollector) observe(msg string, code int, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[metricsKey{msg, code}]++
+	md, ok := c.durations[msg]
+	if !ok {
+		md = &metricsDuration{}
+		c.durations[msg] = md
+	}
+	md.sum += d.Seconds()
+	md.count++
+}
+
+// writes the collected metrics in Prometheus text format
+func (c *metricsCollector) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := &bytes.Buffer{}
+	b.WriteString("# HELP jsonmsg_messages_total Dispatched messages by msg and status code.\n")
+	b.WriteString("# TYPE jsonmsg_messages_total counter\n")
+	var keys []metricsKey
+	for k := range c.counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].msg != keys[j].msg {
+			return keys[i].msg < keys[j].msg
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		b.WriteString("jsonmsg_messages_total{msg=" + strconv.Quote(k.msg) + ",code=\"" + strconv.Itoa(k.code) + "\"} " + strconv.FormatUint(c.counts[k], 10) + "\n")
+	}
+
+	b.WriteString("# HELP jsonmsg_message_duration_seconds Durations of dispatched messages by msg.\n")
+	b.WriteString("# TYPE jsonmsg_message_duration_seconds summary\n")
+	var msgs []string
+	for msg := range c.durations {
+		msgs = append(msgs, msg)
+	}
+	sort.Strings(msgs)
+	for _, msg := range msgs {
+		d := c.durations[msg]
+		b.WriteString("jsonmsg_message_duration_seconds_sum{msg=" + strconv.Quote(msg) + "} " + strconv.FormatFloat(d.sum, 'g', -1, 64) + "\n")
+		b.WriteString("jsonmsg_message_duration_seconds_count{msg=" + strconv.Quote(msg) + "} " + strconv.FormatUint(d.count, 10) + "\n")
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+`)
+
+	return format.Source(w.Bytes())
+}
+
+// Generates element streaming for array definitions if array streaming is enabled
+func generateStreamers(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	if !o.StreamArrays {
+		return nil, nil
+	}
+
+	w := bytes.NewBufferString("\n")
+	fmt.Fprintf(w, `
+// array data which can be written element by element
+type elementStreamer interface {
+	streamElements(enc func(interface{}) error) error
+}
+
+// number of streamed elements after which the response is flushed
+const streamFlushInterval = 100
+
+// writes an out message with array data element by element, flushing periodically
+func streamMessage(w http.ResponseWriter, out outMessage, s elementStreamer) error {
+	msg, err := json.Marshal(out.Msg)
+	if err != nil {
+		return err
+	}
+	w.Write([]byte("{\"msg\":"))
+	w.Write(msg)
+	w.Write([]byte(",\"data\":["))
+
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	n := 0
+	err = s.streamElements(func(e interface{}) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			w.Write([]byte(","))
+		}
+		n++
+		_, err = w.Write(b)
+		if flusher != nil && n%%streamFlushInterval == 0 {
+			flusher.Flush()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write([]byte("]}\n"))
+	return err
+}
+`)
+
+	for _, k := range definitionKeys(s) {
+		d := s.Definitions[k]
+		if d.Type != "array" || d.Items == nil {
+			continue
+		}
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "func (t *%v) streamElements(enc func(interface{}) error) error {\n", d.Name)
+		fmt.Fprintf(w, "\tfor i := range *t {\n")
+		fmt.Fprintf(w, "\t\tif err := enc((*t)[i]); err != nil {\n")
+		fmt.Fprintf(w, "\t\t\treturn err\n")
+		fmt.Fprintf(w, "\t\t}\n")
+		fmt.Fprintf(w, "\t}\n")
+		fmt.Fprintf(w, "\treturn nil\n")
+		fmt.Fprintf(w, "}\n")
+	}
+
+	return format.Source(w.Bytes())
+}
+
+// Generates MarshalJSON and UnmarshalJSON methods encoding tuple definitions as JSON arrays of their items.
+// Extra items are ignored unless the tuple disallows additional items.
+func generateTuples(s *jsonmsg.Spec) ([]byte, error) {
+	w := bytes.NewBufferString("\n")
+	for _, k := range definitionKeys(s) {
+		t, ok := s.Tuples[k]
+		if !ok {
+			continue
+		}
+		d := s.Definitions[k]
+		var fields, ptrs []string
+		for _, p := range t.Items {
+			fields = append(fields, "t."+goFieldName(s, k, p))
+			ptrs = append(ptrs, "&t."+goFieldName(s, k, p))
+		}
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "// MarshalJSON encodes %v as a JSON array of its items\n", d.Name)
+		fmt.Fprintf(w, "func (t *%v) MarshalJSON() ([]byte, error) {\n", d.Name)
+		fmt.Fprintf(w, "\treturn json.Marshal([]interface{}{%v})\n", strings.Join(fields, ", "))
+		fmt.Fprintf(w, "}\n")
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "// UnmarshalJSON decodes %v from a JSON array of its items\n", d.Name)
+		fmt.Fprintf(w, "func (t *%v) UnmarshalJSON(b []byte) error {\n", d.Name)
+		fmt.Fprintf(w, "\tvar items []json.RawMessage\n")
+		fmt.Fprintf(w, "\terr := json.Unmarshal(b, &items)\n")
+		fmt.Fprintf(w, "\tif err != nil {\n")
+		fmt.Fprintf(w, "\t\treturn err\n")
+		fmt.Fprintf(w, "\t}\n")
+		if !t.AdditionalItems {
+			fmt.Fprintf(w, "\tif len(items) > %v {\n", len(t.Items))
+			fmt.Fprintf(w, "\t\treturn errors.New(%q)\n", fmt.Sprintf("invalid %v: must have at most %v items", d.JSONName, len(t.Items)))
+			fmt.Fprintf(w, "\t}\n")
+		}
+		fmt.Fprintf(w, "\t*t = %v{}\n", d.Name)
+		fmt.Fprintf(w, "\tfields := []interface{}{%v}\n", strings.Join(ptrs, ", "))
+		fmt.Fprintf(w, "\tfor i := 0; i < len(items) && i < len(fields); i++ {\n")
+		fmt.Fprintf(w, "\t\terr = json.Unmarshal(items[i], fields[i])\n")
+		fmt.Fprintf(w, "\t\tif err != nil {\n")
+		fmt.Fprintf(w, "\t\t\treturn err\n")
+		fmt.Fprintf(w, "\t\t}\n")
+		fmt.Fprintf(w, "\t}\n")
+		fmt.Fprintf(w, "\treturn nil\n")
+		fmt.Fprintf(w, "}\n")
+	}
+
+	return format.Source(w.Bytes())
+}
+
+// Generates String methods returning compact JSON for all object definitions without a string property
+func generateStringers(s *jsonmsg.Spec) ([]byte, error) {
+	w := bytes.NewBufferString("\n")
+	for _, k := range definitionKeys(s) {
+		d := s.Definitions[k]
+		if d.Type != "object" {
+			continue
+		}
+		// a property named string is generated as field String
+		stringField := false
+		for p := range d.Properties {
+			stringField = stringField || goFieldName(s, k, p) == "String"
+		}
+		if stringField {
+			continue
+		}
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "func (t *%v) String() string {\n", d.Name)
+		fmt.Fprintf(w, "\tb, err := json.Marshal(t)\n")
+		fmt.Fprintf(w, "\tif err != nil {\n")
+		fmt.Fprintf(w, "\t\treturn err.Error()\n")
+		fmt.Fprintf(w, "\t}\n")
+		fmt.Fprintf(w, "\treturn string(b)\n")
+		fmt.Fprintf(w, "}\n")
+	}
+
+	return format.Source(w.Bytes())
+}
+
+// Go value types of simple schema types
+var goValueTypes = map[string]string{
+	"string":  "string",
+	"number":  "float64",
+	"integer": "int64",
+	"boolean": "bool",
+}
+
+// Generates constructors taking all required fields as value parameters for object definitions,
+// initializing optional fields of value types with their defaults.
+// Definitions without required fields or defaults or with required fields of unsupported types are skipped.
+func generateConstructors(s *jsonmsg.Spec) ([]byte, error) {
+	w := bytes.NewBufferString("\n")
+	for _, k := range definitionKeys(s) {
+		d := s.Definitions[k]
+		if d.Type != "object" {
+			continue
+		}
+
+		var params, fields []string
+		supported := true
+		for _, r := range d.Required {
+			p, ok := d.Properties[r]
+			if !ok {
+				continue
+			}
+			arg := goParamName(r)
+			switch p.Type {
+			case "string", "number", "integer", "boolean":
+				// nil for an explicit null
+				if s.Nullable[k+"/properties/"+r] {
+					params = append(params, fmt.Sprintf("%v *%v", arg, goValueTypes[p.Type]))
+					fields = append(fields, fmt.Sprintf("%v: %v", goFieldName(s, k, r), arg))
+					continue
+				}
+				params = append(params, fmt.Sprintf("%v %v", arg, goValueTypes[p.Type]))
+				fields = append(fields, fmt.Sprintf("%v: &%v", goFieldName(s, k, r), arg))
+			case "ref":
+				ref, ok := s.Definitions[p.Ref]
+				if !ok {
+					return nil, fmt.Errorf("golang: %v does not exist in index", p.Ref)
+				}
+				params = append(params, fmt.Sprintf("%v *%v", arg, ref.Name))
+				fields = append(fields, fmt.Sprintf("%v: %v", goFieldName(s, k, r), arg))
+			default:
+				supported = false
+			}
+		}
+		if !supported {
+			continue
+		}
+
+		defaults, err := defaultFields(s, k)
+		if err != nil {
+			return nil, err
+		}
+		if len(params)+len(defaults) == 0 {
+			continue
+		}
+		fields = append(fields, defaults...)
+
+		fmt.Fprintln(w, "")
+		if len(defaults) > 0 {
+			fmt.Fprintf(w, "// New%v creates a %v with all required fields set and defaults of optional fields\n", d.Name, d.Name)
+		} else {
+			fmt.Fprintf(w, "// New%v creates a %v with all required fields set\n", d.Name, d.Name)
+		}
+		fmt.Fprintf(w, "func New%v(%v) *%v {\n", d.Name, strings.Join(params, ", "), d.Name)
+		fmt.Fprintf(w, "\treturn &%v{\n", d.Name)
+		for _, f := range fields {
+			fmt.Fprintf(w, "\t\t%v,\n", f)
+		}
+		fmt.Fprintf(w, "\t}\n")
+		fmt.Fprintf(w, "}\n")
+	}
+
+	return format.Source(w.Bytes())
+}
+
+// Returns field initializations with the defaults of optional properties of value types of a definition
+func defaultFields(s *jsonmsg.Spec, k string) ([]string, error) {
+	d := s.Definitions[k]
+	var props []string
+	for p, _ := range d.Properties {
+		props = append(props, p)
+	}
+	sort.Strings(props)
+
+	var fields []string
+	for _, p := range props {
+		raw, ok := s.Defaults[k+"/properties/"+p]
+		if !ok || stringsContain(d.Required, p) {
+			continue
+		}
+
+		var v interface{}
+		var ctor string
+		switch d.Properties[p].Type {
+		case "string":
+			v, ctor = new(string), "newString(%q)"
+		case "number":
+			v, ctor = new(float64), "newFloat64(%v)"
+		case "integer":
+			v, ctor = new(int64), "newInt64(%v)"
+		case "boolean":
+			v, ctor = new(bool), "newBool(%v)"
+		default:
+			continue
+		}
+		err := json.Unmarshal(raw, v)
+		if err != nil {
+			return nil, fmt.Errorf("golang: default %s of %v/properties/%v does not match type %v", raw, k, p, d.Properties[p].Type)
+		}
+		val := reflect.ValueOf(v).Elem().Interface()
+		fields = append(fields, fmt.Sprintf("%v: "+ctor, goFieldName(s, k, p), val))
+	}
+	return fields, nil
+}
+
+// Returns the sorted pointers of all top-level definitions
+func definitionKeys(s *jsonmsg.Spec) []string {
+	var keys []string
+	for k, _ := range s.Definitions {
+		n := strings.TrimPrefix(k, "#/definitions/")
+		if n != k && !strings.Contains(n, "/") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Generates the connection type if sessions are enabled
+func generateConn(o Options) ([]byte, error) {
+	if !o.Sessions {
+		return nil, nil
+	}
+
+	w := bytes.NewBufferString("\n")
+	fmt.Fprintf(w, `
+// Conn holds session values of a single websocket connection. HTTP requests get a fresh Conn each.
+type Conn struct {
+	mu     sync.Mutex
+	id     string
+	values map[string]interface{}
+}
+
+func newConn() *Conn {
+	return &Conn{values: make(map[string]interface{})}
+}
+
+// ID returns the id of the websocket connection as tracked by a Hub, empty otherwise
+func (c *Conn) ID() string {
+	return c.id
+}
+
+// Get returns the value stored for key or nil
+func (c *Conn) Get(key string) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+// Set stores a value for key
+func (c *Conn) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+}
+
+// Delete removes the value stored for key
+func (c *Conn) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key)
+}
+`)
+
+	return format.Source(w.Bytes())
+}
+
+// Generates the websocket connection writer and Hub if a websocket endpoint exists
+func generateHub(s *jsonmsg.Spec) ([]byte, error) {
+	if _, ok := s.Endpoints["websocket"]; !ok {
+		return nil, nil
+	}
+
+	w := bytes.NewBufferString("\n")
+	fmt.Fprintf(w, `
+// websocket connection serializing concurrent writes
+type wsConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (c *wsConn) write(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// Default maximum size in bytes of received websocket messages
+const DefaultMaxMessageSize = 1 << 20
+
+// BinaryCodec encodes and decodes messages of binary websocket frames, e.g. msgpack.
+// Marshal receives generic JSON values: maps, slices, strings, float64, bool and nil.
+type BinaryCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// converts a binary frame into a JSON message, binary frames are JSON without a codec
+func decodeBinaryFrame(c BinaryCodec, data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	var v interface{}
+	err := c.Unmarshal(data, &v)
+	if err != nil {
+		return nil, err
+	}
+	v, err = jsonValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// converts an out message into a binary frame, binary frames are JSON without a codec
+func encodeBinaryFrame(c BinaryCodec, out interface{}) ([]byte, error) {
+	raw, err := json.Marshal(out)
+	if err != nil || c == nil {
+		return raw, err
+	}
+	var v interface{}
+	err = json.Unmarshal(raw, &v)
+	if err != nil {
+		return nil, err
+	}
+	return c.Marshal(v)
+}
+
+// converts maps with interface{} keys, as decoded by some codecs, into JSON objects
+func jsonValue(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, errors.New("binary frame has a non-string map key")
+			}
+			ev, err := jsonValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[ks] = ev
+		}
+		return m, nil
+	case map[string]interface{}:
+		for k, e := range t {
+			ev, err := jsonValue(e)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = ev
+		}
+	case []interface{}:
+		for i, e := range t {
+			ev, err := jsonValue(e)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = ev
+		}
+	}
+	return v, nil
+}
+
+// Hub tracks websocket connections and pushes server-initiated messages to them.
+// Pass it via APIOptions to the mux and to the API implementation.
+type Hub struct {
+	mu    sync.Mutex
+	next  uint64
+	conns map[string]*wsConn
+}
+
+func NewHub() *Hub {
+	return &Hub{conns: make(map[string]*wsConn)}
+}
+
+// Broadcast sends a message to all connections
+func (h *Hub) Broadcast(msg string, data interface{}) error {
+	raw, err := json.MarshalIndent(newValueMessage(msg, data), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	conns := make([]*wsConn, 0, len(h.conns))
+	for _, c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range conns {
+		c.write(websocket.TextMessage, raw)
+	}
+	return nil
+}
+
+// Send sends a message to the connection with connID
+func (h *Hub) Send(connID string, msg string, data interface{}) error {
+	h.mu.Lock()
+	c, ok := h.conns[connID]
+	h.mu.Unlock()
+	if !ok {
+		return errors.New("unknown connection: " + connID)
+	}
+
+	raw, err := json.MarshalIndent(newValueMessage(msg, data), "", "  ")
+	if err != nil {
+		return err
+	}
+	return c.write(websocket.TextMessage, raw)
+}
+
+// ConnIDs returns the ids of all connections
+func (h *Hub) ConnIDs() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ids := make([]string, 0, len(h.conns))
+	for id, _ := range h.conns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (h *Hub) add(c *wsConn) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.next++
+	id := strconv.FormatUint(h.next, 10)
+	h.conns[id] = c
+	return id
+}
+
+func (h *Hub) remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns, id)
+}
+
+// Returns the close code and reason of a websocket connection after answering a message with statusCode,
+// false keeps the connection open. Errors of single messages, like invalid data, failing API methods
+// or timeouts, keep it open. Unparsable messages break the protocol and other server errors are fatal.
+func closeReason(in []byte, statusCode int) (int, string, bool) {
+	switch {
+	case statusCode < 400:
+		return 0, "", false
+	case statusCode < 500:
+		var m message
+		if in == nil || json.Unmarshal(in, &m) != nil {
+			return websocket.CloseInvalidFramePayloadData, "unparsable message", true
+		}
+		return 0, "", false
+	case statusCode == http.StatusInternalServerError || statusCode == http.StatusServiceUnavailable:
+		return 0, "", false
+	default:
+		return websocket.CloseInternalServerErr, http.StatusText(statusCode), true
+	}
+}
+`)
+
+	return format.Source(w.Bytes())
+}
+
+var (
+	handlerTemplateOnce sync.Once
+	handlerTemplate     *template.Template
+	handlerTemplateErr  error
+)
+
+// Returns a copy of httpHandlerTemplate, which is parsed once for all generations.
+// Funcs depending on the spec are stubs, to be replaced by the caller before executing the copy.
+func parsedHandlerTemplate() (*template.Template, error) {
+	handlerTemplateOnce.Do(func() {
+		stub := func(*jsonschema.Schema) bool {
+			return false
+		}
+		handlerTemplate, handlerTemplateErr = template.New("handler").Funcs(template.FuncMap{
+			"Contains":            stringsContain,
+			"ValueValidated":      stub,
+			"InType":              inType,
+			"ReadOnlyValidated":   stub,
+			"PresenceValidated":   stub,
+			"WriteOnlyRestricted": stub,
+		}).Parse(httpHandlerTemplate)
+	})
+	if handlerTemplateErr != nil {
+		return nil, handlerTemplateErr
+	}
+	return handlerTemplate.Clone()
+}
+
+// Generates an HTTP and HTTPS handler
+func generateHTTPHandler(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	checks, err := valueChecks(s, o)
+	if err != nil {
+		return nil, err
+	}
+	validated := valueValidated(s, checks)
+	readOnly := accessRestricted(s, s.ReadOnly)
+	present := accessRestricted(s, requiredNullable(s))
+	writeOnly := accessRestricted(s, s.WriteOnly)
+
+	tmpl, err := parsedHandlerTemplate()
+	if err != nil {
+		return nil, err
+	}
+	tmpl = tmpl.Funcs(template.FuncMap{
+		"ValueValidated": func(sch *jsonschema.Schema) bool {
+			k, ok := definitionKey(s, sch)
+			return ok && validated[k]
+		},
+		"ReadOnlyValidated": func(sch *jsonschema.Schema) bool {
+			k, ok := definitionKey(s, sch)
+			return ok && readOnly[k]
+		},
+		"PresenceValidated": func(sch *jsonschema.Schema) bool {
+			k, ok := definitionKey(s, sch)
+			return ok && present[k]
+		},
+		"WriteOnlyRestricted": func(sch *jsonschema.Schema) bool {
+			k, ok := definitionKey(s, sch)
+			return ok && writeOnly[k]
+		},
+	})
+
+	w := bytes.NewBufferString("\n")
+	err = tmpl.Execute(w, serverData{s, o})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := s.Endpoints["http"]; ok {
+		w.WriteString(requestIDSrc)
+	}
+	if (serverData{s, o}).MessageHeaders() {
+		generateMessageHeaders(w, s)
+	}
+	if (serverData{s, o}).FormMessages() {
+		generateFormBinding(w, s)
+	}
+	if (serverData{s, o}).BatchMessages() {
+		w.WriteString(batchSrc)
+	}
+	if (serverData{s, o}).JSONRPCRoute() != "" {
+		w.WriteString(jsonrpcSrc)
+	}
+
+	return format.Source(w.Bytes())
+}
+
+// JSON-RPC 2.0 requests dispatched as messages
+const jsonrpcSrc = `
+// JSON-RPC 2.0 request, a notification if it has no id
+type jsonrpcRequest struct {
+	Version string          ` + "`" + `json:"jsonrpc"` + "`" + `
+	Method  string          ` + "`" + `json:"method"` + "`" + `
+	Params  json.RawMessage ` + "`" + `json:"params"` + "`" + `
+	ID      json.RawMessage ` + "`" + `json:"id"` + "`" + `
+}
+
+// JSON-RPC 2.0 error object, data holds the error message of the API
+type jsonrpcError struct {
+	Code    int         ` + "`" + `json:"code"` + "`" + `
+	Message string      ` + "`" + `json:"message"` + "`" + `
+	Detail  interface{} ` + "`" + `json:"data,omitempty"` + "`" + `
+}
+
+// JSON-RPC 2.0 error codes of message status codes, other failures are server errors
+var jsonrpcErrorCodes = map[int]int{
+	http.StatusNotFound:            -32601,
+	http.StatusUnprocessableEntity: -32602,
+	http.StatusInternalServerError: -32603,
+}
+
+// returns a JSON-RPC 2.0 response with a result or an error
+func newJSONRPCResponse(id json.RawMessage, result interface{}, e *jsonrpcError) map[string]interface{} {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	res := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if e != nil {
+		res["error"] = e
+	} else {
+		res["result"] = result
+	}
+	return res
+}
+
+// processes a JSON-RPC 2.0 request as message, its result is the out message.
+// Notifications are processed without a response.
+func processJSONRPC(raw json.RawMessage, process func(in []byte) (interface{}, int)) (interface{}, bool) {
+	var req jsonrpcRequest
+	err := json.Unmarshal(raw, &req)
+	if err != nil || req.Version != "2.0" || req.Method == "" {
+		return newJSONRPCResponse(nil, nil, &jsonrpcError{Code: -32600, Message: "Invalid Request"}), true
+	}
+
+	in, err := json.Marshal(message{Msg: req.Method, Data: req.Params})
+	if err != nil {
+		return newJSONRPCResponse(req.ID, nil, &jsonrpcError{Code: -32603, Message: "Internal error"}), true
+	}
+	out, statusCode := process(in)
+	if req.ID == nil {
+		return nil, false
+	}
+	if statusCode == http.StatusOK {
+		return newJSONRPCResponse(req.ID, out, nil), true
+	}
+	code, ok := jsonrpcErrorCodes[statusCode]
+	if !ok {
+		code = -32000
+	}
+	return newJSONRPCResponse(req.ID, nil, &jsonrpcError{Code: code, Message: http.StatusText(statusCode), Detail: out}), true
+}
+
+// answers a JSON-RPC 2.0 request or batch of requests posted to the JSON-RPC route
+func serveJSONRPC(w http.ResponseWriter, r *http.Request, process func(in []byte) (interface{}, int)) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Allow", "POST, OPTIONS")
+
+	// handle OPTIONS
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// ensure POST
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		enc.Encode(InvalidMethodErrorMessage)
+		return
+	}
+
+	body, errMsg, statusCode := readRequestBody(r)
+	if errMsg != nil {
+		w.WriteHeader(statusCode)
+		enc.Encode(errMsg)
+		return
+	}
+	body = bytes.TrimSpace(body)
+
+	// single request
+	if len(body) == 0 || body[0] != '[' {
+		if !json.Valid(body) {
+			enc.Encode(newJSONRPCResponse(nil, nil, &jsonrpcError{Code: -32700, Message: "Parse error"}))
+			return
+		}
+		res, ok := processJSONRPC(body, process)
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		enc.Encode(res)
+		return
+	}
+
+	// batch of requests, answered with the responses to all but notifications
+	var batch []json.RawMessage
+	err := json.Unmarshal(body, &batch)
+	if err != nil {
+		enc.Encode(newJSONRPCResponse(nil, nil, &jsonrpcError{Code: -32700, Message: "Parse error"}))
+		return
+	}
+	if len(batch) == 0 {
+		enc.Encode(newJSONRPCResponse(nil, nil, &jsonrpcError{Code: -32600, Message: "Invalid Request"}))
+		return
+	}
+	responses := []interface{}{}
+	for _, raw := range batch {
+		if res, ok := processJSONRPC(raw, process); ok {
+			responses = append(responses, res)
+		}
+	}
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	enc.Encode(responses)
+}
+`
+
+// batched outs of messages marked "batch": true
+const batchSrc = `
+// all non-nil outs of a batch message, answered over http as a JSON array of out messages
+// and over websocket with a frame per out message
+type outBatch []interface{}
+
+// out message of a batch answered over websocket, telling how many frames of the batch follow
+type batchFrame struct {
+	Msg       string      ` + "`" + `json:"msg"` + "`" + `
+	Data      interface{} ` + "`" + `json:"data"` + "`" + `
+	Remaining int         ` + "`" + `json:"remaining"` + "`" + `
+}
+
+// returns the websocket frames of the out messages of a batch, or out itself
+func outFrames(out interface{}) []interface{} {
+	b, ok := out.(outBatch)
+	if !ok {
+		return []interface{}{out}
+	}
+	frames := make([]interface{}, len(b))
+	for i, o := range b {
+		m := o.(outMessage)
+		frames[i] = batchFrame{Msg: m.Msg, Data: m.Data, Remaining: len(b) - 1 - i}
+	}
+	return frames
+}
+`
+
+// request IDs of http messages
+const requestIDSrc = `
+// Header of the request ID echoed on http responses, e.g. for tracing across services
+const RequestIDHeader = "X-Request-ID"
+
+// Returns the request ID sent by the client, or a new random ID if it is missing or no printable ASCII of up to 200 bytes
+func requestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if id != "" && len(id) <= 200 && strings.IndexFunc(id, func(c rune) bool { return c < ' ' || c > '~' }) == -1 {
+		return id
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+`
+
+// Generates the binding of multipart forms to the data of form messages
+func generateFormBinding(w *bytes.Buffer, s *jsonmsg.Spec) {
+	fmt.Fprintf(w, "\n// kinds of the form fields of form messages by msg, e.g. \"integer\" or \"[]string\" for arrays\n")
+	fmt.Fprintf(w, "var formFields = map[string]map[string]string{\n")
+	var keys []string
+	for k, _ := range s.Messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m := s.Messages[k]
+		if !m.Form {
+			continue
+		}
+		var props []string
+		for p, _ := range m.InSchema.Properties {
+			props = append(props, p)
+		}
+		sort.Strings(props)
+		fmt.Fprintf(w, "\tMsg%v: {\n", m.Name)
+		for _, p := range props {
+			if kind, ok := formKind(s, m.InSchema.Properties[p]); ok {
+				fmt.Fprintf(w, "\t\t%q: %q,\n", p, kind)
+			}
+		}
+		fmt.Fprintf(w, "\t},\n")
+	}
+	fmt.Fprintf(w, "}\n")
+	w.WriteString(formBindingSrc)
+}
+
+// Returns the kind of form field a property is bound from, false for properties forms cannot hold, e.g. objects
+func formKind(s *jsonmsg.Spec, p *jsonschema.Schema) (string, bool) {
+	if p.Type == "ref" {
+		d, ok := s.Definitions[p.Ref]
+		if !ok {
+			return "", false
+		}
+		p = d
+	}
+	switch p.Type {
+	case "string", "integer", "number", "boolean":
+		return p.Type, true
+	case "array":
+		if p.Items == nil || p.Items.Type == "array" {
+			return "", false
+		}
+		kind, ok := formKind(s, p.Items)
+		return "[]" + kind, ok
+	}
+	return "", false
+}
+
+// binding of multipart forms, independent of the spec
+const formBindingSrc = `
+// Answers multipart forms of messages not accepting forms
+var UnsupportedFormErrorMessage = newErrorMessage("message does not accept forms")
+
+// reads the multipart form of a form message given as msg query parameter, binding its fields to the message data.
+// Files are bound base64-encoded. Failures return the error message and status code to respond with.
+func readFormBody(r *http.Request) ([]byte, interface{}, int) {
+	msg := r.URL.Query().Get("msg")
+	fields, ok := formFields[msg]
+	if !ok {
+		return nil, UnsupportedFormErrorMessage, http.StatusUnsupportedMediaType
+	}
+
+	// files beyond 32 MB in memory are stored in temporary files
+	err := r.ParseMultipartForm(32 << 20)
+	if err != nil {
+		return nil, UnparsableRequestErrorMessage, http.StatusUnprocessableEntity
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	data := make(map[string]interface{})
+	for name, kind := range fields {
+		values := r.MultipartForm.Value[name]
+		for _, fh := range r.MultipartForm.File[name] {
+			b, err := readFormFile(fh)
+			if err != nil {
+				return nil, UnparsableRequestErrorMessage, http.StatusUnprocessableEntity
+			}
+			values = append(values, base64.StdEncoding.EncodeToString(b))
+		}
+		if len(values) == 0 {
+			continue
+		}
+		v, err := formValue(kind, values)
+		if err != nil {
+			return nil, newErrorMessage(fmt.Sprintf("invalid form field %v: %v", name, err)), http.StatusUnprocessableEntity
+		}
+		data[name] = v
+	}
+
+	body, err := json.Marshal(outMessage{Msg: msg, Data: data})
+	if err != nil {
+		return nil, InternalErrorMessage, http.StatusInternalServerError
+	}
+	return body, nil, http.StatusOK
+}
+
+// reads the content of a file of a multipart form
+func readFormFile(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// converts the values of a form field to a JSON value of kind, the first value unless kind is an array
+func formValue(kind string, values []string) (interface{}, error) {
+	if strings.HasPrefix(kind, "[]") {
+		vs := make([]interface{}, len(values))
+		for i, s := range values {
+			v, err := formValue(kind[2:], []string{s})
+			if err != nil {
+				return nil, err
+			}
+			vs[i] = v
+		}
+		return vs, nil
+	}
+
+	switch kind {
+	case "integer":
+		return strconv.ParseInt(values[0], 10, 64)
+	case "number":
+		return strconv.ParseFloat(values[0], 64)
+	case "boolean":
+		return strconv.ParseBool(values[0])
+	}
+	return values[0], nil
+}
+`
+
+// Generates the static response headers by msg, quoted in go as values may hold any characters
+func generateMessageHeaders(w *bytes.Buffer, s *jsonmsg.Spec) {
+	fmt.Fprintf(w, "\n// static headers of successful responses by msg\nvar messageHeaders = map[string]map[string]string{\n")
+	var keys []string
+	for k, _ := range s.Messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m := s.Messages[k]
+		if len(m.Headers) == 0 {
+			continue
+		}
+		var names []string
+		for n, _ := range m.Headers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(w, "\tMsg%v: {\n", m.Name)
+		for _, n := range names {
+			fmt.Fprintf(w, "\t\t%v: %v,\n", strconv.Quote(textproto.CanonicalMIMEHeaderKey(n)), strconv.Quote(m.Headers[n]))
+		}
+		fmt.Fprintf(w, "\t},\n")
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
+// Generates helper
+func generateHelper(s *jsonmsg.Spec) ([]byte, error) {
+	errm, err := generateErrorMessage(s)
+	if err != nil {
+		return nil, err
+	}
+
+	w := bytes.NewBufferString("\n")
+	fmt.Fprintf(w, `
+// jsonmsg message schema
+type message struct {
+	Msg string 
+	Data json.RawMessage
+}
+
+func newMessageReader(msg string, data interface{}) io.Reader {
+	b, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	m := &message{msg, b}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return bytes.NewBuffer(raw)
+}
+
+type outMessage struct {
+	Msg string
+	Data interface{}
+}
+
+// data with writeOnly properties, which are never sent
+type writeOnlyStripper interface {
+	withoutWriteOnly() interface{}
+}
+
+func newValueMessage(msg string, data interface{}) outMessage {
+	if s, ok := data.(writeOnlyStripper); ok {
+		data = s.withoutWriteOnly()
+	}
+	return outMessage{
+		Msg: msg,
+		Data: data,
+	}
+}
+
+// Default duration browsers may cache CORS preflight responses
+const DefaultPreflightMaxAge = 10 * time.Minute
+
+// returns the Access-Control-Max-Age of preflight responses in seconds or "" to omit it
+func preflightMaxAge(d time.Duration) string {
+	if d == 0 {
+		d = DefaultPreflightMaxAge
+	}
+	if d < 0 {
+		return ""
+	}
+	return strconv.Itoa(int(d / time.Second))
+}
+
+%s
+// closed once a timed out handler returned, nil for handlers that were not abandoned
+type abandonedHandler <-chan struct{}
+
+// blocks until the abandoned handler returned, returns at once for nil
+func (a abandonedHandler) wait() {
+	if a != nil {
+		<-a
+	}
+}
+
+// runs dispatch with a context cancelled after timeout, zero disables the timeout.
+// A timed out dispatch is answered with TimeoutErrorMessage and abandoned: it runs on until it returns,
+// which closes the returned abandonedHandler.
+func dispatchWithTimeout(ctx context.Context, timeout time.Duration, dispatch func(ctx context.Context) (interface{}, int)) (interface{}, int, abandonedHandler) {
+	if timeout <= 0 {
+		out, statusCode := dispatch(ctx)
+		return out, statusCode, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		out        interface{}
+		statusCode int
+	}
+	done := make(chan result, 1)
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		out, statusCode := dispatch(ctx)
+		done <- result{out, statusCode}
+	}()
+
+	select {
+	case r := <-done:
+		return r.out, r.statusCode, nil
+	case <-ctx.Done():
+		return TimeoutErrorMessage, http.StatusServiceUnavailable, returned
+	}
+}
+
+var (
 	UnparsableRequestErrorMessage = newErrorMessage("unparsable message")
 	InternalErrorMessage          = newErrorMessage("internal error")
 	UnknownMessageErrorMessage    = newErrorMessage("unknown message")
 	InvalidMethodErrorMessage     = newErrorMessage("only POST method allowed")
+	TimeoutErrorMessage           = newErrorMessage("timeout")
+	TooManyRequestsErrorMessage   = newErrorMessage("too many requests")
+	CorruptEncodingErrorMessage   = newErrorMessage("corrupt content encoding")
+	UnsupportedEncodingErrorMessage = newErrorMessage("unsupported content encoding")
 )
-`)
+
+// Limiter decides whether a message may be dispatched, e.g. by rate limiting per msg
+type Limiter interface {
+	Allow(msg string) bool
+}
+
+// NewRateLimiter returns a Limiter allowing up to limits[msg] messages per second for each msg
+// with bursts of the same size. Messages without a limit are not limited.
+func NewRateLimiter(limits map[string]int) Limiter {
+	return &rateLimiter{
+		limits: limits,
+		tokens: make(map[string]float64),
+		last:   make(map[string]time.Time),
+	}
+}
+
+// token bucket per msg, refilled with the limit per second
+type rateLimiter struct {
+	mu     sync.Mutex
+	limits map[string]int
+	tokens map[string]float64
+	last   map[string]time.Time
+}
+
+func (l *rateLimiter) Allow(msg string) bool {
+	limit, ok := l.limits[msg]
+	if !ok {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	t, ok := l.tokens[msg]
+	if !ok {
+		t = float64(limit)
+	} else {
+		t += now.Sub(l.last[msg]).Seconds() * float64(limit)
+		if t > float64(limit) {
+			t = float64(limit)
+		}
+	}
+	l.last[msg] = now
+
+	if t < 1 {
+		l.tokens[msg] = t
+		return false
+	}
+	l.tokens[msg] = t - 1
+	return true
+}
+`, errm)
 
 	// json annotations (unfortunately not possible in multiline strings)
 	src := w.String()
@@ -225,13 +2606,115 @@ var (
 	src = strings.Replace(src, "Data json.RawMessage", "Data json.RawMessage `json:\"data\"`", -1)
 	src = strings.Replace(src, "Data interface{}", "Data interface{} `json:\"data\"`", -1)
 	src = strings.Replace(src, "Error string", "Error string `json:\"error\"`", -1)
+	src += envelopeSrc
 
 	return format.Source([]byte(src))
 }
 
+// exported message type for external code, appended after the json annotations of the helper
+const envelopeSrc = `
+// Envelope is a message on the wire, e.g. {"msg": "findUser", "data": {"id": "123"}},
+// for external code constructing and parsing messages without the API mux or Client
+type Envelope struct {
+	Msg  string          ` + "`" + `json:"msg"` + "`" + `
+	Data json.RawMessage ` + "`" + `json:"data,omitempty"` + "`" + `
+}
+
+// Returns an Envelope of msg holding the marshaled data, a nil data is left out
+func NewEnvelope(msg string, data interface{}) (*Envelope, error) {
+	e := &Envelope{Msg: msg}
+	if data == nil {
+		return e, nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	e.Data = b
+	return e, nil
+}
+
+// Unmarshals the data of the envelope into v, e.g. a *User of a user message
+func (e *Envelope) UnmarshalData(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+`
+
+// Generates the error payload type and constructor, either the default errorData or the spec's error schema
+func generateErrorMessage(s *jsonmsg.Spec) (string, error) {
+	d := s.ErrorDefinition
+	if d == nil {
+		return `
+type errorData struct {
+	Error string
+}
+
+func newErrorMessage(e string) outMessage {
+	return outMessage{
+		Msg: "error",
+		Data: errorData{e},
+	}
+}
+`, nil
+	}
+
+	p := errorMessageProperty(d)
+	if p == "" {
+		return "", fmt.Errorf("golang: error schema %v has no string property to hold the error message", s.ErrorSchema)
+	}
+
+	return fmt.Sprintf(`
+func newErrorMessage(e string) outMessage {
+	return outMessage{
+		Msg: %q,
+		Data: &%v{%v: &e},
+	}
+}
+`, d.JSONName, d.Name, goFieldName(s, s.ErrorSchema, p)), nil
+}
+
+// Returns the string property of an error schema holding the error message:
+// the first required string property, a string property named error or message, or the first string property
+func errorMessageProperty(d *jsonschema.Schema) string {
+	for _, r := range d.Required {
+		if p, ok := d.Properties[r]; ok && p.Type == "string" {
+			return r
+		}
+	}
+	for _, n := range []string{"error", "message"} {
+		if p, ok := d.Properties[n]; ok && p.Type == "string" {
+			return n
+		}
+	}
+	var keys []string
+	for k, p := range d.Properties {
+		if p.Type == "string" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
 // Generates embedded spec
-func generateEmbeddedJSONSpec(s *jsonmsg.Spec) ([]byte, error) {
-	raw, err := s.JSONSpec()
+func generateEmbeddedJSONSpec(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	if o.NoEmbeddedSpec {
+		return nil, nil
+	}
+
+	raw, err := s.JSONSpecWithOptions(jsonmsg.JSONSpecOptions{StripComments: o.StripComments})
+	if err != nil {
+		return nil, err
+	}
+
+	// the route encodes the spec indented, its etag is of the response body
+	body := &bytes.Buffer{}
+	enc := json.NewEncoder(body)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(json.RawMessage(raw))
 	if err != nil {
 		return nil, err
 	}
@@ -241,37 +2724,83 @@ func generateEmbeddedJSONSpec(s *jsonmsg.Spec) ([]byte, error) {
 	fmt.Fprintf(w, "func newEmbeddedSpec() []byte {\n")
 	fmt.Fprintf(w, "\treturn []byte(`%s`)\n", raw)
 	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "\n// etag of embedded spec\n")
+	fmt.Fprintf(w, "const embeddedSpecETag = %q\n", etag(body.Bytes()))
+	w.WriteString(etagMatchesSrc)
 
 	return format.Source(w.Bytes())
 }
 
 // Generates embedded html spec
-func generateEmbeddedHTMLSpec(s *jsonmsg.Spec) ([]byte, error) {
-	w := bytes.NewBufferString("\n")
-	fmt.Fprintf(w, "// embedded html spec\n")
-	fmt.Fprintf(w, "func newEmbeddedHTMLSpec() []byte {\n")
-	fmt.Fprintf(w, "\treturn []byte(`\n")
+func generateEmbeddedHTMLSpec(s *jsonmsg.Spec, o Options) ([]byte, error) {
+	if o.NoEmbeddedSpec {
+		return nil, nil
+	}
 
 	h, err := s.HTTPSpec()
 	if err != nil {
 		return nil, err
 	}
-	fmt.Fprintf(w, "%s", h)
+	body := append(append([]byte("\n"), h...), '\t')
 
-	fmt.Fprintf(w, "\t`)\n")
+	w := bytes.NewBufferString("\n")
+	fmt.Fprintf(w, "// embedded html spec\n")
+	fmt.Fprintf(w, "func newEmbeddedHTMLSpec() []byte {\n")
+	fmt.Fprintf(w, "\treturn []byte(`%s`)\n", body)
 	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "\n// etag of embedded html spec, which is served as is\n")
+	fmt.Fprintf(w, "const embeddedHTMLSpecETag = %q\n", etag(body))
 
 	return format.Source(w.Bytes())
 }
 
+// Returns a strong HTTP entity tag for the given content
+func etag(b []byte) string {
+	return fmt.Sprintf("\"%x\"", sha256.Sum256(b))
+}
+
+// conditional GETs of the spec routes
+const etagMatchesSrc = `
+// reports if the entity tags of an If-None-Match header match etag by weak comparison, "*" matches any
+func etagMatches(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	h := header
+	for {
+		h = strings.TrimLeft(h, " \t,")
+		switch {
+		case h == "":
+			return false
+		case h[0] == '*':
+			return true
+		}
+
+		// a quoted tag, which may contain commas
+		h = strings.TrimPrefix(h, "W/")
+		if h == "" || h[0] != '"' {
+			return false
+		}
+		end := strings.IndexByte(h[1:], '"')
+		if end == -1 {
+			return false
+		}
+		if h[:end+2] == etag {
+			return true
+		}
+		h = h[end+2:]
+	}
+}
+`
+
 // Returns a list of required Go imports
 func importsForSrc(src []byte) []string {
 	i := []string{
 		"net/http",
 		"encoding/json",
 		"bytes",
+		"context",
 		"io",
 		"io/ioutil",
+		"time",
 	}
 
 	// websockets
@@ -279,97 +2808,503 @@ func importsForSrc(src []byte) []string {
 		i = append(i, "github.com/gorilla/websocket")
 	}
 
+	// sessions and hub
+	if strings.Contains(string(src), "sync.Mutex") {
+		i = append(i, "sync")
+	}
+	if strings.Contains(string(src), "type Hub struct") {
+		i = append(i, "errors", "sort", "strconv")
+	}
+
+	// compressed request bodies
+	if strings.Contains(string(src), "func readRequestBody(") {
+		i = append(i, "compress/gzip", "compress/zlib")
+	}
+
+	// string formats
+	if strings.Contains(string(src), "func validEmailFormat(") || strings.Contains(string(src), "func validUUIDFormat(") {
+		i = unionStrings(i, []string{"regexp"})
+	}
+	if strings.Contains(string(src), "func validURIFormat(") {
+		i = unionStrings(i, []string{"net/url"})
+	}
+	if strings.Contains(string(src), "func validIPv4Format(") {
+		i = unionStrings(i, []string{"net", "strings"})
+	}
+
+	// request IDs
+	if strings.Contains(string(src), "func requestID(") {
+		i = unionStrings(i, []string{"crypto/rand", "encoding/hex", "strings"})
+	}
+
+	// metrics
+	if strings.Contains(string(src), "type metricsCollector struct") {
+		i = unionStrings(i, []string{"sort", "strconv"})
+	}
+
+	// versioned routes
+	if strings.Contains(string(src), "type versionedRouter struct") {
+		i = unionStrings(i, []string{"strings"})
+	}
+
+	// conditional GETs of the spec routes
+	if strings.Contains(string(src), "func etagMatches(") {
+		i = unionStrings(i, []string{"strings"})
+	}
+
+	// preflight caching
+	if strings.Contains(string(src), "func preflightMaxAge(") {
+		i = unionStrings(i, []string{"strconv"})
+	}
+
+	// collected validation failures
+	if strings.Contains(string(src), "type validationErrors []string") {
+		i = unionStrings(i, []string{"strings"})
+	}
+
+	// client, whose methods only use errors for outs and the websocket transport
+	if strings.Contains(string(src), "type Client struct") {
+		i = unionStrings(i, []string{"strconv"})
+		if strings.Contains(string(src), "errors.New(") {
+			i = unionStrings(i, []string{"errors"})
+		}
+	}
+	if strings.Contains(string(src), "func NewClientWithBase(") {
+		i = unionStrings(i, []string{"strings"})
+	}
+
+	// multipart forms
+	if strings.Contains(string(src), "func readFormBody(") {
+		i = unionStrings(i, []string{"encoding/base64", "fmt", "mime", "mime/multipart", "strconv", "strings"})
+	}
+
+	// reflection-free marshalers
+	if strings.Contains(string(src), "func appendJSONFloat(") {
+		i = unionStrings(i, []string{"fmt", "math", "strconv", "unicode/utf8"})
+	}
+
 	sort.Strings(i)
 	return i
 }
 
 const httpHandlerTemplate = `
+// APIOptions configure the behaviour of the API mux
+type APIOptions struct {
+	// Maximum duration of a single message dispatch, zero disables the timeout
+	RequestTimeout time.Duration
+
+	// Duration browsers may cache CORS preflight responses, zero uses DefaultPreflightMaxAge, negative omits Access-Control-Max-Age
+	PreflightMaxAge time.Duration
+
+	// Optional limiter consulted for every message, denied messages are answered with status 429
+	Limiter Limiter
+
+	// Optional logger of the payloads of every message, only intended for debugging
+	PayloadLogger PayloadLogger
+	{{ if (index .Endpoints "websocket") }}
+	// Optional hub tracking websocket connections for server-initiated messages
+	Hub *Hub
+
+	// Maximum size in bytes of received websocket messages, zero uses DefaultMaxMessageSize, negative disables the limit
+	MaxMessageSize int64
+
+	// Optional codec of binary websocket frames, e.g. msgpack. Binary frames are JSON without a codec.
+	BinaryCodec BinaryCodec
+
+	// Accepted websocket subprotocols in order of preference, the first one requested by the client is selected
+	Subprotocols []string
+	{{ end }}
+}
+
+// Router registers handlers for patterns, e.g. *http.ServeMux
+type Router interface {
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
 func NewAPIMux(i API) *http.ServeMux {
-  mux := http.NewServeMux()
+	return NewAPIMuxWithOptions(i, APIOptions{})
+}
 
-	// processing logic
-	processMessage := func(in []byte) (interface{}, int) {
-		var err error
+func NewAPIMuxWithOptions(i API, o APIOptions) *http.ServeMux {
+	mux := http.NewServeMux()
+	RegisterRoutesWithOptions(mux, i, o)
+	return mux
+}
 
-		// parse message
-		var m message
-		err = json.Unmarshal(in, &m)
+func RegisterRoutes(mux Router, i API) {
+	RegisterRoutesWithOptions(mux, i, APIOptions{})
+}
+
+// Processes a message with data by the API implementation without any HTTP/websocket stack,
+// returning the out message and status code a HTTP handler would respond with.
+{{- if .Options.Sessions }}
+// A nil conn processes the message within a fresh session.
+{{- end }}
+func ProcessMessage({{ if .Options.Context }}ctx context.Context, {{ end }}i API, {{ if .Options.Sessions }}conn *Conn, {{ end }}msg string, data interface{}) (interface{}, int) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return UnparsableRequestErrorMessage, http.StatusUnprocessableEntity
+	}
+	in, err := json.Marshal(message{msg, raw})
+	if err != nil {
+		return UnparsableRequestErrorMessage, http.StatusUnprocessableEntity
+	}
+	{{ if .Options.Sessions }}
+	if conn == nil {
+		conn = newConn()
+	}
+	{{ end }}
+	out, statusCode, _ := dispatchMessage({{ if .Options.Context }}ctx{{ else }}context.Background(){{ end }}, i, nil, 0, {{ if .Options.Sessions }}conn, {{ end }}in)
+	return out, statusCode
+}
+
+// Returns a handler per msg, processing the data of a message like ProcessMessage.
+// The handlers allow wiring single messages into other transports.
+{{- if .Options.Context }}
+// API methods receive a background context.
+{{- end }}
+{{- if .Options.Sessions }}
+// All handlers share conn, a nil conn shares a fresh session.
+{{- end }}
+func Handlers(i API{{ if .Options.Sessions }}, conn *Conn{{ end }}) map[string]func(json.RawMessage) (interface{}, int) {
+	{{ if .Options.Sessions }}
+	if conn == nil {
+		conn = newConn()
+	}
+	{{ end }}
+	handlers := make(map[string]func(json.RawMessage) (interface{}, int), len(messageHandlers))
+	for msg, h := range messageHandlers {
+		h := h
+		handlers[msg] = func(data json.RawMessage) (interface{}, int) {
+			return h(context.Background(), i, {{ if .Options.Sessions }}conn, {{ end }}data)
+		}
+	}
+	return handlers
+}
+
+// msg names of all messages
+const (
+	{{- range .Messages }}
+	Msg{{ .Name }} = "{{ .Msg }}"
+	{{- end }}
+)
+
+// Returns the sorted msg names of all messages in spec, e.g. to pre-register per message metrics.
+// Labelling metrics by these names keeps their cardinality bounded, unknown messages are never dispatched.
+func Messages() []string {
+	return []string{
+		{{- range .Messages }}
+		Msg{{ .Name }},
+		{{- end }}
+	}
+}
+
+// dispatching logic shared by all protocols, messages denied by a non-nil limiter are not dispatched.
+// Handlers exceeding a positive timeout are abandoned, see dispatchWithTimeout.
+func dispatchMessage(ctx context.Context, i API, l Limiter, timeout time.Duration, {{ if .Options.Sessions }}conn *Conn, {{ end }}in []byte) (interface{}, int, abandonedHandler) {
+	// parse message
+	var m message
+	err := json.Unmarshal(in, &m)
+	if err != nil {
+		return UnparsableRequestErrorMessage, http.StatusUnprocessableEntity, nil
+	}
+
+	// limiting
+	if l != nil && !l.Allow(m.Msg) {
+		return TooManyRequestsErrorMessage, http.StatusTooManyRequests, nil
+	}
+
+	h, ok := messageHandlers[m.Msg]
+	if !ok {
+		// unknown msg
+		return UnknownMessageErrorMessage, http.StatusNotFound, nil
+	}
+	{{- if .MetricsRoute }}
+	start := time.Now()
+	{{- end }}
+	out, statusCode, abandoned := dispatchWithTimeout(ctx, timeout, func(ctx context.Context) (interface{}, int) {
+		return h(ctx, i, {{ if .Options.Sessions }}conn, {{ end }}m.Data)
+	})
+	{{- if .MetricsRoute }}
+	// the answered status, which is 503 for abandoned handlers
+	metrics.observe(m.Msg, statusCode, time.Since(start))
+	{{- end }}
+	return out, statusCode, abandoned
+}
+
+// handler per msg
+var messageHandlers = map[string]func(ctx context.Context, i API, {{ if .Options.Sessions }}conn *Conn, {{ end }}data json.RawMessage) (interface{}, int){
+	{{- if .SpecJSONRoute }}
+	"fetchSpec": handleFetchSpec,
+	{{- end }}
+	{{- range .Messages }}
+	Msg{{ .Name }}: handle{{ .Name }},
+	{{- end }}
+}
+
+// PayloadLogger receives the payloads of every message with APIOptions.PayloadLogger set.
+// writeOnly properties, e.g. passwords, are redacted in both payloads.
+type PayloadLogger interface {
+	LogPayloads(in []byte, out []byte, statusCode int)
+}
+
+// RequestPayloadLogger is a PayloadLogger additionally receiving the request ID of the message,
+// which is called instead of LogPayloads. The request ID is empty for websocket messages.
+type RequestPayloadLogger interface {
+	PayloadLogger
+	LogRequestPayloads(requestID string, in []byte, out []byte, statusCode int)
+}
+
+// redactors removing writeOnly properties from the data of inbound messages by msg, unparsable data is dropped
+var inboundRedactors = map[string]func(data json.RawMessage) interface{}{
+	{{- range .Messages }}
+	{{- if and .InSchema (WriteOnlyRestricted .InSchema) }}
+	Msg{{ .Name }}: func(data json.RawMessage) interface{} {
+		var d {{ .InSchema.Name }}
+		if json.Unmarshal(data, &d) != nil {
+			return nil
+		}
+		return d.withoutWriteOnly()
+	},
+	{{- end }}
+	{{- end }}
+}
+
+// returns an inbound message without writeOnly properties, messages with unparsable envelopes are returned as is
+func redactInbound(in []byte) []byte {
+	var m message
+	if json.Unmarshal(in, &m) != nil {
+		return in
+	}
+	redact, ok := inboundRedactors[m.Msg]
+	if !ok {
+		return in
+	}
+	b, err := json.Marshal(outMessage{Msg: m.Msg, Data: redact(m.Data)})
+	if err != nil {
+		return in
+	}
+	return b
+}
+
+{{ if .SpecJSONRoute }}
+// built-in fetchSpec
+func handleFetchSpec(ctx context.Context, i API, {{ if .Options.Sessions }}conn *Conn, {{ end }}data json.RawMessage) (interface{}, int) {
+	return outMessage{Msg: "spec", Data: json.RawMessage(newEmbeddedSpec())}, http.StatusOK
+}
+{{ end }}
+
+{{ range .Messages }}
+// {{ .Name }}
+func handle{{ .Name }}(ctx context.Context, i API, {{ if $.Options.Sessions }}conn *Conn, {{ end }}raw json.RawMessage) (interface{}, int) {
+	var err error
+
+	{{ if InType . }}
+	// parse data
+	var data {{ InType . }}
+	err = json.Unmarshal(raw, &data)
+	if err != nil {
+		return UnparsableRequestErrorMessage, http.StatusUnprocessableEntity
+	}
+
+	{{ if $.Options.AllValidationErrors }}
+	// all failed validations at once
+	var errs validationErrors
+	errs = errs.add(data.Validate())
+	{{ if ValueValidated .InSchema }}
+	errs = errs.add(data.validateValues())
+	{{ end }}
+	{{ if ReadOnlyValidated .InSchema }}
+	errs = errs.add(data.validateReadOnly())
+	{{ end }}
+	{{ if PresenceValidated .InSchema }}
+	errs = errs.add(data.validatePresence())
+	{{ end }}
+	err = errs.err()
+	if err != nil {
+		return newErrorMessage(err.Error()), http.StatusUnprocessableEntity
+	}
+	{{ else }}
+	err = data.Validate()
+	if err != nil {
+		return newErrorMessage(err.Error()), http.StatusUnprocessableEntity
+	}
+	{{ if ValueValidated .InSchema }}
+	err = data.validateValues()
+	if err != nil {
+		return newErrorMessage(err.Error()), http.StatusUnprocessableEntity
+	}
+	{{ end }}
+	{{ if ReadOnlyValidated .InSchema }}
+	err = data.validateReadOnly()
+	if err != nil {
+		return newErrorMessage(err.Error()), http.StatusUnprocessableEntity
+	}
+	{{ end }}
+	{{ if PresenceValidated .InSchema }}
+	err = data.validatePresence()
+	if err != nil {
+		return newErrorMessage(err.Error()), http.StatusUnprocessableEntity
+	}
+	{{ end }}
+	{{ end }}
+	{{ end }}
+
+	// dispatch message
+	{{ if InType . }}
+		{{ if .OutSchemas }}
+	outs, err := i.{{ .Name }}({{ if $.Options.Context }}ctx{{ if or $.Options.Sessions (InType .) }}, {{ end }}{{ end }}{{ if $.Options.Sessions }}conn, {{ end }}&data)
+		{{ else }}
+	err = i.{{ .Name }}({{ if $.Options.Context }}ctx{{ if or $.Options.Sessions (InType .) }}, {{ end }}{{ end }}{{ if $.Options.Sessions }}conn, {{ end }}&data)
+		{{ end }}
+	{{ else }}
+		{{ if .OutSchemas }}
+	outs, err := i.{{ .Name }}({{ if $.Options.Context }}ctx{{ if or $.Options.Sessions (InType .) }}, {{ end }}{{ end }}{{ if $.Options.Sessions }}conn{{ end }})
+		{{ else }}
+	err = i.{{ .Name }}({{ if $.Options.Context }}ctx{{ if or $.Options.Sessions (InType .) }}, {{ end }}{{ end }}{{ if $.Options.Sessions }}conn{{ end }})
+		{{ end }}
+	{{ end }}
+	{{ if and .OutSchemas $.Options.OutsOnErrorStatus }}
+	// outs returned along with an error are answered nevertheless
+	status := http.StatusOK
+	if err != nil && outs != nil {
+		status = {{ $.Options.OutsOnErrorStatus }}
+		err = nil
+	}
+	{{ end }}
+	if err != nil {
+		return InternalErrorMessage, http.StatusInternalServerError
+	}
+
+	{{ if .OutSchemas }}
+	// handler returned nothing
+	if outs == nil {
+		return InternalErrorMessage, http.StatusInternalServerError
+	}
+
+	{{ if .Batch }}
+	// all non-nil outs as a batch
+	var batch outBatch
+	{{ range .OutSchemas }}
+	if outs.{{ .Name }} != nil {
+		err = outs.{{ .Name }}.Validate()
+		if err != nil {
+			return InternalErrorMessage, http.StatusInternalServerError
+		}
+		batch = append(batch, newValueMessage("{{ .JSONName }}", outs.{{ .Name }}))
+	}
+	{{ end }}
+	if len(batch) > 0 {
+		return batch, {{ if $.Options.OutsOnErrorStatus }}status{{ else }}http.StatusOK{{ end }}
+	}
+	{{ else }}
+	// select the first non-nil out
+	{{ range .OutSchemas }}
+	if outs.{{ .Name }} != nil {
+		err = outs.{{ .Name }}.Validate()
+		if err != nil {
+			return InternalErrorMessage, http.StatusInternalServerError
+		}
+		return newValueMessage("{{ .JSONName }}", outs.{{ .Name }}), {{ if $.Options.OutsOnErrorStatus }}status{{ else }}http.StatusOK{{ end }}
+	}
+	{{ end }}
+	{{ end }}
+
+	// no outs and no error
+	return InternalErrorMessage, http.StatusInternalServerError
+	{{ else }}
+	return nil, http.StatusOK
+	{{ end }}
+}
+{{ end }}
+
+{{ if (index .Endpoints "http") }}
+// reads a request body, decoding gzip and deflate content encodings.
+// Failures return the error message and status code to respond with.
+func readRequestBody(r *http.Request) ([]byte, interface{}, int) {
+	{{- if .FormMessages }}
+	// multipart forms of form messages
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "multipart/form-data" {
+		return readFormBody(r)
+	}
+	{{- end }}
+	var body io.ReadCloser
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, InternalErrorMessage, http.StatusInternalServerError
+		}
+		return b, nil, http.StatusOK
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, CorruptEncodingErrorMessage, http.StatusBadRequest
+		}
+		body = zr
+	case "deflate":
+		zr, err := zlib.NewReader(r.Body)
 		if err != nil {
-			return UnparsableRequestErrorMessage, http.StatusUnprocessableEntity
+			return nil, CorruptEncodingErrorMessage, http.StatusBadRequest
 		}
-		
-		{{ range .Messages }} 
-		// {{ .Name }}
-		if m.Msg == "{{ .Msg }}" {
-
-			{{ if .InSchema }}
-			// parse data
-			var data {{ .InSchema.Name }}
-			err = json.Unmarshal(m.Data, &data)
-			if err != nil {
-				return UnparsableRequestErrorMessage, http.StatusUnprocessableEntity
-			}
-
-			err = data.Validate()
-			if err != nil {
-				return newErrorMessage(err.Error()), http.StatusUnprocessableEntity
-			}
-			{{ end }}
+		body = zr
+	default:
+		return nil, UnsupportedEncodingErrorMessage, http.StatusUnsupportedMediaType
+	}
+	defer body.Close()
 
-			// dispatch message
-			{{ if .InSchema }}
-				{{ if .OutSchemas }}
-			outs, err := i.{{ .Name }}(&data)
-				{{ else }}
-			err = i.{{ .Name }}(&data)
-				{{ end }}
-			{{ else }}
-				{{ if .OutSchemas }}
-			outs, err := i.{{ .Name }}()
-				{{ else }}
-			err = i.{{ .Name }}()
-				{{ end }}
-			{{ end }}
-			if err != nil {
-				return InternalErrorMessage, http.StatusInternalServerError
-			}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, CorruptEncodingErrorMessage, http.StatusBadRequest
+	}
+	return b, nil, http.StatusOK
+}
+{{ end }}
 
-			{{ if .OutSchemas }}
-			// handler returned nothing
-			if outs == nil {
-				return InternalErrorMessage, http.StatusInternalServerError
-			}
+func RegisterRoutesWithOptions(mux Router, i API, o APIOptions) {
+	{{ if .Options.Versions }}
+	// every route under each version
+	mux = versionedRouter{mux}
+	{{ end }}
 
-			// select the first non-nil out
-			{{ range .OutSchemas }}
-			if outs.{{ .Name }} != nil {
-				err = outs.{{ .Name }}.Validate()
-				if err != nil {
-					return InternalErrorMessage, http.StatusInternalServerError
-				}
-				return newValueMessage("{{ .JSONName }}", outs.{{ .Name }}), http.StatusOK
+	// processing logic, the request ID of http messages is passed to the payload logger.
+	// The returned abandonedHandler is non-nil for timed out handlers.
+	processMessage := func(ctx context.Context, {{ if .Options.Sessions }}conn *Conn, {{ end }}reqID string, in []byte) (interface{}, int, abandonedHandler) {
+		out, statusCode, abandoned := dispatchMessage(ctx, i, o.Limiter, o.RequestTimeout, {{ if .Options.Sessions }}conn, {{ end }}in)
+		if o.PayloadLogger != nil {
+			b, _ := json.Marshal(out)
+			if rl, ok := o.PayloadLogger.(RequestPayloadLogger); ok {
+				rl.LogRequestPayloads(reqID, redactInbound(in), b, statusCode)
+			} else {
+				o.PayloadLogger.LogPayloads(redactInbound(in), b, statusCode)
 			}
-			{{ end }}
-				
-			// no outs and no error
-			return InternalErrorMessage, http.StatusInternalServerError
-			{{ else }}
-			return nil, http.StatusOK
-			{{ end }}
 		}
-		{{ end }}
-		
-		// unknown msg
-		return UnknownMessageErrorMessage, http.StatusNotFound
+		return out, statusCode, abandoned
 	}
 
+	{{ if .SpecJSONRoute }}
 	// GET /spec.json
-  mux.HandleFunc("{{ SubstringRight .Endpoints.http.EscapedPath 5 }}/spec.json", func(w http.ResponseWriter, r *http.Request) {
+  mux.HandleFunc("{{ .SpecJSONRoute }}", func(w http.ResponseWriter, r *http.Request) {
 		enc := json.NewEncoder(w)
 		enc.SetIndent("", "  ")
 		
 		// headers
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match, Access-Control-Allow-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", "GET")
+		w.Header().Set("ETag", embeddedSpecETag)
+
+		// handle OPTIONS
+		if r.Method == "OPTIONS" {
+			if maxAge := preflightMaxAge(o.PreflightMaxAge); maxAge != "" {
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 
 		// ensure GET
 		if r.Method != "GET" {
@@ -378,30 +3313,124 @@ func NewAPIMux(i API) *http.ServeMux {
 			return
 		}
 
+		// conditional GET
+		if etagMatches(r.Header.Get("If-None-Match"), embeddedSpecETag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 		enc.Encode(json.RawMessage(newEmbeddedSpec()))
 		return
 	})
-	
+	{{ end }}
+
+	{{ if .SpecHTMLRoute }}
 	// GET /spec
-  mux.HandleFunc("{{ SubstringRight .Endpoints.http.EscapedPath 5 }}/spec", func(w http.ResponseWriter, r *http.Request) {
+  mux.HandleFunc("{{ .SpecHTMLRoute }}", func(w http.ResponseWriter, r *http.Request) {
 		enc := json.NewEncoder(w)
 		enc.SetIndent("", "  ")
 		
 		// headers
 		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match, Access-Control-Allow-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", "GET")
+		w.Header().Set("ETag", embeddedHTMLSpecETag)
+
+		// handle OPTIONS
+		if r.Method == "OPTIONS" {
+			if maxAge := preflightMaxAge(o.PreflightMaxAge); maxAge != "" {
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 
 		// ensure GET
 		if r.Method != "GET" {
+			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			enc.Encode(InvalidMethodErrorMessage)
 			return
 		}
 
+		// conditional GET
+		if etagMatches(r.Header.Get("If-None-Match"), embeddedHTMLSpecETag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 		w.Write(newEmbeddedHTMLSpec())
 		return
 	})
+	{{ end }}
+
+	{{ if .JSONRPCRoute }}
+	// POST /jsonrpc
+	mux.HandleFunc("{{ .JSONRPCRoute }}", func(w http.ResponseWriter, r *http.Request) {
+		reqID := requestID(r)
+		w.Header().Set(RequestIDHeader, reqID)
+		serveJSONRPC(w, r, func(in []byte) (interface{}, int) {
+			out, statusCode, _ := processMessage(r.Context(), {{ if .Options.Sessions }}newConn(), {{ end }}reqID, in)
+			return out, statusCode
+		})
+	})
+	{{ end }}
+
+	{{ if .HealthRoute }}
+	// GET /health
+	mux.HandleFunc("{{ .HealthRoute }}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Allow", "GET, OPTIONS")
+
+		// handle OPTIONS
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// ensure GET
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(InvalidMethodErrorMessage)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("{\"status\":\"ok\"}\n"))
+	})
+	{{ end }}
+
+	{{ if .MetricsRoute }}
+	// GET /metrics
+	mux.HandleFunc("{{ .MetricsRoute }}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Allow", "GET, OPTIONS")
+
+		// handle OPTIONS
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// ensure GET
+		if r.Method != "GET" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(InvalidMethodErrorMessage)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.WriteHeader(http.StatusOK)
+		metrics.write(w)
+	})
+	{{ end }}
 
 	{{ if (index .Endpoints "http") }}
 	// protocol: http
@@ -413,12 +3442,21 @@ func NewAPIMux(i API) *http.ServeMux {
 
 		// headers
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Allow", "POST, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Access-Control-Allow-Headers")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Encoding, X-Request-ID, Access-Control-Allow-Headers")
 		w.Header().Set("Access-Control-Allow-Methods", "POST")
 
+		// request ID of the client or a new one, echoed for tracing
+		reqID := requestID(r)
+		w.Header().Set(RequestIDHeader, reqID)
+		w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
+
 		// handle OPTIONS
 		if r.Method == "OPTIONS" {
+			if maxAge := preflightMaxAge(o.PreflightMaxAge); maxAge != "" {
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+			}
 			w.WriteHeader(http.StatusOK)
 			return
 		}
@@ -430,15 +3468,45 @@ func NewAPIMux(i API) *http.ServeMux {
 			return
 		}
 		
-		// parse message
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+		// parse message, decoding compressed bodies
+		body, errMsg, statusCode := readRequestBody(r)
+		if errMsg != nil {
+			w.WriteHeader(statusCode)
+			enc.Encode(errMsg)
 			return
 		}
+
+		// bare request without body: msg given as query parameter
+		if len(body) == 0 && r.URL.Query().Get("msg") != "" {
+			body, err = json.Marshal(message{Msg: r.URL.Query().Get("msg")})
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				enc.Encode(InternalErrorMessage)
+				return
+			}
+		}
 		
 		// process message
-		out, statusCode := processMessage(body)
+		out, statusCode, _ := processMessage(r.Context(), {{ if .Options.Sessions }}newConn(), {{ end }}reqID, body)
+		{{ if .MessageHeaders }}
+		// static headers of the message
+		var m message
+		if statusCode == http.StatusOK && json.Unmarshal(body, &m) == nil {
+			for k, v := range messageHeaders[m.Msg] {
+				w.Header().Set(k, v)
+			}
+		}
+		{{ end }}
+		{{ if .Options.StreamArrays }}
+		// stream array outs
+		if om, ok := out.(outMessage); ok && statusCode == http.StatusOK {
+			if s, ok := om.Data.(elementStreamer); ok {
+				w.WriteHeader(statusCode)
+				streamMessage(w, om, s)
+				return
+			}
+		}
+		{{ end }}
 		w.WriteHeader(statusCode)
 		enc.Encode(out)
 	})
@@ -451,42 +3519,463 @@ func NewAPIMux(i API) *http.ServeMux {
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
 		CheckOrigin: nil,
+		Subprotocols: o.Subprotocols,
+		// failed handshakes are answered with an error message
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			enc.Encode(newErrorMessage(reason.Error()))
+		},
 	}
 
 	// GET /websocket
   mux.HandleFunc("{{ .Endpoints.websocket.EscapedPath }}", func(w http.ResponseWriter, r *http.Request) {
 		var err error
-		enc := json.NewEncoder(w)
-		enc.SetIndent("", "  ")
 
+		// handle OPTIONS
+		if r.Method == "OPTIONS" {
+			w.Header().Set("Allow", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Headers", "Connection, Upgrade, Sec-WebSocket-Key, Sec-WebSocket-Version, Sec-WebSocket-Protocol")
+			w.Header().Set("Access-Control-Allow-Methods", "GET")
+			if maxAge := preflightMaxAge(o.PreflightMaxAge); maxAge != "" {
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// ensure GET, the upgrader checks the handshake headers first
+		if r.Method != "GET" {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Allow", "GET, OPTIONS")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			enc.Encode(newErrorMessage("only GET method allowed"))
+			return
+		}
+
+		// upgrader answers failed handshakes
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			enc.Encode(InternalErrorMessage)
 			return
 		}
+		defer conn.Close()
+
+		// oversized messages fail the read, after a close with status 1009 (message too big) is sent
+		switch {
+		case o.MaxMessageSize > 0:
+			conn.SetReadLimit(o.MaxMessageSize)
+		case o.MaxMessageSize == 0:
+			conn.SetReadLimit(DefaultMaxMessageSize)
+		}
 	
-		// read/write loop
+		{{ if .Options.Sessions }}
+		// session of connection
+		session := newConn()
+		{{ end }}
+
+		// register at hub
+		wc := &wsConn{conn: conn}
+		if o.Hub != nil {
+			id := o.Hub.add(wc)
+			defer o.Hub.remove(id)
+			{{ if .Options.Sessions }}session.id = id{{ end }}
+		}
+
+		// read/write loop, messages are handled one at a time: a timed out handler
+		// is awaited before the next message is dispatched
+		var abandoned abandonedHandler
 		for {
-			_, data, err := conn.ReadMessage()
+			messageType, data, err := conn.ReadMessage()
 			if err != nil {
 				return
 			}
+			abandoned.wait()
+
+			// binary frames are answered in kind
+			if messageType == websocket.BinaryMessage {
+				var out interface{} = UnparsableRequestErrorMessage
+				statusCode := http.StatusUnprocessableEntity
+				in, err := decodeBinaryFrame(o.BinaryCodec, data)
+				if err == nil {
+					out, statusCode, abandoned = processMessage(r.Context(), {{ if .Options.Sessions }}session, {{ end }}"", in)
+				}
+				{{ if .BatchMessages }}
+				// batched outs are answered with a frame each
+				for _, out := range outFrames(out) {
+					outMsg, err := encodeBinaryFrame(o.BinaryCodec, out)
+					if err == nil {
+						wc.write(websocket.BinaryMessage, outMsg)
+					}
+				}
+				{{ else }}
+				outMsg, err := encodeBinaryFrame(o.BinaryCodec, out)
+				if err == nil {
+					wc.write(websocket.BinaryMessage, outMsg)
+				}
+				{{ end }}
+				if code, reason, ok := closeReason(in, statusCode); ok {
+					wc.write(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+					return
+				}
+				continue
+			}
 		
 			// process message
-			out, _ := processMessage(data)
+			var out interface{}
+			var statusCode int
+			out, statusCode, abandoned = processMessage(r.Context(), {{ if .Options.Sessions }}session, {{ end }}"", data)
+			{{ if .BatchMessages }}
+			// batched outs are answered with a frame each
+			for _, out := range outFrames(out) {
+				outMsg, err := json.MarshalIndent(out, "", "  ")
+				if err == nil {
+					wc.write(websocket.TextMessage, outMsg)
+				}
+			}
+			{{ else }}
 			outMsg, err := json.MarshalIndent(out, "", "  ")
 			if err == nil {
-				conn.WriteMessage(websocket.TextMessage, outMsg)
+				wc.write(websocket.TextMessage, outMsg)
+			}
+			{{ end }}
+
+			// fatal errors close the connection after answering
+			if code, reason, ok := closeReason(data, statusCode); ok {
+				wc.write(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+				return
 			}
 		}
 	})
 	{{ end }}
-
-	return mux
 }
 `
 
+// Adds a deprecation comment to types of definitions marked "deprecated"
+func deprecateTypes(s *jsonmsg.Spec, src []byte) []byte {
+	for _, k := range definitionKeys(s) {
+		if !s.Deprecated[k] {
+			continue
+		}
+		d := s.Definitions[k]
+		re := regexp.MustCompile(`(?m)^((?://.*\n)*)type ` + regexp.QuoteMeta(d.Name) + ` `)
+		src = re.ReplaceAllFunc(src, func(decl []byte) []byte {
+			sub := re.FindSubmatch(decl)
+			dep := fmt.Sprintf("// Deprecated: %v is deprecated.\ntype %v ", d.JSONName, d.Name)
+			// the deprecation is a paragraph of its own in an existing doc comment
+			if len(sub[1]) > 0 {
+				dep = "//\n" + dep
+			}
+			return append(append([]byte{}, sub[1]...), dep...)
+		})
+	}
+	return src
+}
+
+// Returns the definitions to generate types from, required nullable properties are not required by Validate
+// as an explicit null decodes as nil, validatePresence rejects them if absent
+func goTypesIndex(s *jsonmsg.Spec) *jsonschema.Index {
+	nullable := requiredNullable(s)
+	if len(nullable) == 0 {
+		return &s.Definitions
+	}
+
+	idx := make(jsonschema.Index, len(s.Definitions))
+	for k, d := range s.Definitions {
+		idx[k] = d
+	}
+	for _, k := range definitionKeys(s) {
+		d := s.Definitions[k]
+		var req []string
+		for _, r := range d.Required {
+			if !nullable[k+"/properties/"+r] {
+				req = append(req, r)
+			}
+		}
+		if len(req) != len(d.Required) {
+			c := *d
+			c.Required = req
+			idx[k] = &c
+		}
+	}
+	return &idx
+}
+
+// Adds a present field to the types of definitions with required nullable properties, recording which are
+// present in decoded JSON. Their fields drop omitempty, so nil encodes as explicit null.
+func presentFields(s *jsonmsg.Spec, src []byte) ([]byte, error) {
+	nullable := requiredNullable(s)
+	if len(nullable) == 0 {
+		return src, nil
+	}
+
+	prefix := []byte("package types\n")
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", append(prefix, src...), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// replacements of src by offset
+	type edit struct {
+		start, end int
+		s          string
+	}
+	var edits []edit
+	offset := func(p token.Pos) int {
+		return fset.Position(p).Offset - len(prefix)
+	}
+	for _, k := range definitionKeys(s) {
+		d := s.Definitions[k]
+		var field *ast.Field
+		for _, r := range d.Required {
+			if !nullable[k+"/properties/"+r] {
+				continue
+			}
+			field, _ = structField(f, d.Name, r)
+			if field == nil {
+				return nil, fmt.Errorf("golang: %v/properties/%v is not generated as field of %v", k, r, d.Name)
+			}
+			tag, _ := strconv.Unquote(field.Tag.Value)
+			opts := reflect.StructTag(tag).Get("json")
+			tag = strings.Replace(tag, `json:"`+opts+`"`, `json:"`+r+`"`, 1)
+			edits = append(edits, edit{offset(field.Tag.Pos()), offset(field.Tag.End()), "`" + tag + "`"})
+		}
+		if field == nil {
+			continue
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok && ts.Name.Name == d.Name {
+					end := offset(st.Fields.Closing)
+					edits = append(edits, edit{end, end, "\n\t// required nullable properties present in decoded JSON, even if null\n\tpresent map[string]bool\n"})
+				}
+			}
+		}
+	}
+
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+	b := append([]byte(nil), src...)
+	for _, e := range edits {
+		b = append(b[:e.start], append([]byte(e.s), b[e.end:]...)...)
+	}
+	return format.Source(b)
+}
+
+// Returns the go field name of a property of the definition at ptr, overridden by "x-go-name"
+func goFieldName(s *jsonmsg.Spec, ptr string, p string) string {
+	if n, ok := s.GoNames[ptr+"/properties/"+p]; ok {
+		return n
+	}
+	return jsonmsg.GoName(p)
+}
+
+// Renames fields of definition types overridden by "x-go-name", including their uses in methods of the types.
+// Fields are identified by their json tag, so the JSON keys stay as in spec.
+func renameFields(s *jsonmsg.Spec, src []byte) ([]byte, error) {
+	if len(s.GoNames) == 0 {
+		return src, nil
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", append([]byte("package types\n"), src...), parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// new field names by old field name by type
+	renames := make(map[string]map[string]string)
+	for _, k := range definitionKeys(s) {
+		d := s.Definitions[k]
+		for p := range d.Properties {
+			n, ok := s.GoNames[k+"/properties/"+p]
+			if !ok {
+				continue
+			}
+			if !token.IsIdentifier(n) || !ast.IsExported(n) {
+				return nil, fmt.Errorf("golang: x-go-name %q of %v/properties/%v is not an exported Go identifier", n, k, p)
+			}
+			field, fields := structField(f, d.Name, p)
+			if field == nil {
+				return nil, fmt.Errorf("golang: %v/properties/%v is not generated as field of %v", k, p, d.Name)
+			}
+			if fields[n] && field.Names[0].Name != n {
+				return nil, fmt.Errorf("golang: x-go-name %q of %v/properties/%v collides with another field of %v", n, k, p, d.Name)
+			}
+			if renames[d.Name] == nil {
+				renames[d.Name] = make(map[string]string)
+			}
+			renames[d.Name][field.Names[0].Name] = n
+			field.Names[0].Name = n
+		}
+	}
+
+	// uses within methods
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || len(fn.Recv.List) == 0 || len(fn.Recv.List[0].Names) == 0 {
+			continue
+		}
+		typ := fn.Recv.List[0].Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		ident, ok := typ.(*ast.Ident)
+		if !ok || renames[ident.Name] == nil {
+			continue
+		}
+		recv := fn.Recv.List[0].Names[0].Name
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == recv {
+					if name, ok := renames[ident.Name][sel.Sel.Name]; ok {
+						sel.Sel.Name = name
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	w := &bytes.Buffer{}
+	err = printer.Fprint(w, fset, f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimPrefix(w.Bytes(), []byte("package types\n")), nil
+}
+
+// Returns the field of a struct type with a json tag for key and the names of all fields of the type
+func structField(f *ast.File, typ string, key string) (*ast.Field, map[string]bool) {
+	var field *ast.Field
+	fields := make(map[string]bool)
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || ts.Name.Name != typ {
+				continue
+			}
+			for _, fl := range st.Fields.List {
+				for _, n := range fl.Names {
+					fields[n.Name] = true
+				}
+				if fl.Tag == nil || len(fl.Names) != 1 {
+					continue
+				}
+				tag, err := strconv.Unquote(fl.Tag.Value)
+				if err != nil {
+					continue
+				}
+				name := strings.Split(reflect.StructTag(tag).Get("json"), ",")[0]
+				if name == key {
+					field = fl
+				}
+			}
+		}
+	}
+	return field, fields
+}
+
+// creates a go friendly parameter name from a property name
+func goParamName(p string) string {
+	n := regexp.MustCompile("[^a-zA-Z0-9_]").ReplaceAllString(p, "")
+	if n == "" || token.Lookup(n).IsKeyword() || types.Universe.Lookup(n) != nil || unicode.IsDigit(rune(n[0])) {
+		n = "p" + jsonmsg.GoName(n)
+	}
+	return n
+}
+
+// Checks that definition types do not collide with types generated for the server
+func checkTypeNames(s *jsonmsg.Spec, o Options) error {
+	generated := map[string]string{
+		"API":                    "API interface",
+		"APIOptions":             "APIOptions type",
+		"Router":                 "Router interface",
+		"ProcessMessage":         "ProcessMessage function",
+		"Handlers":               "Handlers function",
+		"Messages":               "Messages function",
+		"DefaultPreflightMaxAge": "DefaultPreflightMaxAge constant",
+		"Limiter":                "Limiter interface",
+		"PayloadLogger":          "PayloadLogger interface",
+		"RequestPayloadLogger":   "RequestPayloadLogger interface",
+		"Envelope":               "Envelope type",
+		"NewEnvelope":            "NewEnvelope function",
+	}
+	if _, ok := s.Endpoints["http"]; ok {
+		generated["RequestIDHeader"] = "http RequestIDHeader constant"
+	}
+	if _, ok := s.Endpoints["websocket"]; ok {
+		generated["Hub"] = "websocket Hub type"
+		generated["BinaryCodec"] = "websocket BinaryCodec interface"
+		generated["DefaultMaxMessageSize"] = "websocket DefaultMaxMessageSize constant"
+	}
+	if o.Sessions {
+		generated["Conn"] = "session Conn type"
+	}
+	if o.Client {
+		generated["Client"] = "Client type"
+		generated["ResponseError"] = "client ResponseError type"
+		generated["Transport"] = "client Transport interface"
+		generated["HTTPTransport"] = "client HTTPTransport type"
+		if _, ok := s.Endpoints["websocket"]; ok {
+			generated["WebsocketTransport"] = "client WebsocketTransport type"
+		}
+	}
+	for _, m := range s.Messages {
+		generated[m.Name+"Outs"] = fmt.Sprintf("outs type of message %q", m.Msg)
+		generated["Msg"+m.Name] = fmt.Sprintf("msg constant of message %q", m.Msg)
+		if len(m.InSchemas) > 0 {
+			generated[inType(m)] = fmt.Sprintf("in type of message %q", m.Msg)
+		}
+	}
+
+	for _, k := range definitionKeys(s) {
+		d := s.Definitions[k]
+		if what, ok := generated[d.Name]; ok {
+			return fmt.Errorf("golang: definition %v is generated as type %v, which collides with the %v", k, d.Name, what)
+		}
+	}
+	return nil
+}
+
+// Checks that the outs of every message are sent with distinct msg names,
+// otherwise clients could not tell which out a response holds
+func checkOutNames(s *jsonmsg.Spec) error {
+	var keys []string
+	for k, _ := range s.Messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		m := s.Messages[k]
+		for i, o := range m.OutSchemas {
+			for j := 0; j < i; j++ {
+				if m.OutSchemas[j].JSONName == o.JSONName {
+					return fmt.Errorf("golang: message %q outs %v and %v are both sent as msg %q", k, m.Outs[j], m.Outs[i], o.JSONName)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // Returns the union of string slices
 func unionStrings(s ...[]string) []string {
 	m := make(map[string]bool)
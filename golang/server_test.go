@@ -2,10 +2,19 @@ package golang
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/tfkhsr/jsonmsg"
@@ -23,7 +32,7 @@ type API interface {
 	Logout(*Session) (*LogoutOuts, error)
 }
 `
-	typ, err := generateInterfaceType(spc)
+	typ, err := generateInterfaceType(spc, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -32,6 +41,328 @@ type API interface {
 	}
 }
 
+func TestServerPackageStamp(t *testing.T) {
+	spc, err := jsonmsg.Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := ServerPackageSrc(spc, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := strings.SplitN(string(src), "\n", 2)[0]
+	stamp := regexp.MustCompile(`^// Code generated by jsonmsg v(\d+\.\d+\.\d+) from spec sha256:([0-9a-f]{64}); DO NOT EDIT\.$`)
+	m := stamp.FindStringSubmatch(line)
+	if m == nil {
+		t.Fatalf("first line %q is not a generated code stamp", line)
+	}
+	if m[1] != jsonmsg.Version {
+		t.Fatalf("stamped version was %v, expected %v", m[1], jsonmsg.Version)
+	}
+	if m[2] != fmt.Sprintf("%x", sha256.Sum256(spc.Raw)) {
+		t.Fatalf("stamped hash %v does not match the spec", m[2])
+	}
+
+	// a changed spec changes the stamp
+	spc2, err := jsonmsg.Parse([]byte(fixture.TestSchemaSimpleLoginHTTPandWebsocket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jsonmsg.GeneratedStamp(spc2) == jsonmsg.GeneratedStamp(spc) {
+		t.Fatal("different specs should have different stamps")
+	}
+}
+
+func TestServerPackageGeneratedMarker(t *testing.T) {
+	generated := regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+	schemas := []string{
+		fixture.TestSchemaSimpleLogin,
+		fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+		fixture.TestSchemaMessageLocalSchemas,
+	}
+	options := []Options{
+		{},
+		{Sessions: true},
+		{Formatter: NoFormat},
+	}
+	for _, raw := range schemas {
+		spc, err := jsonmsg.Parse([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, o := range options {
+			src, err := ServerPackageSrcWithOptions(spc, "main", o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			line := strings.SplitN(strings.TrimLeft(string(src), "\n"), "\n", 2)[0]
+			if !generated.MatchString(line) {
+				t.Fatalf("first line %q does not mark the file as generated", line)
+			}
+		}
+	}
+}
+
+func TestServerPackageSrcStable(t *testing.T) {
+	schemas := []string{
+		fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+		fixture.TestSchemaMessageLocalSchemas,
+		fixture.TestSchemaInlineObjectProperties,
+		fixture.TestSchemaEnumValues,
+		fixture.TestSchemaKeywordNames,
+	}
+	for _, raw := range schemas {
+		var first []byte
+		for n := 0; n < 10; n++ {
+			// parse each time, so map iteration of properties differs
+			spc, err := jsonmsg.Parse([]byte(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := ServerPackageSrcWithOptions(spc, "main", Options{Sessions: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first == nil {
+				first = src
+				continue
+			}
+			if !bytes.Equal(src, first) {
+				t.Fatalf("generation %v differs from the first one:\n%s\n\nfirst:\n%s", n, src, first)
+			}
+		}
+	}
+}
+
+func TestServerPackageImportGroups(t *testing.T) {
+	spc, err := jsonmsg.Parse([]byte(fixture.TestSchemaSimpleLoginHTTPandWebsocket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := ServerPackageSrc(spc, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := string(src)
+	start := strings.Index(block, "import (\n")
+	end := strings.Index(block, "\n)\n")
+	if start == -1 || end == -1 {
+		t.Fatalf("missing import block: %s", src)
+	}
+	groups := strings.Split(block[start+len("import (\n"):end], "\n\n")
+	if len(groups) != 2 {
+		t.Fatalf("import block should have 2 groups, but has %v: %v", len(groups), groups)
+	}
+	for _, i := range strings.Split(groups[0], "\n") {
+		if strings.Contains(i, ".") {
+			t.Fatalf("third-party import %v in stdlib group", i)
+		}
+	}
+	for _, i := range strings.Split(groups[1], "\n") {
+		if !strings.Contains(i, `"github.com/`) {
+			t.Fatalf("stdlib import %v in third-party group", i)
+		}
+	}
+}
+
+func TestFetchSpecCollision(t *testing.T) {
+	spc, err := jsonmsg.Parse([]byte(fixture.TestSchemaReservedFetchSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ServerSrc(spc)
+	if err == nil {
+		t.Fatal("message fetchSpec should collide with the built-in message")
+	}
+	if !strings.Contains(err.Error(), `"fetchSpec"`) {
+		t.Fatalf("error should name the message: %v", err)
+	}
+
+	// without spec routes there is no built-in message
+	_, err = ServerSrcWithOptions(spc, Options{SpecJSONPath: "-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHealthRouteCollision(t *testing.T) {
+	spc, err := jsonmsg.Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ServerSrcWithOptions(spc, Options{Health: true, SpecHTMLPath: "/v1/health"})
+	if err == nil {
+		t.Fatal("health check route should collide with the spec route")
+	}
+	expected := "golang: health check route /v1/health collides with a spec route"
+	if err.Error() != expected {
+		t.Fatalf("error was %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestUnsupportedProtocol(t *testing.T) {
+	spc, err := jsonmsg.Parse([]byte(fixture.TestSchemaUnsupportedProtocol))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ServerPackageSrc(spc, "main")
+	if err == nil {
+		t.Fatal("grpc endpoint should not be generated silently")
+	}
+	expected := `golang: endpoint protocol "grpc" is not supported by generated servers, supported protocols are http, websocket`
+	if err.Error() != expected {
+		t.Fatalf("error was %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	spc, err := jsonmsg.Parse([]byte(fixture.TestSchemaComments))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range []Options{{}, {StripComments: true}} {
+		src, err := ServerSrcWithOptions(spc, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if embedded := bytes.Contains(src, []byte("internal: primary key")); embedded == o.StripComments {
+			t.Fatalf("comments were embedded %v with %+v", embedded, o)
+		}
+	}
+}
+
+// A spec built programmatically generates the same server as the parsed spec, besides the stamp of the raw spec
+func TestServerSrcOfBuiltSpec(t *testing.T) {
+	object := func(props ...string) map[string]interface{} {
+		properties := make(map[string]interface{})
+		for _, p := range props {
+			properties[p] = map[string]string{"type": "string"}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	built, err := jsonmsg.NewSpec().
+		AddEndpoint("http", "http://api.specc.io/v1").
+		AddDefinition("credentials", object("name", "password")).
+		AddDefinition("session", object("id")).
+		AddDefinition("error", object("error")).
+		AddDefinition("message", object("message")).
+		AddMessage("loginWithCredentials", "#/definitions/credentials", []string{"#/definitions/session", "#/definitions/error"}, "login").
+		AddMessage("logout", "#/definitions/session", []string{"#/definitions/message"}, "logout").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := jsonmsg.Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := ServerPackageSrc(built, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ServerPackageSrc(parsed, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trim := func(b []byte) []byte {
+		return b[bytes.IndexByte(b, '\n'):]
+	}
+	if !bytes.Equal(trim(src), trim(expected)) {
+		t.Fatalf("sources of built spec differ:\n%s", src)
+	}
+}
+
+// Formats are not validated by default
+func TestFormatsNotValidatedByDefault(t *testing.T) {
+	spec, err := jsonmsg.Parse([]byte(fixture.TestSchemaFormats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := ServerSrc(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(src, []byte("validEmailFormat")) {
+		t.Fatalf("formats are validated by default:\n%s", src)
+	}
+}
+
+func TestTypeNameCollisions(t *testing.T) {
+	table := []struct {
+		Name    string
+		From    string
+		To      string
+		Options Options
+		Error   string
+	}{
+		{
+			"definition named like API interface",
+			`"credentials": {`,
+			`"api": {`,
+			Options{},
+			`golang: definition #/definitions/api is generated as type API, which collides with the API interface`,
+		},
+		{
+			"definition named like outs type",
+			`"credentials": {`,
+			`"logoutOuts": {`,
+			Options{},
+			`golang: definition #/definitions/logoutOuts is generated as type LogoutOuts, which collides with the outs type of message "logout"`,
+		},
+		{
+			"definition named like msg constant",
+			`"credentials": {`,
+			`"msgLogout": {`,
+			Options{},
+			`golang: definition #/definitions/msgLogout is generated as type MsgLogout, which collides with the msg constant of message "logout"`,
+		},
+		{
+			"definition named like session type",
+			`"credentials": {`,
+			`"conn": {`,
+			Options{Sessions: true},
+			`golang: definition #/definitions/conn is generated as type Conn, which collides with the session Conn type`,
+		},
+	}
+	for _, ts := range table {
+		raw := strings.Replace(fixture.TestSchemaSimpleLogin, ts.From, ts.To, 1)
+		raw = strings.Replace(raw, `"in": "#/definitions/credentials"`, `"in": "#/definitions/session"`, 1)
+		spc, err := jsonmsg.Parse([]byte(raw))
+		if err != nil {
+			t.Fatal(ts.Name, err)
+		}
+		_, err = ServerSrcWithOptions(spc, ts.Options)
+		if err == nil {
+			t.Fatalf("%v: should not generate", ts.Name)
+		}
+		if err.Error() != ts.Error {
+			t.Fatalf("%v: error was %q, expected %q", ts.Name, err.Error(), ts.Error)
+		}
+	}
+}
+
+func TestOutNameCollision(t *testing.T) {
+	spec, err := jsonmsg.Parse([]byte(fixture.TestSchemaOutNameCollision))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ServerSrc(spec)
+	if err == nil {
+		t.Fatal("outs sharing a json name should not generate")
+	}
+	expected := `golang: message "findUser" outs #/definitions/user and #/definitions/account/properties/user are both sent as msg "user"`
+	if err.Error() != expected {
+		t.Fatalf("error was %q, expected %q", err.Error(), expected)
+	}
+}
+
 func TestGenerateGoHTTPHandler(t *testing.T) {
 	table := []struct {
 		Name      string
@@ -50,9 +381,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"log"
-	"io"
 	"io/ioutil"
-	"bytes"
 )
 
 type Server struct{}
@@ -127,9 +456,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"log"
-	"io"
 	"io/ioutil"
-	"bytes"
 	"strings"
 	"time"
 	
@@ -254,9 +581,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"log"
-	"io"
 	"io/ioutil"
-	"bytes"
 )
 
 type Server struct{}
@@ -326,14 +651,10 @@ func main() {
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
-	"io/ioutil"
 	"log"
-	"io"
-	"bytes"
 )
 
 type Server struct{}
@@ -373,13 +694,9 @@ func main() {
 package main
 
 import (
-	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"io/ioutil"
 	"log"
-	"io"
-	"bytes"
 )
 
 type Server struct{}
@@ -413,7 +730,7 @@ func main() {
 	`,
 		},
 		{
-			"unparsable message => 422",
+			"slow handler with request timeout => 503",
 			fixture.TestSchemaSimpleLogin,
 			`
 package main
@@ -424,15 +741,16 @@ import (
 	"net/http/httptest"
 	"io/ioutil"
 	"log"
-	"io"
-	"bytes"
-	"strings"
+	"time"
 )
 
 type Server struct{}
 
 func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
-	return nil, nil
+	time.Sleep(2 * time.Second)
+	return &LoginWithCredentialsOuts{
+		Session: &Session{ID: newString("foo")},
+	}, nil
 }
 
 func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
@@ -440,48 +758,69 @@ func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
 }
 
 func main() {
-	s := httptest.NewServer(NewAPIMux(&Server{}))
+	s := httptest.NewServer(NewAPIMuxWithOptions(&Server{}, APIOptions{RequestTimeout: 50 * time.Millisecond}))
 	defer s.Close()
-	
-	res, err := http.Post(s.URL+"/v1/http", "application/json", strings.NewReader("certainly not json"))
+
+	c := Credentials{
+		Name: newString("john"),
+		Password: newString("snow"),
+	}
+
+	start := time.Now()
+	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("loginWithCredentials", c))
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if res.StatusCode != 422 {
-		log.Fatal("status code not 422")
+	if time.Since(start) > time.Second {
+		log.Fatalf("request was not timed out: %v", time.Since(start))
+	}
+
+	if res.StatusCode != 503 {
+		log.Fatalf("status code not 503, but: %v", res.StatusCode)
+	}
+
+	var rm message
+	err = json.Unmarshal(raw, &rm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var e errorData
+	err = json.Unmarshal(rm.Data, &e)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if e.Error != "timeout" {
+		log.Fatalf("error was: %s", e.Error)
 	}
 }
 	`,
 		},
 		{
-			"empty messages",
-			fixture.TestSchemaEmptyMessages,
+			"unparsable message => 422",
+			fixture.TestSchemaSimpleLogin,
 			`
 package main
 
 import (
-	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"log"
-	"io"
-	"io/ioutil"
-	"bytes"
 	"strings"
 )
 
 type Server struct{}
 
-func(s *Server) SubscribeEmpty() error {
-	return nil
-}
-
-func(s *Server) SubscribeInOnly(m *Message) error {
-	return nil
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return nil, nil
 }
 
-func(s *Server) SubscribeOutsOnly() (*SubscribeOutsOnlyOuts, error) {
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
 	return nil, nil
 }
 
@@ -489,32 +828,28 @@ func main() {
 	s := httptest.NewServer(NewAPIMux(&Server{}))
 	defer s.Close()
 	
-	res, err := http.Post(s.URL+"/v1/http", "application/json", strings.NewReader("{ \"msg\": \"subscribeEmpty\" }"))
+	res, err := http.Post(s.URL+"/v1/http", "application/json", strings.NewReader("certainly not json"))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if res.StatusCode != 200 {
-		log.Fatal("status code not 200")
+	if res.StatusCode != 422 {
+		log.Fatal("status code not 422")
 	}
 }
 	`,
 		},
 		{
-			"in messages",
+			"empty messages",
 			fixture.TestSchemaEmptyMessages,
 			`
 package main
 
 import (
-	"encoding/json"
-	"errors"
 	"net/http"
 	"net/http/httptest"
 	"log"
-	"io"
-	"io/ioutil"
-	"bytes"
+	"strings"
 )
 
 type Server struct{}
@@ -524,9 +859,6 @@ func(s *Server) SubscribeEmpty() error {
 }
 
 func(s *Server) SubscribeInOnly(m *Message) error {
-	if *m.Message != "foo" {
-		return errors.New("invalid in message")
-	}
 	return nil
 }
 
@@ -537,10 +869,8 @@ func(s *Server) SubscribeOutsOnly() (*SubscribeOutsOnlyOuts, error) {
 func main() {
 	s := httptest.NewServer(NewAPIMux(&Server{}))
 	defer s.Close()
-
-	m := &Message{Message: newString("foo")}
 	
-	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("subscribeInOnly", m))
+	res, err := http.Post(s.URL+"/v1/http", "application/json", strings.NewReader("{ \"msg\": \"subscribeEmpty\" }"))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -552,7 +882,7 @@ func main() {
 	`,
 		},
 		{
-			"response messages",
+			"empty messages with minimal envelope and bare request",
 			fixture.TestSchemaEmptyMessages,
 			`
 package main
@@ -562,9 +892,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"log"
-	"io"
-	"bytes"
 	"io/ioutil"
+	"strings"
 )
 
 type Server struct{}
@@ -585,60 +914,165 @@ func main() {
 	s := httptest.NewServer(NewAPIMux(&Server{}))
 	defer s.Close()
 
-	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("subscribeOutsOnly", nil))
+	// minimal envelope without data
+	for _, msg := range []string{"subscribeEmpty", "subscribeOutsOnly"} {
+		res, err := http.Post(s.URL+"/v1/http", "application/json", strings.NewReader("{\"msg\":\"" + msg + "\"}"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != 200 {
+			log.Fatalf("%v: status code not 200, but: %v", msg, res.StatusCode)
+		}
+	}
+
+	// bare request with msg in query
+	res, err := http.Post(s.URL+"/v1/http?msg=subscribeOutsOnly", "application/json", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
-
 	raw, err := ioutil.ReadAll(res.Body)
 	res.Body.Close()
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	if res.StatusCode != 200 {
+		log.Fatalf("status code not 200, but: %v", res.StatusCode)
+	}
+
 	var rm message
 	err = json.Unmarshal(raw, &rm)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	if res.StatusCode != 200 {
-		log.Fatal("status code not 200")
-	}
-
 	if rm.Msg != "message" {
 		log.Fatalf("response message was: %v", rm.Msg)
 	}
-	
-	var m Message
-	err = json.Unmarshal(rm.Data, &m)
+
+	// empty body without msg stays unparsable
+	res, err = http.Post(s.URL+"/v1/http", "application/json", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if *m.Message != "bar" {
-		log.Fatalf("message was: %s", m.Message)
+	res.Body.Close()
+
+	if res.StatusCode != 422 {
+		log.Fatalf("status code not 422, but: %v", res.StatusCode)
 	}
 }
-	`},
+	`,
+		},
 		{
-			"validation fail",
-			fixture.TestSchemaValidationSpec,
+			"String() returns compact json",
+			fixture.TestSchemaMessageLocalSchemas,
 			`
 package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) FindUser(q *FindUserIn) (*FindUserOuts, error) {
+	return nil, nil
+}
+
+func(s *Server) DeleteUser(q *FindUserIn) error {
+	return nil
+}
+
+func main() {
+	u := &User{ID: newString("visurgif"), Name: newString("Foo Bar")}
+
+	str := fmt.Sprintf("%s", u)
+	if !json.Valid([]byte(str)) {
+		log.Fatalf("not valid json: %s", str)
+	}
+	if str != u.String() {
+		log.Fatalf("Stringer was not used: %s", str)
+	}
+
+	var u2 User
+	err := json.Unmarshal([]byte(str), &u2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *u2.ID != "visurgif" || *u2.Name != "Foo Bar" {
+		log.Fatalf("invalid user: %s", str)
+	}
+}
+	`,
+		},
+		{
+			"constructor with required fields",
+			fixture.TestSchemaMessageLocalSchemas,
+			`
+package main
+
+import (
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) FindUser(q *FindUserIn) (*FindUserOuts, error) {
+	return nil, nil
+}
+
+func(s *Server) DeleteUser(q *FindUserIn) error {
+	return nil
+}
+
+func main() {
+	u := NewUser("visurgif", "Foo Bar")
+	err := u.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *u.ID != "visurgif" || *u.Name != "Foo Bar" {
+		log.Fatalf("invalid user: %v", u)
+	}
+
+	q := NewFindUserIn("visurgif")
+	err = q.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+	`,
+		},
+		{
+			"in messages",
+			fixture.TestSchemaEmptyMessages,
+			`
+package main
+
+import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"log"
-	"io"
-	"bytes"
-	"io/ioutil"
 )
 
 type Server struct{}
 
-func(s *Server) SayHello(m *Message) (*SayHelloOuts, error) {
+func(s *Server) SubscribeEmpty() error {
+	return nil
+}
+
+func(s *Server) SubscribeInOnly(m *Message) error {
+	if *m.Message != "foo" {
+		return errors.New("invalid in message")
+	}
+	return nil
+}
+
+func(s *Server) SubscribeOutsOnly() (*SubscribeOutsOnlyOuts, error) {
 	return nil, nil
 }
 
@@ -646,12 +1080,56 @@ func main() {
 	s := httptest.NewServer(NewAPIMux(&Server{}))
 	defer s.Close()
 
-	m := &Message{}
+	m := &Message{Message: newString("foo")}
 	
-	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("sayHello", m))
+	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("subscribeInOnly", m))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if res.StatusCode != 200 {
+		log.Fatal("status code not 200")
+	}
+}
+	`,
+		},
+		{
+			"response messages",
+			fixture.TestSchemaEmptyMessages,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+)
+
+type Server struct{}
+
+func(s *Server) SubscribeEmpty() error {
+	return nil
+}
+
+func(s *Server) SubscribeInOnly(m *Message) error {
+	return nil
+}
+
+func(s *Server) SubscribeOutsOnly() (*SubscribeOutsOnlyOuts, error) {
+	return &SubscribeOutsOnlyOuts{ Message: &Message{newString("bar")}}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("subscribeOutsOnly", nil))
 	if err != nil {
 		log.Fatal(err)
 	}
+
 	raw, err := ioutil.ReadAll(res.Body)
 	res.Body.Close()
 	if err != nil {
@@ -663,53 +1141,49 @@ func main() {
 		log.Fatal(err)
 	}
 
-	if res.StatusCode != 422 {
-		log.Fatal("status code not 422")
+	if res.StatusCode != 200 {
+		log.Fatal("status code not 200")
 	}
 
-	if rm.Msg != "error" {
+	if rm.Msg != "message" {
 		log.Fatalf("response message was: %v", rm.Msg)
 	}
 	
-	var e errorData
-	err = json.Unmarshal(rm.Data, &e)
+	var m Message
+	err = json.Unmarshal(rm.Data, &m)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if e.Error != "invalid message: missing message" {
-		log.Fatalf("error was: %s", e.Error)
+	if *m.Message != "bar" {
+		log.Fatalf("message was: %s", m.Message)
 	}
 }
-	`,
-		},
+	`},
 		{
-			"output validation fail",
+			"validation fail",
 			fixture.TestSchemaValidationSpec,
 			`
 package main
 
 import (
 	"encoding/json"
-	"errors"
 	"net/http"
 	"net/http/httptest"
 	"log"
-	"io"
-	"bytes"
 	"io/ioutil"
 )
 
 type Server struct{}
 
 func(s *Server) SayHello(m *Message) (*SayHelloOuts, error) {
-	return &SayHelloOuts{&Message{}}, nil
+	return nil, nil
 }
 
 func main() {
 	s := httptest.NewServer(NewAPIMux(&Server{}))
 	defer s.Close()
 
-	m := &Message{newString("hello")}
+	m := &Message{}
 	
 	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("sayHello", m))
 	if err != nil {
@@ -726,8 +1200,8 @@ func main() {
 		log.Fatal(err)
 	}
 
-	if res.StatusCode != 500 {
-		log.Fatal("status code not 500")
+	if res.StatusCode != 422 {
+		log.Fatal("status code not 422")
 	}
 
 	if rm.Msg != "error" {
@@ -739,152 +1213,4335 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	if e.Error != "internal error" {
+	if e.Error != "invalid message: missing message" {
 		log.Fatalf("error was: %s", e.Error)
 	}
 }
 	`,
 		},
-	}
-	for _, ts := range table {
-		spec, err := jsonmsg.Parse([]byte(ts.RawSchema))
-		if err != nil {
-			t.Fatal(ts.Name, err)
-		}
-		src, err := ServerSrc(spec)
-		if err != nil {
-			t.Fatal(ts.Name, err)
-		}
-
-		w := &bytes.Buffer{}
-		fmt.Fprintf(w, `%s`, ts.Code)
-		fmt.Fprintf(w, `%s`, src)
-
-		out, err := compileAndRun(w.Bytes())
-		if err != nil {
-			t.Fatal(ts.Name, err)
-		}
-
-		if out != "" {
-			t.Fatalf("%v: should have produced 'ok', but produced '%v'", ts.Name, out)
-		}
-	}
-}
-
-func TestGenerateGoSpecHandler(t *testing.T) {
-	table := []struct {
-		Name      string
-		RawSchema string
-		Code      string
-	}{
 		{
-			"GET /spec.json with response",
-			fixture.TestSchemaSimpleLogin,
+			"OPTIONS on all routes",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
 			`
 package main
 
 import (
-	"encoding/json"
-	"errors"
 	"net/http"
 	"net/http/httptest"
 	"log"
-	"io"
-	"io/ioutil"
-	"bytes"
-	"strings"
 )
 
 type Server struct{}
 
 func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
-	return nil, errors.New("not implemented")
+	return nil, nil
 }
 
 func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
-	return nil, errors.New("not implemented")
+	return nil, nil
 }
 
 func main() {
 	s := httptest.NewServer(NewAPIMux(&Server{}))
 	defer s.Close()
-	
-	res, err := http.Get(s.URL+"/v1/spec.json")
-	if err != nil {
-		log.Fatal(err)
-	}
-	raw, err := ioutil.ReadAll(res.Body)
-	res.Body.Close()
-	if err != nil {
-		log.Fatal(err)
-	}
 
-	if res.StatusCode != 200 {
-		log.Fatal("status code not 200, but: %v", res.StatusCode)
+	tbl := []struct{
+		Path string
+		Allow string
+		Methods string
+	}{
+		{"/v1/http", "POST, OPTIONS", "POST"},
+		{"/v1/websocket", "GET, OPTIONS", "GET"},
+		{"/v1/spec", "GET, OPTIONS", "GET"},
+		{"/v1/spec.json", "GET, OPTIONS", "GET"},
 	}
 
-	a := strings.Replace(string(newEmbeddedSpec()), "  ", "", -1)
-	b := strings.Replace(string(raw), "  ", "", -1)
-	a = strings.Replace(a, "\n", "", -1)
-	b = strings.Replace(b, "\n", "", -1)
+	for _, e := range tbl {
+		req, err := http.NewRequest("OPTIONS", s.URL+e.Path, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		res.Body.Close()
 
-	if a != b {
-		log.Fatalf("response data was: \n'%s'\n should be: \n'%s'", b, a)
+		if res.StatusCode != 200 {
+			log.Fatalf("%v: status code was %v", e.Path, res.StatusCode)
+		}
+		if res.Header.Get("Allow") != e.Allow {
+			log.Fatalf("%v: Allow was %q", e.Path, res.Header.Get("Allow"))
+		}
+		if res.Header.Get("Access-Control-Allow-Methods") != e.Methods {
+			log.Fatalf("%v: Access-Control-Allow-Methods was %q", e.Path, res.Header.Get("Access-Control-Allow-Methods"))
+		}
+		if res.Header.Get("Access-Control-Allow-Origin") != "*" {
+			log.Fatalf("%v: Access-Control-Allow-Origin was %q", e.Path, res.Header.Get("Access-Control-Allow-Origin"))
+		}
 	}
 }
-			`,
+	`,
 		},
 		{
-			"GET /spec with response",
-			fixture.TestSchemaSimpleLogin,
+			"method not allowed with Allow header",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
 			`
 package main
 
 import (
-	"encoding/json"
-	"errors"
 	"net/http"
 	"net/http/httptest"
 	"log"
-	"io"
-	"io/ioutil"
-	"bytes"
+	"strings"
 )
 
 type Server struct{}
 
 func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
-	return nil, errors.New("not implemented")
+	return nil, nil
 }
 
 func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
-	return nil, errors.New("not implemented")
+	return nil, nil
 }
 
 func main() {
 	s := httptest.NewServer(NewAPIMux(&Server{}))
 	defer s.Close()
-	
-	res, err := http.Get(s.URL+"/v1/spec")
-	if err != nil {
-		log.Fatal(err)
-	}
+
+	tbl := []struct{
+		Method string
+		Path   string
+		Allow  string
+	}{
+		{"GET", "/v1/http", "POST, OPTIONS"},
+		{"PUT", "/v1/http", "POST, OPTIONS"},
+		{"POST", "/v1/websocket", "GET, OPTIONS"},
+		{"POST", "/v1/spec", "GET, OPTIONS"},
+		{"DELETE", "/v1/spec.json", "GET, OPTIONS"},
+	}
+
+	for _, e := range tbl {
+		req, err := http.NewRequest(e.Method, s.URL+e.Path, strings.NewReader("{}"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusMethodNotAllowed {
+			log.Fatalf("%v %v: status code was %v", e.Method, e.Path, res.StatusCode)
+		}
+		if res.Header.Get("Allow") != e.Allow {
+			log.Fatalf("%v %v: Allow was %q", e.Method, e.Path, res.Header.Get("Allow"))
+		}
+	}
+}
+	`,
+		},
+		{
+			"preflight max age",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			`
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"time"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return nil, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	tbl := []struct{
+		MaxAge time.Duration
+		Header string
+	}{
+		{0, "600"},
+		{time.Hour, "3600"},
+		{-1, ""},
+	}
+
+	for _, e := range tbl {
+		s := httptest.NewServer(NewAPIMuxWithOptions(&Server{}, APIOptions{PreflightMaxAge: e.MaxAge}))
+		for _, path := range []string{"/v1/http", "/v1/websocket", "/v1/spec", "/v1/spec.json"} {
+			req, err := http.NewRequest("OPTIONS", s.URL+path, nil)
+			if err != nil {
+				log.Fatal(err)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				log.Fatal(err)
+			}
+			res.Body.Close()
+
+			if res.Header.Get("Access-Control-Max-Age") != e.Header {
+				log.Fatalf("%v with max age %v: Access-Control-Max-Age was %q", path, e.MaxAge, res.Header.Get("Access-Control-Max-Age"))
+			}
+		}
+		s.Close()
+	}
+}
+	`,
+		},
+		{
+			"inline object properties",
+			fixture.TestSchemaInlineObjectProperties,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+)
+
+type Server struct{}
+
+func(s *Server) UpdateUser(u *User) (*UpdateUserOuts, error) {
+	return &UpdateUserOuts{User: u}, nil
+}
+
+func post(url string, u *User) (int, []byte) {
+	res, err := http.Post(url, "application/json", newMessageReader("updateUser", u))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+	raw, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return res.StatusCode, raw
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	u := &User{
+		Name: newString("john"),
+		Address: &UserAddress{
+			Street: newString("main street"),
+			Geo: &UserAddressGeo{Lat: newFloat64(52.5), Lng: newFloat64(13.4)},
+		},
+	}
+	status, raw := post(s.URL+"/v1/http", u)
+	if status != 200 {
+		log.Fatalf("status code was %v: %s", status, raw)
+	}
+
+	var rm message
+	err := json.Unmarshal(raw, &rm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var ru User
+	err = json.Unmarshal(rm.Data, &ru)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = ru.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *ru.Address.Street != "main street" || *ru.Address.Geo.Lat != 52.5 || *ru.Address.Geo.Lng != 13.4 {
+		log.Fatalf("nested data did not round-trip: %s", raw)
+	}
+
+	// invalid nested data
+	u.Address.Geo.Lng = nil
+	status, raw = post(s.URL+"/v1/http", u)
+	if status != 422 {
+		log.Fatalf("status code was %v: %s", status, raw)
+	}
+}
+	`,
+		},
+		{
+			"integer and number types",
+			fixture.TestSchemaIntegerAndNumber,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+	"reflect"
+)
+
+type Server struct{}
+
+func(s *Server) Record(m *Measurement) (*RecordOuts, error) {
+	return &RecordOuts{Measurement: m}, nil
+}
+
+func main() {
+	var m Measurement
+	if t := reflect.TypeOf(m.Count).Elem().Kind(); t != reflect.Int64 {
+		log.Fatalf("integer field type was %v", t)
+	}
+	if t := reflect.TypeOf(m.Value).Elem().Kind(); t != reflect.Float64 {
+		log.Fatalf("number field type was %v", t)
+	}
+
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("record", NewMeasurement(3, 1.5)))
+	if err != nil {
+		log.Fatal(err)
+	}
 	raw, err := ioutil.ReadAll(res.Body)
 	res.Body.Close()
 	if err != nil {
 		log.Fatal(err)
 	}
+	if res.StatusCode != 200 {
+		log.Fatalf("status code was %v: %s", res.StatusCode, raw)
+	}
+
+	var rm message
+	err = json.Unmarshal(raw, &rm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var rs Measurement
+	err = json.Unmarshal(rm.Data, &rs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *rs.Count != 3 || *rs.Value != 1.5 {
+		log.Fatalf("measurement was: %s", raw)
+	}
+
+	// fractional integer is rejected
+	res, err = http.Post(s.URL+"/v1/http", "application/json", newMessageReader("record", map[string]interface{}{"count": 1.5, "value": 1}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode == 200 {
+		log.Fatal("fractional integer was accepted")
+	}
+}
+	`,
+		},
+		{
+			"routes registered on custom router",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"strings"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("foo")}}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+// mounts all routes under a prefix
+type prefixRouter struct {
+	prefix string
+	mux *http.ServeMux
+	patterns []string
+}
+
+func (r *prefixRouter) HandleFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
+	r.patterns = append(r.patterns, pattern)
+	r.mux.Handle(r.prefix+pattern, http.StripPrefix(r.prefix, http.HandlerFunc(h)))
+}
+
+func main() {
+	r := &prefixRouter{prefix: "/api", mux: http.NewServeMux()}
+	RegisterRoutes(r, &Server{})
+
+	if strings.Join(r.patterns, " ") != "/v1/spec.json /v1/spec /v1/http" {
+		log.Fatalf("registered patterns were: %v", r.patterns)
+	}
+
+	s := httptest.NewServer(r.mux)
+	defer s.Close()
+
+	c := &Credentials{Name: newString("john"), Password: newString("snow")}
+	res, err := http.Post(s.URL+"/api/v1/http", "application/json", newMessageReader("loginWithCredentials", c))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		log.Fatalf("status code was %v", res.StatusCode)
+	}
+
+	res, err = http.Post(s.URL+"/v1/http", "application/json", newMessageReader("loginWithCredentials", c))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		log.Fatalf("unprefixed status code was %v", res.StatusCode)
+	}
+}
+	`,
+		},
+		{
+			"const discriminator validation",
+			fixture.TestSchemaConstDiscriminator,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+)
+
+type Server struct{}
+
+func(s *Server) Draw(d *Drawing) (*DrawOuts, error) {
+	return &DrawOuts{Circle: d.Shape}, nil
+}
+
+func post(url string, d interface{}) (int, string) {
+	res, err := http.Post(url, "application/json", newMessageReader("draw", d))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+	raw, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var rm message
+	err = json.Unmarshal(raw, &rm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var e struct {
+		Error string
+	}
+	json.Unmarshal(rm.Data, &e)
+	return res.StatusCode, e.Error
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	tbl := []struct{
+		Data string
+		Status int
+		Error string
+	}{
+		{` + "`" + `{"shape": {"kind": "circle", "radius": 1}}` + "`" + `, 200, ""},
+		{` + "`" + `{"shape": {"kind": "circle"}, "version": 2}` + "`" + `, 200, ""},
+		{` + "`" + `{"shape": {"kind": "square"}}` + "`" + `, 422, "invalid circle: kind must be \"circle\""},
+		{` + "`" + `{"shape": {"kind": "circle"}, "version": 3}` + "`" + `, 422, "invalid drawing: version must be 2"},
+	}
+
+	for _, e := range tbl {
+		status, msg := post(s.URL+"/v1/http", json.RawMessage(e.Data))
+		if status != e.Status {
+			log.Fatalf("%v: status code was %v", e.Data, status)
+		}
+		if msg != e.Error {
+			log.Fatalf("%v: error was %q", e.Data, msg)
+		}
+	}
+
+	if err := NewCircle("circle").validateValues(); err != nil {
+		log.Fatal(err)
+	}
+}
+	`,
+		},
+		{
+			"built-in fetchSpec message",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return nil, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("fetchSpec", nil))
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if res.StatusCode != 200 {
+		log.Fatalf("status code was %v: %s", res.StatusCode, raw)
+	}
+
+	var rm message
+	err = json.Unmarshal(raw, &rm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if rm.Msg != "spec" {
+		log.Fatalf("response message was: %v", rm.Msg)
+	}
+	var spc struct {
+		Messages map[string]interface{}
+	}
+	err = json.Unmarshal(rm.Data, &spc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, ok := spc.Messages["loginWithCredentials"]; !ok {
+		log.Fatalf("spec was: %s", rm.Data)
+	}
+}
+	`,
+		},
+		{
+			"enum and array of enum validation",
+			fixture.TestSchemaEnumValues,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+)
+
+type Server struct{}
+
+func(s *Server) UpdateUser(u *User) (*UpdateUserOuts, error) {
+	return &UpdateUserOuts{User: u}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	tbl := []struct{
+		Data string
+		Status int
+		Error string
+	}{
+		{` + "`" + `{"name": "john"}` + "`" + `, 200, ""},
+		{` + "`" + `{"name": "john", "status": "active", "roles": ["admin", "member"]}` + "`" + `, 200, ""},
+		{` + "`" + `{"name": "john", "status": "deleted"}` + "`" + `, 422, "invalid user: status must be one of \"active\", \"blocked\""},
+		{` + "`" + `{"name": "john", "roles": ["member", "root"]}` + "`" + `, 422, "invalid user: roles items must be one of \"admin\", \"member\""},
+	}
+
+	for _, e := range tbl {
+		res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("updateUser", json.RawMessage(e.Data)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		raw, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if res.StatusCode != e.Status {
+			log.Fatalf("%v: status code was %v: %s", e.Data, res.StatusCode, raw)
+		}
+
+		var rm message
+		err = json.Unmarshal(raw, &rm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var re struct {
+			Error string
+		}
+		json.Unmarshal(rm.Data, &re)
+		if re.Error != e.Error {
+			log.Fatalf("%v: error was %q", e.Data, re.Error)
+		}
+	}
+}
+	`,
+		},
+		{
+			"keyword message and property names",
+			fixture.TestSchemaKeywordNames,
+			`
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) Type(i *Item) (*TypeOuts, error) {
+	return &TypeOuts{Item: i}, nil
+}
+
+func(s *Server) Range() (*RangeOuts, error) {
+	return &RangeOuts{Item: NewItem("a", "b", "c", 1, true)}, nil
+}
+
+func(s *Server) X2faVerify(i *Item) error {
+	return nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	i := NewItem("type", "func", "string", 2, false)
+	if *i.Type != "type" || *i.Func != "func" || *i.String != "string" || *i.Len != 2 || *i.Nil != false {
+		log.Fatal("constructor did not set fields")
+	}
+
+	for _, m := range []string{"type", "range", "2fa.verify"} {
+		res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader(m, i))
+		if err != nil {
+			log.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != 200 {
+			log.Fatalf("%v: status code was %v", m, res.StatusCode)
+		}
+	}
+}
+	`,
+		},
+		{
+			"error responses have content type",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"strings"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return nil, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	tbl := []struct{
+		Method string
+		Path string
+		Body string
+		Status int
+	}{
+		{"GET", "/v1/http", "", 405},
+		{"POST", "/v1/http", "{", 422},
+		{"POST", "/v1/http", ` + "`" + `{"msg": "unknown"}` + "`" + `, 404},
+		{"POST", "/v1/spec", "", 405},
+		{"POST", "/v1/spec.json", "", 405},
+		{"GET", "/v1/websocket", "", 400},
+	}
+
+	for _, e := range tbl {
+		req, err := http.NewRequest(e.Method, s.URL+e.Path, strings.NewReader(e.Body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if res.StatusCode != e.Status {
+			log.Fatalf("%v %v: status code was %v", e.Method, e.Path, res.StatusCode)
+		}
+		if res.Header.Get("Content-Type") != "application/json" {
+			log.Fatalf("%v %v: content type was %q", e.Method, e.Path, res.Header.Get("Content-Type"))
+		}
+
+		var rm message
+		err = json.NewDecoder(res.Body).Decode(&rm)
+		res.Body.Close()
+		if err != nil {
+			log.Fatalf("%v %v: %v", e.Method, e.Path, err)
+		}
+		if rm.Msg != "error" {
+			log.Fatalf("%v %v: response message was %v", e.Method, e.Path, rm.Msg)
+		}
+	}
+}
+	`,
+		},
+		{
+			"process messages without HTTP",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"errors"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	if *c.Password != "secret" {
+		return nil, errors.New("wrong password")
+	}
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	out, status := ProcessMessage(&Server{}, "loginWithCredentials", &Credentials{Name: newString("abc"), Password: newString("secret")})
+	if status != 200 {
+		log.Fatalf("status code was %v", status)
+	}
+	om, ok := out.(outMessage)
+	if !ok || om.Msg != "session" {
+		log.Fatalf("out was %v", out)
+	}
+	if *om.Data.(*Session).ID != "123" {
+		log.Fatalf("session was %v", om.Data)
+	}
+
+	_, status = ProcessMessage(&Server{}, "loginWithCredentials", &Credentials{Name: newString("abc"), Password: newString("wrong")})
+	if status != 500 {
+		log.Fatalf("status code for failing implementation was %v", status)
+	}
+
+	_, status = ProcessMessage(&Server{}, "loginWithCredentials", "abc")
+	if status != 422 {
+		log.Fatalf("status code for unparsable data was %v", status)
+	}
+
+	_, status = ProcessMessage(&Server{}, "unknown", nil)
+	if status != 404 {
+		log.Fatalf("status code for unknown message was %v", status)
+	}
+}
+	`,
+		},
+		{
+			"dispatch messages from the Handlers map",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"encoding/json"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	handlers := Handlers(&Server{})
+	for _, msg := range []string{"loginWithCredentials", "logout", "fetchSpec"} {
+		if _, ok := handlers[msg]; !ok {
+			log.Fatalf("missing handler for %v", msg)
+		}
+	}
+
+	out, status := handlers["loginWithCredentials"](json.RawMessage(` + "`" + `{"name": "abc", "password": "secret"}` + "`" + `))
+	if status != 200 {
+		log.Fatalf("status code was %v", status)
+	}
+	om, ok := out.(outMessage)
+	if !ok || om.Msg != "session" || *om.Data.(*Session).ID != "123" {
+		log.Fatalf("out was %v", out)
+	}
+
+	_, status = handlers["loginWithCredentials"](json.RawMessage(` + "`" + `{"name": 1}` + "`" + `))
+	if status != 422 {
+		log.Fatalf("status code for unparsable data was %v", status)
+	}
+}
+	`,
+		},
+		{
+			"websocket binary frames with codec",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			`
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log"
+	"math"
+	"net/http/httptest"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// minimal msgpack codec of generic JSON values
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	err := packValue(&b, v)
+	return b.Bytes(), err
+}
+
+func packValue(b *bytes.Buffer, v interface{}) error {
+	switch t := v.(type) {
+	case nil:
+		b.WriteByte(0xc0)
+	case bool:
+		if t {
+			b.WriteByte(0xc3)
+		} else {
+			b.WriteByte(0xc2)
+		}
+	case float64:
+		b.WriteByte(0xcb)
+		binary.Write(b, binary.BigEndian, math.Float64bits(t))
+	case string:
+		b.WriteByte(0xd9)
+		b.WriteByte(byte(len(t)))
+		b.WriteString(t)
+	case []interface{}:
+		b.WriteByte(0x90 | byte(len(t)))
+		for _, e := range t {
+			if err := packValue(b, e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		b.WriteByte(0x80 | byte(len(t)))
+		var keys []string
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			packValue(b, k)
+			if err := packValue(b, t[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("unsupported value")
+	}
+	return nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	r := bytes.NewReader(data)
+	u, err := unpackValue(r)
+	if err != nil {
+		return err
+	}
+	*(v.(*interface{})) = u
+	return nil
+}
+
+func unpackValue(r *bytes.Reader) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == 0xc0:
+		return nil, nil
+	case c == 0xc2 || c == 0xc3:
+		return c == 0xc3, nil
+	case c == 0xcb:
+		var bits uint64
+		err = binary.Read(r, binary.BigEndian, &bits)
+		return math.Float64frombits(bits), err
+	case c&0xe0 == 0xa0 || c == 0xd9:
+		n := int(c & 0x1f)
+		if c == 0xd9 {
+			l, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			n = int(l)
+		}
+		s := make([]byte, n)
+		_, err = r.Read(s)
+		return string(s), err
+	case c&0xf0 == 0x90:
+		a := make([]interface{}, int(c&0x0f))
+		for i := range a {
+			if a[i], err = unpackValue(r); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	case c&0xf0 == 0x80:
+		// decoded with interface{} keys like common msgpack libraries
+		m := make(map[interface{}]interface{})
+		for i := 0; i < int(c&0x0f); i++ {
+			k, err := unpackValue(r)
+			if err != nil {
+				return nil, err
+			}
+			if m[k], err = unpackValue(r); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, errors.New("unsupported format")
+}
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{
+		Session: &Session{ID: newString(*c.Name)},
+	}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMuxWithOptions(&Server{}, APIOptions{BinaryCodec: msgpackCodec{}}))
+	defer s.Close()
+
+	url := strings.Replace(s.URL, "http://", "ws://", 1)
+	conn, _, err := websocket.DefaultDialer.Dial(url + "/v1/websocket", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	// binary msgpack frame
+	in, err := msgpackCodec{}.Marshal(map[string]interface{}{
+		"msg": "loginWithCredentials",
+		"data": map[string]interface{}{"name": "john", "password": "snow"},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = conn.WriteMessage(websocket.BinaryMessage, in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mt, out, err := conn.ReadMessage()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if mt != websocket.BinaryMessage {
+		log.Fatalf("response frame type was %v", mt)
+	}
+	var v interface{}
+	err = msgpackCodec{}.Unmarshal(out, &v)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m := v.(map[interface{}]interface{})
+	if m["msg"] != "session" {
+		log.Fatalf("response message was %v", m["msg"])
+	}
+	if m["data"].(map[interface{}]interface{})["id"] != "john" {
+		log.Fatalf("response data was %v", m["data"])
+	}
+
+	// text frames stay JSON
+	err = conn.WriteMessage(websocket.TextMessage, []byte(` + "`" + `{"msg": "loginWithCredentials", "data": {"name": "john"}}` + "`" + `))
+	if err != nil {
+		log.Fatal(err)
+	}
+	mt, out, err = conn.ReadMessage()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if mt != websocket.TextMessage || !bytes.Contains(out, []byte(` + "`" + `"msg": "session"` + "`" + `)) {
+		log.Fatalf("response to text frame was %v %s", mt, out)
+	}
+
+	// undecodable binary frames are answered, then close the connection
+	err = conn.WriteMessage(websocket.BinaryMessage, []byte{0xc1})
+	if err != nil {
+		log.Fatal(err)
+	}
+	mt, out, err = conn.ReadMessage()
+	if err != nil {
+		log.Fatal(err)
+	}
+	v = nil
+	msgpackCodec{}.Unmarshal(out, &v)
+	if mt != websocket.BinaryMessage || v.(map[interface{}]interface{})["msg"] != "error" {
+		log.Fatalf("response to undecodable frame was %v %v", mt, v)
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseInvalidFramePayloadData) {
+		log.Fatalf("undecodable frame should close with 1007, but was %v", err)
+	}
+}
+	`,
+		},
+		{
+			"rate limited messages",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return &LogoutOuts{Message: &Message{newString("bye")}}, nil
+}
+
+func main() {
+	l := NewRateLimiter(map[string]int{"loginWithCredentials": 2})
+	s := httptest.NewServer(NewAPIMuxWithOptions(&Server{}, APIOptions{Limiter: l}))
+	defer s.Close()
+
+	post := func(msg string, data interface{}) int {
+		res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader(msg, data))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer res.Body.Close()
+		var rm message
+		err = json.NewDecoder(res.Body).Decode(&rm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if res.StatusCode == http.StatusTooManyRequests && rm.Msg != "error" {
+			log.Fatalf("response message was %v", rm.Msg)
+		}
+		return res.StatusCode
+	}
+
+	// burst up to the limit
+	for n := 0; n < 2; n++ {
+		if status := post("loginWithCredentials", &Credentials{}); status != 200 {
+			log.Fatalf("request %v: status code was %v", n, status)
+		}
+	}
+	if status := post("loginWithCredentials", &Credentials{}); status != 429 {
+		log.Fatalf("status code after exceeding the limit was %v", status)
+	}
+
+	// other messages are not limited
+	for n := 0; n < 5; n++ {
+		if status := post("logout", &Session{}); status != 200 {
+			log.Fatalf("unlimited request %v: status code was %v", n, status)
+		}
+	}
+}
+	`,
+		},
+		{
+			"nullable properties accept explicit null",
+			fixture.TestSchemaNullableTypes,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"strings"
+)
+
+type Server struct{}
+
+func(s *Server) UpdateProfile(p *Profile) (*UpdateProfileOuts, error) {
+	if p.Nickname != nil || p.Email != nil {
+		log.Fatalf("explicit nulls were decoded as %v, %v", p.Nickname, p.Email)
+	}
+	return &UpdateProfileOuts{Profile: NewProfile(*p.Name, p.Email)}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	tbl := []struct{
+		Body string
+		Status int
+	}{
+		{` + "`" + `{"msg": "updateProfile", "data": {"name": "john", "nickname": null, "email": null}}` + "`" + `, 200},
+		{` + "`" + `{"msg": "updateProfile", "data": {"name": "john", "email": null}}` + "`" + `, 200},
+		{` + "`" + `{"msg": "updateProfile", "data": {"nickname": null, "email": null}}` + "`" + `, 422},
+		{` + "`" + `{"msg": "updateProfile", "data": {"name": "john", "nickname": null}}` + "`" + `, 422},
+	}
+	for _, e := range tbl {
+		res, err := http.Post(s.URL+"/v1/http", "application/json", strings.NewReader(e.Body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if res.StatusCode != e.Status {
+			log.Fatalf("%v: status code was %v", e.Body, res.StatusCode)
+		}
+
+		var rm struct {
+			Msg string
+			Data map[string]interface{}
+		}
+		err = json.NewDecoder(res.Body).Decode(&rm)
+		res.Body.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if e.Status != 200 {
+			continue
+		}
+
+		// required nullable properties encode as null
+		_, email := rm.Data["email"]
+		if rm.Msg != "profile" || rm.Data["name"] != "john" || rm.Data["nickname"] != nil || !email || rm.Data["email"] != nil {
+			log.Fatalf("%v: response was %v", e.Body, rm)
+		}
+	}
+}
+	`,
+		},
+		{
+			"websocket closes on oversized messages",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"log"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{
+		Session: &Session{ID: newString("123")},
+	}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMuxWithOptions(&Server{}, APIOptions{MaxMessageSize: 128}))
+	defer s.Close()
+	url := strings.Replace(s.URL, "http://", "ws://", 1) + "/v1/websocket"
+
+	// oversized message
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	err = conn.WriteJSON(outMessage{"loginWithCredentials", Credentials{Name: newString(strings.Repeat("x", 1024))}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		log.Fatalf("read should fail with close 1009, but was %v", err)
+	}
+
+	// server keeps serving
+	conn2, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn2.Close()
+	err = conn2.WriteJSON(outMessage{"loginWithCredentials", Credentials{Name: newString("john")}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, data, err := conn2.ReadMessage()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var rm message
+	err = json.Unmarshal(data, &rm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if rm.Msg != "session" {
+		log.Fatalf("response message was %v", rm.Msg)
+	}
+}
+	`,
+		},
+		{
+			"gzip compressed requests",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{
+		Session: &Session{ID: newString("123")},
+	}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func post(url string, encoding string, body []byte) (int, message) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Content-Encoding", encoding)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+	var rm message
+	err = json.NewDecoder(res.Body).Decode(&rm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return res.StatusCode, rm
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+	url := s.URL + "/v1/http"
+
+	raw, err := ioutil.ReadAll(newMessageReader("loginWithCredentials", Credentials{Name: newString("john")}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(raw)
+	zw.Close()
+
+	statusCode, rm := post(url, "gzip", buf.Bytes())
+	if statusCode != 200 || rm.Msg != "session" {
+		log.Fatalf("gzip request was answered with %v %v", statusCode, rm.Msg)
+	}
+
+	// corrupt compression
+	statusCode, rm = post(url, "gzip", buf.Bytes()[:buf.Len()/2])
+	if statusCode != 400 {
+		log.Fatalf("status code for truncated gzip was %v", statusCode)
+	}
+	statusCode, rm = post(url, "gzip", raw)
+	if statusCode != 400 {
+		log.Fatalf("status code for uncompressed gzip was %v", statusCode)
+	}
+
+	// unsupported encoding
+	statusCode, rm = post(url, "br", raw)
+	if statusCode != 415 {
+		log.Fatalf("status code for unsupported encoding was %v", statusCode)
+	}
+}
+	`,
+		},
+		{
+			"payload logging redacts writeOnly properties",
+			fixture.TestSchemaReadWriteOnly,
+			`
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type Server struct{}
+
+func(s *Server) UpdateCredentials(c *Credentials) (*UpdateCredentialsOuts, error) {
+	return &UpdateCredentialsOuts{Credentials: c}, nil
+}
+
+func(s *Server) UpdateAccount(a *Account) (*UpdateAccountOuts, error) {
+	return &UpdateAccountOuts{Account: a}, nil
+}
+
+type payloads struct {
+	in, out    string
+	statusCode int
+}
+
+type capturingLogger struct {
+	logged []payloads
+}
+
+func (l *capturingLogger) LogPayloads(in []byte, out []byte, statusCode int) {
+	l.logged = append(l.logged, payloads{string(in), string(out), statusCode})
+}
+
+func main() {
+	l := &capturingLogger{}
+	s := httptest.NewServer(NewAPIMuxWithOptions(&Server{}, APIOptions{PayloadLogger: l}))
+	defer s.Close()
+
+	for _, in := range []string{
+		` + "`" + `{"msg": "updateCredentials", "data": {"name": "john", "password": "secret"}}` + "`" + `,
+		` + "`" + `{"msg": "updateAccount", "data": {"credentials": {"name": "john", "password": "secret"}}}` + "`" + `,
+	} {
+		res, err := http.Post(s.URL+"/v1/http", "application/json", bytes.NewBufferString(in))
+		if err != nil {
+			log.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	if len(l.logged) != 2 {
+		log.Fatalf("logged %v payloads", len(l.logged))
+	}
+	for _, p := range l.logged {
+		if p.statusCode != 200 || !strings.Contains(p.in, "john") || !strings.Contains(p.out, "john") {
+			log.Fatalf("logged payloads were %+v", p)
+		}
+		if strings.Contains(p.in, "secret") || strings.Contains(p.out, "secret") {
+			log.Fatalf("logged payloads were not redacted: %+v", p)
+		}
+	}
+}
+	`,
+		},
+		{
+			"readOnly and writeOnly properties",
+			fixture.TestSchemaReadWriteOnly,
+			`
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) UpdateCredentials(c *Credentials) (*UpdateCredentialsOuts, error) {
+	if c.Password == nil || *c.Password != "secret" {
+		log.Fatalf("password was not accepted as input: %v", c)
+	}
+	return &UpdateCredentialsOuts{Credentials: c}, nil
+}
+
+func(s *Server) UpdateAccount(a *Account) (*UpdateAccountOuts, error) {
+	a.ID = newString("123")
+	return &UpdateAccountOuts{Account: a}, nil
+}
+
+func post(url string, body string) (int, string) {
+	res, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return res.StatusCode, string(b)
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+	url := s.URL + "/v1/http"
+
+	// writeOnly properties are accepted but never echoed, also when nested
+	statusCode, body := post(url, ` + "`" + `{"msg": "updateCredentials", "data": {"name": "john", "password": "secret"}}` + "`" + `)
+	if statusCode != 200 || !bytes.Contains([]byte(body), []byte("john")) || bytes.Contains([]byte(body), []byte("password")) {
+		log.Fatalf("response was %v %v", statusCode, body)
+	}
+	statusCode, body = post(url, ` + "`" + `{"msg": "updateAccount", "data": {"credentials": {"name": "john", "password": "secret"}}}` + "`" + `)
+	if statusCode != 200 || !bytes.Contains([]byte(body), []byte("123")) || bytes.Contains([]byte(body), []byte("password")) {
+		log.Fatalf("response was %v %v", statusCode, body)
+	}
+
+	// readOnly properties are rejected
+	statusCode, body = post(url, ` + "`" + `{"msg": "updateAccount", "data": {"id": "1"}}` + "`" + `)
+	if statusCode != 422 || !bytes.Contains([]byte(body), []byte("invalid account: id is read-only")) {
+		log.Fatalf("response was %v %v", statusCode, body)
+	}
+}
+	`,
+		},
+		{
+			"alternative in schemas",
+			fixture.TestSchemaAlternativeIns,
+			`
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) Login(in *LoginIn) (*LoginOuts, error) {
+	switch {
+	case in.Credentials != nil:
+		return &LoginOuts{Session: &Session{ID: newString("credentials " + *in.Credentials.Name)}}, nil
+	case in.Token != nil:
+		return &LoginOuts{Session: &Session{ID: newString("token " + *in.Token.Token)}}, nil
+	}
+	log.Fatal("no alternative set")
+	return nil, nil
+}
+
+func post(url string, body string) (int, string) {
+	res, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return res.StatusCode, string(b)
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+	url := s.URL + "/v1/http"
+
+	statusCode, body := post(url, ` + "`" + `{"msg": "login", "data": {"name": "john", "password": "secret"}}` + "`" + `)
+	if statusCode != 200 || !bytes.Contains([]byte(body), []byte("credentials john")) {
+		log.Fatalf("credentials were answered with %v %v", statusCode, body)
+	}
+
+	statusCode, body = post(url, ` + "`" + `{"msg": "login", "data": {"token": "abc"}}` + "`" + `)
+	if statusCode != 200 || !bytes.Contains([]byte(body), []byte("token abc")) {
+		log.Fatalf("token was answered with %v %v", statusCode, body)
+	}
+
+	statusCode, body = post(url, ` + "`" + `{"msg": "login", "data": {"name": "john"}}` + "`" + `)
+	if statusCode != 422 || !bytes.Contains([]byte(body), []byte("invalid in of login: data matches none of credentials, token")) {
+		log.Fatalf("invalid data was answered with %v %v", statusCode, body)
+	}
+}
+	`,
+		},
+		{
+			"websocket closes on unparsable messages",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			`
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return nil, errors.New("failed")
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+	url := strings.Replace(s.URL, "http://", "ws://", 1) + "/v1/websocket"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	// errors of single messages keep the connection open
+	for _, in := range []string{` + "`" + `{"msg": "unknown"}` + "`" + `, ` + "`" + `{"msg": "loginWithCredentials", "data": {}}` + "`" + `} {
+		err = conn.WriteMessage(websocket.TextMessage, []byte(in))
+		if err != nil {
+			log.Fatal(err)
+		}
+		_, _, err = conn.ReadMessage()
+		if err != nil {
+			log.Fatalf("%v: read failed: %v", in, err)
+		}
+	}
+
+	// garbage is answered and closes the connection
+	err = conn.WriteMessage(websocket.TextMessage, []byte("garbage"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, data, err := conn.ReadMessage()
+	if err != nil || !strings.Contains(string(data), "unparsable message") {
+		log.Fatalf("garbage was answered with %s, %v", data, err)
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseInvalidFramePayloadData) {
+		log.Fatalf("read should fail with close 1007, but was %v", err)
+	}
+	if ce := err.(*websocket.CloseError); ce.Text != "unparsable message" {
+		log.Fatalf("close reason was %q", ce.Text)
+	}
+}
+	`,
+		},
+		{
+			"websocket subprotocol negotiation",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			`
+package main
+
+import (
+	"log"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return nil, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func dial(url string, protocols ...string) string {
+	d := websocket.Dialer{Subprotocols: protocols}
+	conn, res, err := d.Dial(url, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	if h := res.Header.Get("Sec-WebSocket-Protocol"); h != conn.Subprotocol() {
+		log.Fatalf("response header was %q", h)
+	}
+	return conn.Subprotocol()
+}
+
+func main() {
+	// no subprotocol is negotiated by default
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	url := strings.Replace(s.URL, "http://", "ws://", 1) + "/v1/websocket"
+	if p := dial(url, "p1", "p2"); p != "" {
+		log.Fatalf("subprotocol was %q", p)
+	}
+	s.Close()
+
+	// the first accepted subprotocol requested by the client
+	s = httptest.NewServer(NewAPIMuxWithOptions(&Server{}, APIOptions{Subprotocols: []string{"p3", "p2", "p1"}}))
+	defer s.Close()
+	url = strings.Replace(s.URL, "http://", "ws://", 1) + "/v1/websocket"
+	if p := dial(url, "p0", "p2"); p != "p2" {
+		log.Fatalf("subprotocol was %q", p)
+	}
+	if p := dial(url, "p0"); p != "" {
+		log.Fatalf("subprotocol was %q", p)
+	}
+}
+	`,
+		},
+		{
+			"property counts of free-form objects",
+			fixture.TestSchemaPropertyCounts,
+			`
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) TagItem(i *Item) (*TagItemOuts, error) {
+	return &TagItemOuts{Item: i}, nil
+}
+
+func post(url string, body string) (int, string) {
+	res, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return res.StatusCode, string(b)
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+	url := s.URL + "/v1/http"
+
+	for _, c := range []struct {
+		data       string
+		statusCode int
+		err        string
+	}{
+		{` + "`" + `{"id": "1"}` + "`" + `, 200, ""},
+		{` + "`" + `{"labels": {"a": "1"}}` + "`" + `, 200, ""},
+		{` + "`" + `{"labels": {"a": "1", "b": "2"}}` + "`" + `, 200, ""},
+		{` + "`" + `{"labels": {}}` + "`" + `, 422, "invalid item: labels must have at least 1 properties"},
+		{` + "`" + `{"labels": {"a": "1", "b": "2", "c": "3"}}` + "`" + `, 422, "invalid item: labels must have at most 2 properties"},
+	} {
+		statusCode, body := post(url, ` + "`" + `{"msg": "tagItem", "data": ` + "`" + `+c.data+` + "`" + `}` + "`" + `)
+		if statusCode != c.statusCode || !bytes.Contains([]byte(body), []byte(c.err)) {
+			log.Fatalf("%v: response was %v %v", c.data, statusCode, body)
+		}
+	}
+}
+	`,
+		},
+		{
+			"x-go-name field names",
+			fixture.TestSchemaGoNames,
+			`
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) UpdateUser(u *User) (*UpdateUserOuts, error) {
+	if *u.UserID != "1" || *u.Slug != "john-doe" {
+		log.Fatalf("user was %v", u)
+	}
+	u.Name = newString("John Doe")
+	return &UpdateUserOuts{User: u}, nil
+}
+
+func post(url string, body string) (int, string) {
+	res, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return res.StatusCode, string(b)
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+	url := s.URL + "/v1/http"
+
+	// json keys are kept
+	statusCode, body := post(url, ` + "`" + `{"msg": "updateUser", "data": {"id": "1", "url_slug": "john-doe"}}` + "`" + `)
+	if statusCode != 200 || !bytes.Contains([]byte(body), []byte(` + "`" + `"url_slug": "john-doe"` + "`" + `)) {
+		log.Fatalf("response was %v %v", statusCode, body)
+	}
+
+	// required renamed fields are validated
+	statusCode, body = post(url, ` + "`" + `{"msg": "updateUser", "data": {"id": "1"}}` + "`" + `)
+	if statusCode != 422 {
+		log.Fatalf("response was %v %v", statusCode, body)
+	}
+}
+	`,
+		},
+		{
+			"sorted message names",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"log"
+	"reflect"
+)
+
+func main() {
+	if msgs := Messages(); !reflect.DeepEqual(msgs, []string{"loginWithCredentials", "logout"}) {
+		log.Fatalf("messages were %v", msgs)
+	}
+
+	// constants of the msg names
+	if MsgLoginWithCredentials != "loginWithCredentials" || MsgLogout != "logout" {
+		log.Fatalf("msg constants were %v and %v", MsgLoginWithCredentials, MsgLogout)
+	}
+}
+	`,
+		},
+		{
+			"static response headers of a message",
+			fixture.TestSchemaResponseHeaders,
+			`
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) FindUser(q *UserQuery) (*FindUserOuts, error) {
+	if *q.ID == "unknown" {
+		return nil, errors.New("not found")
+	}
+	return &FindUserOuts{User: &User{ID: q.ID}}, nil
+}
+
+func(s *Server) DeleteUser(q *UserQuery) error {
+	return nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("findUser", &UserQuery{ID: newString("123")}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if h := res.Header.Get("Cache-Control"); h != "max-age=60, public" {
+		log.Fatalf("cache control was %q", h)
+	}
+	if h := res.Header.Get("X-Api-Hint"); h != "\"cached\" & <stable>" {
+		log.Fatalf("hint was %q", h)
+	}
+
+	// failed responses and other messages lack the headers
+	res, err = http.Post(s.URL+"/v1/http", "application/json", newMessageReader("findUser", &UserQuery{ID: newString("unknown")}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 500 || res.Header.Get("Cache-Control") != "" {
+		log.Fatalf("status code was %v with cache control %q", res.StatusCode, res.Header.Get("Cache-Control"))
+	}
+	res, err = http.Post(s.URL+"/v1/http", "application/json", newMessageReader("deleteUser", &UserQuery{ID: newString("123")}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 || res.Header.Get("Cache-Control") != "" {
+		log.Fatalf("delete: status code was %v with cache control %q", res.StatusCode, res.Header.Get("Cache-Control"))
+	}
+}
+	`,
+		},
+		{
+			"multipart form binding",
+			fixture.TestSchemaFormUpload,
+			`
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) UploadAvatar(u *AvatarUpload) (*UploadAvatarOuts, error) {
+	image, err := base64.StdEncoding.DecodeString(*u.Image)
+	if err != nil {
+		return nil, err
+	}
+	if *u.Width != 64 || fmt.Sprint(u.Tags) != "[a b]" {
+		return nil, fmt.Errorf("width %v and tags %v", *u.Width, u.Tags)
+	}
+	if *u.Name == "john" && string(image) != "\x89PNG\xff" {
+		return nil, fmt.Errorf("image was %q", image)
+	}
+	size := int64(len(image))
+	return &UploadAvatarOuts{Avatar: &Avatar{Name: u.Name, Size: &size}}, nil
+}
+
+func(s *Server) FindAvatar(a *Avatar) (*FindAvatarOuts, error) {
+	return &FindAvatarOuts{Avatar: a}, nil
+}
+
+// posts a multipart form with a name, width, tags and an image file
+func post(url string, width string) *http.Response {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	w.WriteField("name", "john")
+	w.WriteField("width", width)
+	w.WriteField("tags", "a")
+	w.WriteField("tags", "b")
+	f, err := w.CreateFormFile("image", "avatar.png")
+	if err != nil {
+		log.Fatal(err)
+	}
+	f.Write([]byte("\x89PNG\xff"))
+	w.Close()
+
+	res, err := http.Post(url, w.FormDataContentType(), body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return res
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	res := post(s.URL+"/v1/http?msg=uploadAvatar", "64")
+	raw, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if res.StatusCode != 200 {
+		log.Fatalf("status code was %v: %s", res.StatusCode, raw)
+	}
+	var out struct {
+		Msg  string
+		Data Avatar
+	}
+	err = json.Unmarshal(raw, &out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if out.Msg != "avatar" || *out.Data.Name != "john" || *out.Data.Size != 5 {
+		log.Fatalf("response was %s", raw)
+	}
+
+	// unparsable fields
+	res = post(s.URL+"/v1/http?msg=uploadAvatar", "wide")
+	raw, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 422 || !bytes.Contains(raw, []byte("invalid form field width")) {
+		log.Fatalf("invalid width: status code was %v: %s", res.StatusCode, raw)
+	}
+
+	// messages without forms
+	res = post(s.URL+"/v1/http?msg=findAvatar", "64")
+	res.Body.Close()
+	if res.StatusCode != 415 {
+		log.Fatalf("findAvatar: status code was %v", res.StatusCode)
+	}
+
+	// JSON is still accepted
+	res, err = http.Post(s.URL+"/v1/http", "application/json", newMessageReader("uploadAvatar", &AvatarUpload{
+		Name:  newString("jane"),
+		Width: newInt64(64),
+		Tags:  []string{"a", "b"},
+		Image: newString(base64.StdEncoding.EncodeToString([]byte("gif"))),
+	}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		log.Fatalf("json: status code was %v", res.StatusCode)
+	}
+}
+	`,
+		},
+		{
+			"request ID echoed and logged",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout(session *Session) (*LogoutOuts, error) {
+	return &LogoutOuts{Message: &Message{Message: newString("bye")}}, nil
+}
+
+type requestLogger struct {
+	ids []string
+}
+
+func (l *requestLogger) LogPayloads(in []byte, out []byte, statusCode int) {
+	log.Fatal("request logger received payloads without request ID")
+}
+
+func (l *requestLogger) LogRequestPayloads(requestID string, in []byte, out []byte, statusCode int) {
+	l.ids = append(l.ids, requestID)
+}
+
+func post(url string, id string) string {
+	req, err := http.NewRequest("POST", url, bytes.NewBufferString(` + "`" + `{"msg": "logout", "data": {"id": "123"}}` + "`" + `))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		log.Fatalf("status code was %v", res.StatusCode)
+	}
+	return res.Header.Get(RequestIDHeader)
+}
+
+func main() {
+	l := &requestLogger{}
+	s := httptest.NewServer(NewAPIMuxWithOptions(&Server{}, APIOptions{PayloadLogger: l}))
+	defer s.Close()
+
+	// given ID is echoed
+	if id := post(s.URL+"/v1/http", "trace-42"); id != "trace-42" {
+		log.Fatalf("request ID was %q", id)
+	}
+
+	// missing and oversized IDs are generated
+	generated := post(s.URL+"/v1/http", "")
+	if len(generated) != 32 {
+		log.Fatalf("generated request ID was %q", generated)
+	}
+	if id := post(s.URL+"/v1/http", strings.Repeat("a", 201)); len(id) != 32 || id == generated {
+		log.Fatalf("request ID of oversized ID was %q", id)
+	}
+
+	if len(l.ids) != 3 || l.ids[0] != "trace-42" || l.ids[1] != generated {
+		log.Fatalf("logged request IDs were %v", l.ids)
+	}
+}
+	`,
+		},
+		{
+			"envelope",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout(session *Session) (*LogoutOuts, error) {
+	return &LogoutOuts{Message: &Message{Message: newString("bye")}}, nil
+}
+
+func main() {
+	// wire shape
+	e, err := NewEnvelope(MsgLoginWithCredentials, &Credentials{Name: newString("john"), Password: newString("secret")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if string(b) != ` + "`" + `{"msg":"loginWithCredentials","data":{"name":"john","password":"secret"}}` + "`" + ` {
+		log.Fatalf("envelope was %s", b)
+	}
+	empty, err := NewEnvelope("logout", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if b, _ := json.Marshal(empty); string(b) != ` + "`" + `{"msg":"logout"}` + "`" + ` {
+		log.Fatalf("envelope without data was %s", b)
+	}
+
+	// round trip through the server
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+	res, err := http.Post(s.URL+"/v1/http", "application/json", bytes.NewReader(b))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+	var out Envelope
+	err = json.NewDecoder(res.Body).Decode(&out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var session Session
+	err = out.UnmarshalData(&session)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if out.Msg != "session" || *session.ID != "123" {
+		log.Fatalf("out was %v %v", out.Msg, session)
+	}
+}
+	`,
+		},
+		{
+			"conditionally required properties",
+			fixture.TestSchemaConditionalRequired,
+			`
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) Pay(in *Payment) (*PayOuts, error) {
+	return &PayOuts{Payment: in}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	tbl := []struct {
+		data   string
+		status int
+		body   string
+	}{
+		{` + "`" + `{"type": "card", "cardNumber": "4242"}` + "`" + `, 200, "4242"},
+		{` + "`" + `{"type": "card", "iban": "DE89"}` + "`" + `, 422, "invalid payment: cardNumber is required if type is"},
+		{` + "`" + `{"type": "transfer", "iban": "DE89"}` + "`" + `, 200, "DE89"},
+		{` + "`" + `{"type": "transfer", "cardNumber": "4242"}` + "`" + `, 422, "invalid payment: iban is required unless type is"},
+	}
+	for _, ts := range tbl {
+		res, err := http.Post(s.URL+"/v1/http", "application/json", bytes.NewBufferString(` + "`" + `{"msg": "pay", "data": ` + "`" + `+ts.data+` + "`" + `}` + "`" + `))
+		if err != nil {
+			log.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if res.StatusCode != ts.status || !bytes.Contains(b, []byte(ts.body)) {
+			log.Fatalf("%v: status code was %v with %s", ts.data, res.StatusCode, b)
+		}
+	}
+}
+	`,
+		},
+		{
+			"tuples",
+			fixture.TestSchemaTuples,
+			`
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) Locate(p *Place) (*LocateOuts, error) {
+	return &LocateOuts{Place: p}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	tbl := []struct {
+		data   string
+		status int
+		body   string
+	}{
+		{` + "`" + `{"name": "home", "location": [52.52, 13.4]}` + "`" + `, 200, "[52.52,13.4]"},
+		{` + "`" + `{"name": "home", "location": [52.52]}` + "`" + `, 422, "invalid"},
+		{` + "`" + `{"name": "home", "location": [52.52, 13.4, 1]}` + "`" + `, 422, "unparsable"},
+		{` + "`" + `{"name": "home", "location": {"item0": 52.52, "item1": 13.4}}` + "`" + `, 422, "unparsable"},
+	}
+	for _, ts := range tbl {
+		res, err := http.Post(s.URL+"/v1/http", "application/json", bytes.NewBufferString(` + "`" + `{"msg": "locate", "data": ` + "`" + `+ts.data+` + "`" + `}` + "`" + `))
+		if err != nil {
+			log.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if res.StatusCode != ts.status || !bytes.Contains(bytes.Join(bytes.Fields(b), nil), []byte(ts.body)) {
+			log.Fatalf("%v: status code was %v with %s", ts.data, res.StatusCode, b)
+		}
+	}
+
+	// a tuple round-trips as array
+	c := &Coordinates{Item0: newFloat64(1.5), Item1: newFloat64(-2)}
+	b, err := json.Marshal(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var back Coordinates
+	err = json.Unmarshal(b, &back)
+	if err != nil || string(b) != "[1.5,-2]" || *back.Item0 != 1.5 || *back.Item1 != -2 {
+		log.Fatalf("round trip was %s to %v: %v", b, back, err)
+	}
+}
+	`,
+		},
+		{
+			"validation fail with custom error schema",
+			fixture.TestSchemaCustomErrorSchema,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+)
+
+type Server struct{}
+
+func(s *Server) SayHello(m *Message) (*SayHelloOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("sayHello", &Message{}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if res.StatusCode != 422 {
+		log.Fatal("status code not 422")
+	}
+
+	var rm message
+	err = json.Unmarshal(raw, &rm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if rm.Msg != "apiError" {
+		log.Fatalf("response message was: %v", rm.Msg)
+	}
+
+	var e ApiError
+	err = json.Unmarshal(rm.Data, &e)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = e.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *e.Reason != "invalid message: missing message" {
+		log.Fatalf("error was: %s", *e.Reason)
+	}
+}
+	`,
+		},
+		{
+			"output validation fail",
+			fixture.TestSchemaValidationSpec,
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+)
+
+type Server struct{}
+
+func(s *Server) SayHello(m *Message) (*SayHelloOuts, error) {
+	return &SayHelloOuts{&Message{}}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	m := &Message{newString("hello")}
+	
+	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("sayHello", m))
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var rm message
+	err = json.Unmarshal(raw, &rm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if res.StatusCode != 500 {
+		log.Fatal("status code not 500")
+	}
+
+	if rm.Msg != "error" {
+		log.Fatalf("response message was: %v", rm.Msg)
+	}
+	
+	var e errorData
+	err = json.Unmarshal(rm.Data, &e)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if e.Error != "internal error" {
+		log.Fatalf("error was: %s", e.Error)
+	}
+}
+	`,
+		},
+	}
+	for _, ts := range table {
+		spec, err := jsonmsg.Parse([]byte(ts.RawSchema))
+		if err != nil {
+			t.Fatal(ts.Name, err)
+		}
+		src, err := ServerPackageSrc(spec, "main")
+		if err != nil {
+			t.Fatal(ts.Name, err)
+		}
+
+		out, err := compileAndRun([]byte(ts.Code), src)
+		if err != nil {
+			t.Fatal(ts.Name, err)
+		}
+
+		if out != "" {
+			t.Fatalf("%v: should have produced 'ok', but produced '%v'", ts.Name, out)
+		}
+	}
+}
+
+func TestGenerateGoSpecHandler(t *testing.T) {
+	table := []struct {
+		Name      string
+		RawSchema string
+		Code      string
+	}{
+		{
+			"GET /spec.json with response",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+	"strings"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return nil, errors.New("not implemented")
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, errors.New("not implemented")
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+	
+	res, err := http.Get(s.URL+"/v1/spec.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if res.StatusCode != 200 {
+		log.Fatal("status code not 200, but: %v", res.StatusCode)
+	}
+
+	a := strings.Replace(string(newEmbeddedSpec()), "  ", "", -1)
+	b := strings.Replace(string(raw), "  ", "", -1)
+	a = strings.Replace(a, "\n", "", -1)
+	b = strings.Replace(b, "\n", "", -1)
+
+	if a != b {
+		log.Fatalf("response data was: \n'%s'\n should be: \n'%s'", b, a)
+	}
+}
+			`,
+		},
+		{
+			"GET /spec with response",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return nil, errors.New("not implemented")
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, errors.New("not implemented")
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+	
+	res, err := http.Get(s.URL+"/v1/spec")
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if res.StatusCode != 200 {
+		log.Fatal("status code not 200, but: %v", res.StatusCode)
+	}
+	
+	if res.Header.Get("Content-Type") != "text/html" {
+		log.Fatal("content type not text/html, but: %v", res.Header.Get("Content-Type"))
+	}
+
+	if len(raw) < 400 {
+		log.Fatalf("Response content suspiciously short: %v", raw)
+	}
+}
+			`,
+		},
+		{
+			"conditional GET /spec.json and /spec => 304",
+			fixture.TestSchemaSimpleLogin,
+			`
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return nil, errors.New("not implemented")
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, errors.New("not implemented")
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	for _, p := range []string{"/v1/spec.json", "/v1/spec"} {
+		res, err := http.Get(s.URL+p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		if res.StatusCode != 200 {
+			log.Fatalf("%v: status code not 200, but: %v", p, res.StatusCode)
+		}
+		etag := res.Header.Get("ETag")
+		if etag == "" {
+			log.Fatalf("%v: missing etag", p)
+		}
+
+		// the etag is of the response body
+		if sum := fmt.Sprintf("\"%x\"", sha256.Sum256(body)); etag != sum {
+			log.Fatalf("%v: etag was %v, expected %v", p, etag, sum)
+		}
+
+		tbl := []struct{
+			IfNoneMatch string
+			Status int
+		}{
+			{etag, 304},
+			{"W/" + etag, 304},
+			{` + "`" + `"other", ` + "`" + ` + etag, 304},
+			{` + "`" + `"a,b",` + "`" + ` + etag + ` + "`" + `, "c"` + "`" + `, 304},
+			{"*", 304},
+			{` + "`" + `"other"` + "`" + `, 200},
+			{` + "`" + `"a,` + "`" + ` + etag, 200},
+		}
+		for _, e := range tbl {
+			req, err := http.NewRequest("GET", s.URL+p, nil)
+			if err != nil {
+				log.Fatal(err)
+			}
+			req.Header.Set("If-None-Match", e.IfNoneMatch)
+			res, err = http.DefaultClient.Do(req)
+			if err != nil {
+				log.Fatal(err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != e.Status {
+				log.Fatalf("%v: If-None-Match %v: status code not %v, but: %v", p, e.IfNoneMatch, e.Status, res.StatusCode)
+			}
+		}
+	}
+}
+			`,
+		},
+	}
+	for _, ts := range table {
+		spec, err := jsonmsg.Parse([]byte(ts.RawSchema))
+		if err != nil {
+			t.Fatal(ts.Name, err)
+		}
+		src, err := ServerPackageSrc(spec, "main")
+		if err != nil {
+			t.Fatal(ts.Name, err)
+		}
+
+		out, err := compileAndRun([]byte(ts.Code), src)
+		if err != nil {
+			t.Fatal(ts.Name, err)
+		}
+
+		if out != "" {
+			t.Fatalf("%v: should have produced 'ok', but produced '%v'", ts.Name, out)
+		}
+	}
+}
+
+func TestGenerateGoServerWithOptions(t *testing.T) {
+	table := []struct {
+		Name      string
+		RawSchema string
+		Options   Options
+		Code      string
+	}{
+		{
+			"websocket session persists between messages",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			Options{Sessions: true},
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(conn *Conn, c *Credentials) (*LoginWithCredentialsOuts, error) {
+	conn.Set("user", *c.Name)
+	return &LoginWithCredentialsOuts{
+		Session: &Session{ID: newString("foo")},
+	}, nil
+}
+
+func(s *Server) Logout(conn *Conn, sess *Session) (*LogoutOuts, error) {
+	user, ok := conn.Get("user").(string)
+	if !ok {
+		return &LogoutOuts{Message: &Message{newString("not logged in")}}, nil
+	}
+	conn.Delete("user")
+	return &LogoutOuts{Message: &Message{newString("bye " + user)}}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	url := strings.Replace(s.URL, "http://", "ws://", 1)
+	conn, _, err := websocket.DefaultDialer.Dial(url + "/v1/websocket", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	send := func(msg string, data interface{}) message {
+		o, err := json.Marshal(outMessage{Msg: msg, Data: data})
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = conn.WriteMessage(websocket.TextMessage, o)
+		if err != nil {
+			log.Fatal(err)
+		}
+		_, mr, err := conn.ReadMessage()
+		if err != nil {
+			log.Fatalf("read failed: %v", err)
+		}
+		var rm message
+		err = json.Unmarshal(mr, &rm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return rm
+	}
+
+	rm := send("loginWithCredentials", Credentials{Name: newString("john"), Password: newString("snow")})
+	if rm.Msg != "session" {
+		log.Fatalf("response message was: %v", rm.Msg)
+	}
+
+	rm = send("logout", Session{ID: newString("foo")})
+	var m Message
+	err = json.Unmarshal(rm.Data, &m)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *m.Message != "bye john" {
+		log.Fatalf("message was: %s", *m.Message)
+	}
+
+	// http is stateless
+	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("loginWithCredentials", Credentials{Name: newString("john")}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	res, err = http.Post(s.URL+"/v1/http", "application/json", newMessageReader("logout", Session{ID: newString("foo")}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = json.Unmarshal(raw, &rm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = json.Unmarshal(rm.Data, &m)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *m.Message != "not logged in" {
+		log.Fatalf("message was: %s", *m.Message)
+	}
+}
+			`,
+		},
+		{
+			"hub broadcasts to other connections",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			Options{Sessions: true},
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type Server struct{
+	hub *Hub
+}
+
+func(s *Server) LoginWithCredentials(conn *Conn, c *Credentials) (*LoginWithCredentialsOuts, error) {
+	s.hub.Broadcast("notification", Message{newString(*c.Name + " logged in")})
+	s.hub.Send(conn.ID(), "notification", Message{newString("welcome")})
+	return &LoginWithCredentialsOuts{
+		Session: &Session{ID: newString("foo")},
+	}, nil
+}
+
+func(s *Server) Logout(conn *Conn, sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	hub := NewHub()
+	s := httptest.NewServer(NewAPIMuxWithOptions(&Server{hub}, APIOptions{Hub: hub}))
+	defer s.Close()
+
+	url := strings.Replace(s.URL, "http://", "ws://", 1)
+	a, _, err := websocket.DefaultDialer.Dial(url + "/v1/websocket", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer a.Close()
+	b, _, err := websocket.DefaultDialer.Dial(url + "/v1/websocket", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer b.Close()
+
+	// wait for registration
+	for i := 0; len(hub.ConnIDs()) != 2; i++ {
+		if i > 100 {
+			log.Fatalf("connections not registered: %v", hub.ConnIDs())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	read := func(conn *websocket.Conn) (string, string) {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, mr, err := conn.ReadMessage()
+		if err != nil {
+			log.Fatalf("read failed: %v", err)
+		}
+		var rm message
+		err = json.Unmarshal(mr, &rm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var m Message
+		json.Unmarshal(rm.Data, &m)
+		if m.Message == nil {
+			return rm.Msg, ""
+		}
+		return rm.Msg, *m.Message
+	}
+
+	o, err := json.Marshal(outMessage{Msg: "loginWithCredentials", Data: Credentials{Name: newString("john")}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = a.WriteMessage(websocket.TextMessage, o)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// other connection receives broadcast only
+	msg, text := read(b)
+	if msg != "notification" || text != "john logged in" {
+		log.Fatalf("received: %v %v", msg, text)
+	}
+
+	// sender receives broadcast, direct message and response
+	expected := [][]string{
+		{"notification", "john logged in"},
+		{"notification", "welcome"},
+		{"session", ""},
+	}
+	for _, e := range expected {
+		msg, text := read(a)
+		if msg != e[0] || text != e[1] {
+			log.Fatalf("received: %v %v, expected: %v", msg, text, e)
+		}
+	}
+}
+			`,
+		},
+		{
+			"spec routes disabled",
+			fixture.TestSchemaSimpleLogin,
+			Options{SpecJSONPath: "-", SpecHTMLPath: "-"},
+			`
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("foo")}}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	for _, p := range []string{"/v1/spec", "/v1/spec.json"} {
+		res, err := http.Get(s.URL+p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != 404 {
+			log.Fatalf("%v: status code was %v", p, res.StatusCode)
+		}
+	}
+
+	c := &Credentials{Name: newString("john"), Password: newString("snow")}
+	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("loginWithCredentials", c))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		log.Fatalf("message status code was %v", res.StatusCode)
+	}
+}
+			`,
+		},
+		{
+			"custom spec route paths",
+			fixture.TestSchemaSimpleLogin,
+			Options{SpecJSONPath: "/docs/api.json", SpecHTMLPath: "/docs/api"},
+			`
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return nil, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	tbl := []struct{
+		Path string
+		Status int
+		ContentType string
+	}{
+		{"/docs/api.json", 200, "application/json"},
+		{"/docs/api", 200, "text/html"},
+		{"/v1/spec.json", 404, ""},
+		{"/v1/spec", 404, ""},
+	}
+
+	for _, e := range tbl {
+		res, err := http.Get(s.URL+e.Path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != e.Status {
+			log.Fatalf("%v: status code was %v", e.Path, res.StatusCode)
+		}
+		if e.ContentType != "" && res.Header.Get("Content-Type") != e.ContentType {
+			log.Fatalf("%v: content type was %v", e.Path, res.Header.Get("Content-Type"))
+		}
+	}
+}
+			`,
+		},
+		{
+			"spec embedding disabled",
+			fixture.TestSchemaSimpleLogin,
+			Options{NoEmbeddedSpec: true},
+			`
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"os"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("foo")}}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	// spec literal is not compiled in
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	bin, err := ioutil.ReadFile(exe)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if bytes.Contains(bin, []byte("api.specc.io")) {
+		log.Fatal("binary contains the spec")
+	}
+
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	for _, p := range []string{"/v1/spec", "/v1/spec.json"} {
+		res, err := http.Get(s.URL+p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != 404 {
+			log.Fatalf("%v: status code was %v", p, res.StatusCode)
+		}
+	}
+
+	res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("fetchSpec", nil))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		log.Fatalf("fetchSpec status code was %v", res.StatusCode)
+	}
+
+	c := &Credentials{Name: newString("john"), Password: newString("snow")}
+	res, err = http.Post(s.URL+"/v1/http", "application/json", newMessageReader("loginWithCredentials", c))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		log.Fatalf("message status code was %v", res.StatusCode)
+	}
+}
+			`,
+		},
+		{
+			"streamed array outs",
+			fixture.TestSchemaArrayOuts,
+			Options{StreamArrays: true},
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"strconv"
+)
+
+type Server struct{}
+
+func(s *Server) ListUsers(q *ListQuery) (*ListUsersOuts, error) {
+	users := make(Users, *q.Limit)
+	for i := range users {
+		users[i] = User{ID: newString(strconv.Itoa(i)), Name: newString("user")}
+	}
+	return &ListUsersOuts{Users: &users}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	for _, limit := range []int64{0, 1, 10000} {
+		res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("listUsers", &ListQuery{Limit: &limit}))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if res.StatusCode != 200 {
+			log.Fatalf("status code was %v", res.StatusCode)
+		}
+		if len(res.TransferEncoding) != 1 || res.TransferEncoding[0] != "chunked" {
+			log.Fatalf("transfer encoding was %v", res.TransferEncoding)
+		}
+
+		var rm struct {
+			Msg string
+			Data Users
+		}
+		err = json.NewDecoder(res.Body).Decode(&rm)
+		res.Body.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if rm.Msg != "users" {
+			log.Fatalf("response message was %v", rm.Msg)
+		}
+		if int64(len(rm.Data)) != limit {
+			log.Fatalf("received %v users, expected %v", len(rm.Data), limit)
+		}
+		for i, u := range rm.Data {
+			if *u.ID != strconv.Itoa(i) || *u.Name != "user" {
+				log.Fatalf("user %v was %v", i, u)
+			}
+		}
+	}
+}
+			`,
+		},
+		{
+			"health check route",
+			fixture.TestSchemaSimpleLogin,
+			Options{Health: true},
+			`
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return nil, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	res, err := http.Get(s.URL + "/v1/health")
+	if err != nil {
+		log.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if res.StatusCode != 200 {
+		log.Fatalf("status code was %v", res.StatusCode)
+	}
+	if res.Header.Get("Content-Type") != "application/json" {
+		log.Fatalf("content type was %v", res.Header.Get("Content-Type"))
+	}
+	if string(body) != ` + "`" + `{"status":"ok"}` + "`" + `+"\n" {
+		log.Fatalf("body was %s", body)
+	}
+
+	res, err = http.Post(s.URL + "/v1/health", "application/json", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 405 {
+		log.Fatalf("status code of POST was %v", res.StatusCode)
+	}
+}
+			`,
+		},
+		{
+			"metrics route",
+			fixture.TestSchemaSimpleLogin,
+			Options{Metrics: true},
+			`
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"strings"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func scrape(url string) string {
+	res, err := http.Get(url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if res.StatusCode != 200 {
+		log.Fatalf("status code was %v", res.StatusCode)
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/plain") {
+		log.Fatalf("content type was %v", res.Header.Get("Content-Type"))
+	}
+	return string(body)
+}
+
+func login(url string) {
+	res, err := http.Post(url, "application/json", bytes.NewBufferString(` + "`" + `{"msg": "loginWithCredentials", "data": {"name": "john", "password": "secret"}}` + "`" + `))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		log.Fatalf("status code was %v", res.StatusCode)
+	}
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	body := scrape(s.URL + "/v1/metrics")
+	if strings.Contains(body, "loginWithCredentials") {
+		log.Fatalf("metrics before any message were %s", body)
+	}
+
+	login(s.URL + "/v1/http")
+	body = scrape(s.URL + "/v1/metrics")
+	if !strings.Contains(body, ` + "`" + `jsonmsg_messages_total{msg="loginWithCredentials",code="200"} 1` + "`" + `+"\n") ||
+		!strings.Contains(body, ` + "`" + `jsonmsg_message_duration_seconds_count{msg="loginWithCredentials"} 1` + "`" + `+"\n") {
+		log.Fatalf("metrics after a message were %s", body)
+	}
+
+	login(s.URL + "/v1/http")
+	body = scrape(s.URL + "/v1/metrics")
+	if !strings.Contains(body, ` + "`" + `jsonmsg_messages_total{msg="loginWithCredentials",code="200"} 2` + "`" + `+"\n") {
+		log.Fatalf("metrics after two messages were %s", body)
+	}
+
+	// unknown messages are not collected
+	res, err := http.Post(s.URL + "/v1/http", "application/json", bytes.NewBufferString(` + "`" + `{"msg": "unknown"}` + "`" + `))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if body = scrape(s.URL + "/v1/metrics"); strings.Contains(body, "unknown") {
+		log.Fatalf("metrics of unknown message were %s", body)
+	}
+}
+			`,
+		},
+		{
+			"error discards outs by default",
+			fixture.TestSchemaSimpleLogin,
+			Options{},
+			`
+package main
+
+import (
+	"errors"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("partial")}}, errors.New("profile unavailable")
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, errors.New("failed")
+}
+
+func main() {
+	out, status := ProcessMessage(&Server{}, "loginWithCredentials", &Credentials{Name: newString("abc")})
+	if status != 500 {
+		log.Fatalf("status code was %v", status)
+	}
+	if out != InternalErrorMessage {
+		log.Fatalf("out was %#v", out)
+	}
+
+	// without outs the error wins
+	_, status = ProcessMessage(&Server{}, "logout", &Session{ID: newString("123")})
+	if status != 500 {
+		log.Fatalf("logout: status code was %v", status)
+	}
+}
+			`,
+		},
+		{
+			"outs on error answered with status",
+			fixture.TestSchemaSimpleLogin,
+			Options{OutsOnErrorStatus: 206},
+			`
+package main
+
+import (
+	"errors"
+	"log"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("partial")}}, errors.New("profile unavailable")
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, errors.New("failed")
+}
+
+func main() {
+	out, status := ProcessMessage(&Server{}, "loginWithCredentials", &Credentials{Name: newString("abc")})
+	if status != 206 {
+		log.Fatalf("status code was %v", status)
+	}
+	if out.(outMessage).Msg != "session" {
+		log.Fatalf("out was %#v", out)
+	}
+
+	// without outs the error wins
+	_, status = ProcessMessage(&Server{}, "logout", &Session{ID: newString("123")})
+	if status != 500 {
+		log.Fatalf("logout: status code was %v", status)
+	}
+}
+			`,
+		},
+		{
+			"fast JSON marshaling equals encoding/json",
+			fixture.TestSchemaUserProfile,
+			Options{FastJSON: true},
+			`
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"math"
+)
+
+// without the generated MarshalJSON method
+type plainUser User
+
+func newFloat(f float64) *float64 {
+	return &f
+}
+
+func compare(u *User) {
+	fast, err := json.Marshal(u)
+	if err != nil {
+		log.Fatal(err)
+	}
+	plain, err := json.Marshal((*plainUser)(u))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !bytes.Equal(fast, plain) {
+		log.Fatalf("fast path encoded\n%s\ninstead of\n%s", fast, plain)
+	}
+}
+
+func main() {
+	u := &User{
+		ID:         newString("1"),
+		Name:       newString("John"),
+		Age:        newInt64(-42),
+		Score:      newFloat(1e21),
+		Active:     newBool(true),
+		Tags:       []string{"a", "b"},
+		Address:    &UserAddress{Street: newString("Main St."), Lat: newFloat(0.1)},
+		Contacts:   []Contact{{ID: newString("2"), Primary: newBool(true)}, {}},
+		Attributes: map[string]interface{}{"b": 1.5, "a": []interface{}{"x", nil}},
+	}
+	compare(u)
+	compare(&User{
+		Name:   newString("tab\t \"quote\" \\ <html> & \u2028 \x01 ü"),
+		Score:  newFloat(1e-7),
+		Tags:   []string{},
+		Active: newBool(false),
+	})
+	compare(&User{})
+	for _, f := range []float64{0, -0.000001, 123456789, 1.5e300, -2.5e-10} {
+		compare(&User{Score: newFloat(f)})
+	}
+
+	// unsupported numbers fail like encoding/json
+	_, err := json.Marshal(&User{Score: newFloat(math.Inf(1))})
+	if err == nil {
+		log.Fatal("infinite score was encoded")
+	}
+}
+			`,
+		},
+		{
+			"relative endpoints",
+			fixture.TestSchemaRelativeEndpoints,
+			Options{Client: true},
+			`
+package main
+
+import (
+	"log"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func main() {
+	if HTTPEndpointPath != "/v1/http" {
+		log.Fatalf("http endpoint path was %v", HTTPEndpointPath)
+	}
+
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+	c := NewClientWithBase(s.URL + "/")
+	outs, err := c.LoginWithCredentials(&Credentials{Name: newString("john"), Password: newString("secret")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outs.Session == nil || *outs.Session.ID != "123" {
+		log.Fatalf("outs were %v", outs)
+	}
+}
+			`,
+		},
+		{
+			"string formats validated",
+			fixture.TestSchemaFormats,
+			Options{ValidateFormats: true},
+			`
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) Signup(in *Signup) (*SignupOuts, error) {
+	return &SignupOuts{Signup: in}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	tbl := []struct {
+		data   string
+		status int
+		body   string
+	}{
+		{` + "`" + `{"email": "john@example.com", "homepage": "https://example.com", "id": "123e4567-e89b-12d3-a456-426614174000", "ips": ["10.0.0.1"], "birthday": "someday"}` + "`" + `, 200, ""},
+		{` + "`" + `{"email": "john"}` + "`" + `, 422, "invalid signup: email must be formatted as email"},
+		{` + "`" + `{"email": "john@example.com", "homepage": "/relative"}` + "`" + `, 422, "invalid signup: homepage must be formatted as uri"},
+		{` + "`" + `{"email": "john@example.com", "id": "123"}` + "`" + `, 422, "invalid signup: id must be formatted as uuid"},
+		{` + "`" + `{"email": "john@example.com", "ips": ["10.0.0.1", "::1"]}` + "`" + `, 422, "invalid signup: ips items must be formatted as ipv4"},
+	}
+	for _, ts := range tbl {
+		res, err := http.Post(s.URL+"/v1/http", "application/json", bytes.NewBufferString(` + "`" + `{"msg": "signup", "data": ` + "`" + `+ts.data+` + "`" + `}` + "`" + `))
+		if err != nil {
+			log.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if res.StatusCode != ts.status || !bytes.Contains(b, []byte(ts.body)) {
+			log.Fatalf("%v: status code was %v with %s", ts.data, res.StatusCode, b)
+		}
+	}
+}
+			`,
+		},
+		{
+			"batch message answers all outs",
+			fixture.TestSchemaBatchOuts,
+			Options{Client: true},
+			`
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+type Server struct{}
+
+func(s *Server) Search(q *Query) (*SearchOuts, error) {
+	return &SearchOuts{
+		Results: &Results{Names: []string{*q.Term + "1", *q.Term + "2"}},
+		Meta: &Meta{Total: newInt64(2)},
+	}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	// http answers an array of out messages
+	res, err := http.Post(s.URL+"/v1/http", "application/json", bytes.NewBufferString(` + "`" + `{"msg": "search", "data": {"term": "john"}}` + "`" + `))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var batch []Envelope
+	err = json.NewDecoder(res.Body).Decode(&batch)
+	res.Body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(batch) != 2 || batch[0].Msg != "results" || batch[1].Msg != "meta" {
+		log.Fatalf("batch was %v", batch)
+	}
+
+	// websocket answers a frame per out message
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(s.URL, "http://", "ws://", 1)+"/v1/websocket", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	err = conn.WriteMessage(websocket.TextMessage, []byte(` + "`" + `{"msg": "search", "data": {"term": "john"}}` + "`" + `))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, msg := range []string{"results", "meta"} {
+		var f struct {
+			Msg       string
+			Remaining int
+		}
+		err = conn.ReadJSON(&f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if f.Msg != msg || f.Remaining != 1-i {
+			log.Fatalf("frame was %v with %v remaining, expected %v", f.Msg, f.Remaining, msg)
+		}
+	}
+
+	// the client sets all outs
+	outs, err := NewClient(s.URL + "/v1/http").Search(&Query{Term: newString("john")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outs.Results == nil || len(outs.Results.Names) != 2 || outs.Meta == nil || *outs.Meta.Total != 2 {
+		log.Fatalf("outs were %v", outs)
+	}
+
+	// the websocket client collects the frames of a batch
+	c, err := NewWebsocketClient(strings.Replace(s.URL, "http://", "ws://", 1) + "/v1/websocket")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	for _, term := range []string{"john", "jane"} {
+		outs, err = c.Search(&Query{Term: newString(term)})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if outs.Results == nil || outs.Results.Names[0] != term+"1" || outs.Meta == nil || *outs.Meta.Total != 2 {
+			log.Fatalf("websocket outs were %v", outs)
+		}
+	}
+}
+			`,
+		},
+		{
+			"json-rpc requests",
+			fixture.TestSchemaSimpleLogin,
+			Options{JSONRPC: true},
+			`
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout(session *Session) (*LogoutOuts, error) {
+	return &LogoutOuts{Message: &Message{Message: newString("bye")}}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	tbl := []struct {
+		req    string
+		status int
+		res    string
+	}{
+		{
+			` + "`" + `{"jsonrpc": "2.0", "method": "loginWithCredentials", "params": {"name": "john", "password": "secret"}, "id": 1}` + "`" + `,
+			200,
+			` + "`" + `{"id":1,"jsonrpc":"2.0","result":{"msg":"session","data":{"id":"123"}}}` + "`" + `,
+		},
+		{
+			` + "`" + `{"jsonrpc": "2.0", "method": "signup", "params": {}, "id": "a"}` + "`" + `,
+			200,
+			` + "`" + `{"error":{"code":-32601,"message":"Not Found","data":{"msg":"error","data":{"error":"unknown message"}}},"id":"a","jsonrpc":"2.0"}` + "`" + `,
+		},
+		{
+			` + "`" + `{"jsonrpc": "2.0", "method": "loginWithCredentials", "params": {"name": 5}, "id": 2}` + "`" + `,
+			200,
+			` + "`" + `"code":-32602` + "`" + `,
+		},
+		{
+			` + "`" + `{"jsonrpc": "1.0", "method": "logout", "id": 3}` + "`" + `,
+			200,
+			` + "`" + `{"error":{"code":-32600,"message":"Invalid Request"},"id":null,"jsonrpc":"2.0"}` + "`" + `,
+		},
+		{
+			` + "`" + `{"jsonrpc": "2.0", "method": "logout", "params": {"id": "123"}}` + "`" + `,
+			204,
+			"",
+		},
+		{
+			` + "`" + `[{"jsonrpc": "2.0", "method": "logout", "params": {"id": "123"}, "id": 4}, {"jsonrpc": "2.0", "method": "logout", "params": {"id": "123"}}]` + "`" + `,
+			200,
+			` + "`" + `[{"id":4,"jsonrpc":"2.0","result":{"msg":"message","data":{"message":"bye"}}}]` + "`" + `,
+		},
+		{
+			` + "`" + `{"jsonrpc": "2.0", "method"` + "`" + `,
+			200,
+			` + "`" + `{"error":{"code":-32700,"message":"Parse error"},"id":null,"jsonrpc":"2.0"}` + "`" + `,
+		},
+	}
+	for _, ts := range tbl {
+		res, err := http.Post(s.URL+"/v1/jsonrpc", "application/json", bytes.NewBufferString(ts.req))
+		if err != nil {
+			log.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		// compare without indentation
+		compact := &bytes.Buffer{}
+		json.Compact(compact, b)
+		if res.StatusCode != ts.status || !strings.Contains(compact.String(), ts.res) {
+			log.Fatalf("%v: status code was %v with %s", ts.req, res.StatusCode, b)
+		}
+	}
+}
+			`,
+		},
+		{
+			"all validation errors reported together",
+			fixture.TestSchemaFormats,
+			Options{ValidateFormats: true, AllValidationErrors: true},
+			`
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) Signup(in *Signup) (*SignupOuts, error) {
+	return &SignupOuts{Signup: in}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	data := ` + "`" + `{"email": "john", "id": "123", "ips": ["::1", "::2"]}` + "`" + `
+	res, err := http.Post(s.URL+"/v1/http", "application/json", bytes.NewBufferString(` + "`" + `{"msg": "signup", "data": ` + "`" + `+data+` + "`" + `}` + "`" + `))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+	var out struct {
+		Data struct {
+			Error string
+		}
+	}
+	err = json.NewDecoder(res.Body).Decode(&out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	expected := "invalid signup: email must be formatted as email; invalid signup: id must be formatted as uuid; invalid signup: ips items must be formatted as ipv4"
+	if res.StatusCode != 422 || out.Data.Error != expected {
+		log.Fatalf("status code was %v with %q", res.StatusCode, out.Data.Error)
+	}
+
+	// valid inputs pass
+	res, err = http.Post(s.URL+"/v1/http", "application/json", bytes.NewBufferString(` + "`" + `{"msg": "signup", "data": {"email": "john@example.com"}}` + "`" + `))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		log.Fatalf("status code of valid input was %v", res.StatusCode)
+	}
+}
+			`,
+		},
+		{
+			"tuples with fast json",
+			fixture.TestSchemaTuples,
+			Options{FastJSON: true},
+			`
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+)
+
+type Server struct{}
+
+func(s *Server) Locate(p *Place) (*LocateOuts, error) {
+	return &LocateOuts{Place: p}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	tbl := []struct {
+		data   string
+		status int
+		body   string
+	}{
+		{` + "`" + `{"name": "home", "location": [52.52, 13.4]}` + "`" + `, 200, "[52.52,13.4]"},
+		{` + "`" + `{"name": "home", "location": [52.52]}` + "`" + `, 422, "invalid"},
+		{` + "`" + `{"name": "home", "location": [52.52, 13.4, 1]}` + "`" + `, 422, "unparsable"},
+		{` + "`" + `{"name": "home", "location": {"item0": 52.52, "item1": 13.4}}` + "`" + `, 422, "unparsable"},
+	}
+	for _, ts := range tbl {
+		res, err := http.Post(s.URL+"/v1/http", "application/json", bytes.NewBufferString(` + "`" + `{"msg": "locate", "data": ` + "`" + `+ts.data+` + "`" + `}` + "`" + `))
+		if err != nil {
+			log.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if res.StatusCode != ts.status || !bytes.Contains(bytes.Join(bytes.Fields(b), nil), []byte(ts.body)) {
+			log.Fatalf("%v: status code was %v with %s", ts.data, res.StatusCode, b)
+		}
+	}
+
+	// a tuple round-trips as array
+	c := &Coordinates{Item0: newFloat64(1.5), Item1: newFloat64(-2)}
+	b, err := json.Marshal(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var back Coordinates
+	err = json.Unmarshal(b, &back)
+	if err != nil || string(b) != "[1.5,-2]" || *back.Item0 != 1.5 || *back.Item1 != -2 {
+		log.Fatalf("round trip was %s to %v: %v", b, back, err)
+	}
+}
+			`,
+		},
+		{
+			"request timeout cancels the context of API methods",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			Options{Context: true, Metrics: true},
+			`
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type Server struct {
+	cancelled chan error
+	returned  int32
+}
+
+// slow logins wait for their context, fast logins answer how many slow logins returned before
+func(s *Server) LoginWithCredentials(ctx context.Context, c *Credentials) (*LoginWithCredentialsOuts, error) {
+	if *c.Name == "slow" {
+		<-ctx.Done()
+		s.cancelled <- ctx.Err()
+		time.Sleep(100 * time.Millisecond)
+		atomic.AddInt32(&s.returned, 1)
+	}
+	return &LoginWithCredentialsOuts{
+		Session: &Session{ID: newString(strconv.Itoa(int(atomic.LoadInt32(&s.returned))))},
+	}, nil
+}
+
+func(s *Server) Logout(ctx context.Context, sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	srv := &Server{cancelled: make(chan error, 2)}
+	s := httptest.NewServer(NewAPIMuxWithOptions(srv, APIOptions{RequestTimeout: 50 * time.Millisecond}))
+	defer s.Close()
+
+	// timed out handlers are cancelled
+	res, err := http.Post(s.URL+"/v1/http", "application/json", bytes.NewBufferString(` + "`" + `{"msg": "loginWithCredentials", "data": {"name": "slow"}}` + "`" + `))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 503 {
+		log.Fatalf("status code was %v", res.StatusCode)
+	}
+	select {
+	case err := <-srv.cancelled:
+		if err != context.DeadlineExceeded {
+			log.Fatalf("context error was %v", err)
+		}
+	case <-time.After(time.Second):
+		log.Fatal("context was not cancelled")
+	}
+
+	// metrics count the answered status, not the one of the abandoned handler
+	for atomic.LoadInt32(&srv.returned) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	res, err = http.Get(s.URL + "/v1/metrics")
+	if err != nil {
+		log.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !strings.Contains(string(body), ` + "`" + `jsonmsg_messages_total{msg="loginWithCredentials",code="503"} 1` + "`" + `) || strings.Contains(string(body), ` + "`" + `code="200"` + "`" + `) {
+		log.Fatalf("metrics were %s", body)
+	}
+
+	// websocket messages are handled one at a time, after the abandoned handler returned
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(s.URL, "http://", "ws://", 1)+"/v1/websocket", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	for _, name := range []string{"slow", "fast"} {
+		err = conn.WriteMessage(websocket.TextMessage, []byte(` + "`" + `{"msg": "loginWithCredentials", "data": {"name": "` + "`" + `+name+` + "`" + `"}}` + "`" + `))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, expected := range []string{"timeout", "2"} {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var m struct {
+			Data struct {
+				Error string
+				ID    string
+			}
+		}
+		err = json.Unmarshal(raw, &m)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if m.Data.Error+m.Data.ID != expected {
+			log.Fatalf("expected %v, got %s", expected, raw)
+		}
+	}
+}
+			`,
+		},
+		{
+			"websocket client receives pushed messages",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			Options{Client: true},
+			`
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+type Server struct{
+	hub *Hub
+}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	s.hub.Broadcast("notification", Message{newString(*c.Name + " logged in")})
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return &LogoutOuts{Message: &Message{Message: newString("bye")}}, nil
+}
+
+func main() {
+	hub := NewHub()
+	s := httptest.NewServer(NewAPIMuxWithOptions(&Server{hub}, APIOptions{Hub: hub}))
+	defer s.Close()
+
+	// pushed messages need a websocket client
+	if NewClient(s.URL + "/v1/http").On("notification", func(json.RawMessage) {}) == nil {
+		log.Fatal("http client accepted a push handler")
+	}
+
+	c, err := NewWebsocketClient(strings.Replace(s.URL, "http://", "ws://", 1) + "/v1/websocket")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	notifications := make(chan string, 1)
+	err = c.On("notification", func(data json.RawMessage) {
+		var m Message
+		if err := json.Unmarshal(data, &m); err != nil {
+			log.Fatal(err)
+		}
+		notifications <- *m.Message
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the broadcast arrives before the response
+	outs, err := c.LoginWithCredentials(&Credentials{Name: newString("john"), Password: newString("secret")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outs.Session == nil || *outs.Session.ID != "123" {
+		log.Fatalf("outs were %v", outs)
+	}
+	select {
+	case n := <-notifications:
+		if n != "john logged in" {
+			log.Fatalf("notification was %q", n)
+		}
+	case <-time.After(5 * time.Second):
+		log.Fatal("handler of notification did not fire")
+	}
+
+	// requests keep working
+	logout, err := c.Logout(&Session{ID: newString("123")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if logout.Message == nil || *logout.Message.Message != "bye" {
+		log.Fatalf("logout outs were %v", logout)
+	}
+}
+			`,
+		},
+		{
+			"websocket client ignores unhandled pushed messages",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			Options{Client: true},
+			`
+package main
+
+import (
+	"log"
+	"net/http/httptest"
+	"strings"
+)
+
+type Server struct{
+	hub *Hub
+}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	s.hub.Broadcast("audit", Message{newString(*c.Name + " logged in")})
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return &LogoutOuts{Message: &Message{Message: newString("bye")}}, nil
+}
+
+func main() {
+	hub := NewHub()
+	s := httptest.NewServer(NewAPIMuxWithOptions(&Server{hub}, APIOptions{Hub: hub}))
+	defer s.Close()
+
+	c, err := NewWebsocketClient(strings.Replace(s.URL, "http://", "ws://", 1) + "/v1/websocket")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	// the broadcast without handler arrives while the request waits, but is no response
+	outs, err := c.LoginWithCredentials(&Credentials{Name: newString("john"), Password: newString("secret")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outs.Session == nil || *outs.Session.ID != "123" {
+		log.Fatalf("outs were %v", outs)
+	}
+
+	// the next request gets its own response
+	outs, err = c.LoginWithCredentials(&Credentials{Name: newString("jane"), Password: newString("secret")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outs.Session == nil || *outs.Session.ID != "123" {
+		log.Fatalf("outs of the next request were %v", outs)
+	}
+}
+			`,
+		},
+		{
+			"websocket client returns error messages as ResponseError",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			Options{Client: true},
+			`
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http/httptest"
+	"strings"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, errors.New("not logged in")
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	c, err := NewWebsocketClient(strings.Replace(s.URL, "http://", "ws://", 1) + "/v1/websocket")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	_, err = c.Logout(&Session{ID: newString("123")})
+	re, ok := err.(*ResponseError)
+	if !ok {
+		log.Fatalf("error was %#v", err)
+	}
+	if re.StatusCode != 0 || re.Msg != "error" || len(re.Data) == 0 {
+		log.Fatalf("response error was %+v", re)
+	}
+
+	// the connection serves the next request
+	outs, err := c.LoginWithCredentials(&Credentials{Name: newString("john"), Password: newString("secret")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outs.Session == nil || *outs.Session.ID != "123" {
+		log.Fatalf("outs were %v", outs)
+	}
+}
+			`,
+		},
+		{
+			"client of messages without outs",
+			fixture.TestSchemaStrictEmptyMessages,
+			Options{Client: true},
+			`
+package main
+
+import (
+	"log"
+	"net/http/httptest"
+)
+
+type Server struct{
+	pings int
+}
+
+func(s *Server) Ping() error {
+	s.pings++
+	return nil
+}
+
+func(s *Server) Logout() error {
+	return nil
+}
+
+func main() {
+	srv := &Server{}
+	s := httptest.NewServer(NewAPIMux(srv))
+	defer s.Close()
+
+	c := NewClient(s.URL + "/v1/http")
+	err := c.Ping()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = c.Logout()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if srv.pings != 1 {
+		log.Fatalf("server received %v pings", srv.pings)
+	}
+}
+			`,
+		},
+		{
+			"client validates inputs before sending",
+			fixture.TestSchemaRequiredCredentials,
+			Options{Client: true},
+			`
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http/httptest"
+)
+
+type Server struct{
+	requests int
+}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	s.requests++
+	if *c.Password != "secret" {
+		return nil, errors.New("wrong password")
+	}
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout() error {
+	s.requests++
+	return nil
+}
+
+func main() {
+	srv := &Server{}
+	s := httptest.NewServer(NewAPIMux(srv))
+	defer s.Close()
+	c := NewClient(s.URL + "/v1/http")
+
+	// invalid input fails without a request
+	_, err := c.LoginWithCredentials(&Credentials{Name: newString("john")})
+	if err == nil {
+		log.Fatal("credentials without password should not validate")
+	}
+	if srv.requests != 0 {
+		log.Fatalf("server received %v requests", srv.requests)
+	}
+
+	// valid input
+	outs, err := c.LoginWithCredentials(&Credentials{Name: newString("john"), Password: newString("secret")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outs.Session == nil || *outs.Session.ID != "123" {
+		log.Fatalf("outs were %v", outs)
+	}
+
+	// failing implementation
+	_, err = c.LoginWithCredentials(&Credentials{Name: newString("john"), Password: newString("wrong")})
+	re, ok := err.(*ResponseError)
+	if !ok || re.StatusCode != 500 || re.Msg != "error" {
+		log.Fatalf("error was %v", err)
+	}
+
+	// message without in and outs
+	err = c.Logout()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if srv.requests != 3 {
+		log.Fatalf("server received %v requests", srv.requests)
+	}
+}
+			`,
+		},
+		{
+			"client with in-memory transport",
+			fixture.TestSchemaMessageLocalSchemas,
+			Options{Client: true},
+			`
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+)
+
+type Server struct{
+	deleted string
+}
+
+func(s *Server) FindUser(in *FindUserIn) (*FindUserOuts, error) {
+	if *in.ID != "1" {
+		return nil, errors.New("user not found")
+	}
+	return &FindUserOuts{User: &User{ID: in.ID, Name: newString("john")}}, nil
+}
+
+func(s *Server) DeleteUser(in *FindUserIn) error {
+	s.deleted = *in.ID
+	return nil
+}
+
+// dispatches messages to the handlers without any network
+type memoryTransport struct {
+	handlers map[string]func(json.RawMessage) (interface{}, int)
+}
+
+func (t *memoryTransport) Do(msg string, data []byte) ([]byte, error) {
+	out, status := t.handlers[msg](data)
+	b, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	if status != 200 {
+		var m message
+		json.Unmarshal(b, &m)
+		return nil, &ResponseError{StatusCode: status, Msg: m.Msg, Data: m.Data}
+	}
+	return b, nil
+}
+
+func main() {
+	srv := &Server{}
+	c := &Client{Transport: &memoryTransport{Handlers(srv)}}
+
+	outs, err := c.FindUser(&FindUserIn{ID: newString("1")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outs.User == nil || *outs.User.Name != "john" {
+		log.Fatalf("outs were %v", outs)
+	}
+
+	// failing implementation
+	_, err = c.FindUser(&FindUserIn{ID: newString("2")})
+	re, ok := err.(*ResponseError)
+	if !ok || re.StatusCode != 500 || re.Msg != "error" {
+		log.Fatalf("error was %v", err)
+	}
+
+	// message without outs
+	err = c.DeleteUser(&FindUserIn{ID: newString("1")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if srv.deleted != "1" {
+		log.Fatalf("deleted user was %q", srv.deleted)
+	}
+}
+			`,
+		},
+		{
+			"routes under multiple versions",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			Options{Versions: []string{"v1", "v2"}},
+			`
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+type Server struct{}
+
+func(s *Server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: newString("123")}}, nil
+}
+
+func(s *Server) Logout(sess *Session) (*LogoutOuts, error) {
+	return nil, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	for _, v := range []string{"v1", "v2"} {
+		res, err := http.Post(s.URL+"/"+v+"/http", "application/json", newMessageReader("loginWithCredentials", &Credentials{}))
+		if err != nil {
+			log.Fatal(err)
+		}
+		var rm message
+		err = json.NewDecoder(res.Body).Decode(&rm)
+		res.Body.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if res.StatusCode != 200 || rm.Msg != "session" {
+			log.Fatalf("%v: status code was %v, response message %v", v, res.StatusCode, rm.Msg)
+		}
+
+		res, err = http.Get(s.URL+"/"+v+"/spec.json")
+		if err != nil {
+			log.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != 200 {
+			log.Fatalf("%v: spec status code was %v", v, res.StatusCode)
+		}
 
-	if res.StatusCode != 200 {
-		log.Fatal("status code not 200, but: %v", res.StatusCode)
-	}
-	
-	if res.Header.Get("Content-Type") != "text/html" {
-		log.Fatal("content type not text/html, but: %v", res.Header.Get("Content-Type"))
+		url := strings.Replace(s.URL, "http://", "ws://", 1)
+		conn, _, err := websocket.DefaultDialer.Dial(url+"/"+v+"/websocket", nil)
+		if err != nil {
+			log.Fatalf("%v: %v", v, err)
+		}
+		conn.Close()
 	}
 
-	if len(raw) < 400 {
-		log.Fatalf("Response content suspiciously short: %v", raw)
+	// other versions are not served
+	res, err := http.Post(s.URL+"/v3/http", "application/json", newMessageReader("loginWithCredentials", &Credentials{}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		log.Fatalf("v3: status code was %v", res.StatusCode)
 	}
 }
 			`,
@@ -895,16 +5552,12 @@ func main() {
 		if err != nil {
 			t.Fatal(ts.Name, err)
 		}
-		src, err := ServerSrc(spec)
+		src, err := ServerPackageSrcWithOptions(spec, "main", ts.Options)
 		if err != nil {
 			t.Fatal(ts.Name, err)
 		}
 
-		w := &bytes.Buffer{}
-		fmt.Fprintf(w, `%s`, ts.Code)
-		fmt.Fprintf(w, `%s`, src)
-
-		out, err := compileAndRun(w.Bytes())
+		out, err := compileAndRun([]byte(ts.Code), src)
 		if err != nil {
 			t.Fatal(ts.Name, err)
 		}
@@ -915,8 +5568,467 @@ func main() {
 	}
 }
 
-// compiles the given code, runs it and returns the response
-func compileAndRun(code []byte) (string, error) {
+func TestServerPackageSrcFormatter(t *testing.T) {
+	spec, err := jsonmsg.Parse([]byte(fixture.TestSchemaSimpleLoginHTTPandWebsocket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := ServerPackageSrc(spec, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unformatted sources are valid Go and format to the default output
+	src, err := ServerPackageSrcWithOptions(spec, "main", Options{Formatter: NoFormat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(src, formatted) {
+		t.Fatal("sources should not be formatted")
+	}
+	_, err = parser.ParseFile(token.NewFileSet(), "main.go", src, 0)
+	if err != nil {
+		t.Fatalf("unformatted sources do not parse: %v", err)
+	}
+	src, err = format.Source(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, formatted) {
+		t.Fatal("formatted sources differ from the default output")
+	}
+
+	// custom formatter
+	src, err = ServerPackageSrcWithOptions(spec, "main", Options{Formatter: func(src []byte) ([]byte, error) {
+		return append([]byte("// formatted\n"), src...), nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(src, []byte("// formatted\n")) {
+		t.Fatal("custom formatter was not used")
+	}
+}
+
+func TestServerPackageSrcTransform(t *testing.T) {
+	spec, err := jsonmsg.Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := ServerPackageSrcWithOptions(spec, "main", Options{Transform: func(fset *token.FileSet, f *ast.File) error {
+		f.Doc = &ast.CommentGroup{List: []*ast.Comment{{Slash: f.Package - 1, Text: "// Package main serves the login API."}}}
+		// comments are ordered by position, the doc follows the generated stamp
+		f.Comments = append(f.Comments[:1], append([]*ast.CommentGroup{f.Doc}, f.Comments[1:]...)...)
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(src, []byte("// Package main serves the login API.\npackage main\n")) {
+		t.Fatalf("package comment is missing:\n%s", src)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "main.go", src, 0); err != nil {
+		t.Fatalf("transformed sources do not parse: %v", err)
+	}
+
+	// transform errors abort generation
+	_, err = ServerPackageSrcWithOptions(spec, "main", Options{Transform: func(fset *token.FileSet, f *ast.File) error {
+		return errors.New("transform failed")
+	}})
+	if err == nil || err.Error() != "transform failed" {
+		t.Fatalf("error was %v", err)
+	}
+}
+
+func TestGoNames(t *testing.T) {
+	spec, err := jsonmsg.Parse([]byte(fixture.TestSchemaGoNames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := ServerSrc(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expr := range []string{
+		`Slug\s+\*string\s+` + "`" + `json:"url_slug,omitempty"` + "`",
+		`UserID\s+\*string\s+` + "`" + `json:"id,omitempty"` + "`",
+		`Name\s+\*string\s+` + "`" + `json:"name,omitempty"` + "`",
+	} {
+		if !regexp.MustCompile(expr).Match(src) {
+			t.Fatalf("field %v is missing:\n%s", expr, src)
+		}
+	}
+	if bytes.Contains(src, []byte("UrlSlug")) || bytes.Contains(src, []byte("t.ID")) {
+		t.Fatalf("default field names are still used:\n%s", src)
+	}
+
+	for _, c := range []struct {
+		name     string
+		expected string
+	}{
+		{"slug", `golang: x-go-name "slug" of #/definitions/user/properties/url_slug is not an exported Go identifier`},
+		{"Url-Slug", `golang: x-go-name "Url-Slug" of #/definitions/user/properties/url_slug is not an exported Go identifier`},
+		{"Name", `golang: x-go-name "Name" of #/definitions/user/properties/url_slug collides with another field of User`},
+	} {
+		raw := strings.Replace(fixture.TestSchemaGoNames, `"x-go-name": "Slug"`, `"x-go-name": "`+c.name+`"`, 1)
+		spec, err := jsonmsg.Parse([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ServerSrc(spec)
+		if err == nil || err.Error() != c.expected {
+			t.Fatalf("error was %v, expected %v", err, c.expected)
+		}
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	spec, err := jsonmsg.Parse([]byte(fixture.TestSchemaDeprecated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := ServerSrcWithOptions(spec, Options{Client: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"\t// Deprecated: the findUserByName message is deprecated.\n\tFindUserByName(*NameQuery) (*FindUserByNameOuts, error)\n",
+		"// Deprecated: the findUserByName message is deprecated.\nfunc (c *Client) FindUserByName(",
+		"// Deprecated: nameQuery is deprecated.\ntype NameQuery struct",
+	} {
+		if !bytes.Contains(src, []byte(expected)) {
+			t.Fatalf("missing %q in:\n%s", expected, src)
+		}
+	}
+	if n := bytes.Count(src, []byte("// Deprecated:")); n != 3 {
+		t.Fatalf("expected 3 deprecation comments, found %v", n)
+	}
+}
+
+func TestDefaultConstructors(t *testing.T) {
+	spec, err := jsonmsg.Parse([]byte(fixture.TestSchemaDefaults))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := ServerSrc(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expr := range []string{
+		`func NewQuery\(term string\) \*Query {\s+return &Query{\s+Term:\s+&term,\s+Exact:\s+newBool\(true\),\s+Limit:\s+newInt64\(10\),\s+Order:\s+newString\("relevance"\),\s+}`,
+		`func NewPage\(\) \*Page {\s+return &Page{\s+Size: newFloat64\(2.5\),\s+}`,
+	} {
+		if !regexp.MustCompile(expr).Match(src) {
+			t.Fatalf("constructor %v is missing:\n%s", expr, src)
+		}
+	}
+
+	raw := strings.Replace(fixture.TestSchemaDefaults, `"default": 10`, `"default": "ten"`, 1)
+	spec, err = jsonmsg.Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ServerSrc(spec)
+	expected := `golang: default "ten" of #/definitions/query/properties/limit does not match type integer`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("error was %v, expected %v", err, expected)
+	}
+}
+
+func TestServerSrcParallel(t *testing.T) {
+	for _, raw := range []string{
+		fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+		fixture.TestSchemaAlternativeIns,
+		fixture.TestSchemaDefaults,
+		largeSpec(100),
+	} {
+		spec, err := jsonmsg.Parse([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, o := range []Options{{}, {Client: true, Sessions: true, Metrics: true, StreamArrays: true, FastJSON: true}} {
+			sequential, err := serverSrc(spec, o, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parallel, err := serverSrc(spec, o, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(sequential, parallel) {
+				t.Fatalf("parallel sources differ from sequential sources with %+v", o)
+			}
+		}
+	}
+}
+
+func TestGenerateSectionsPanic(t *testing.T) {
+	sections := []func() ([]byte, error){
+		func() ([]byte, error) { return []byte("ok"), nil },
+		func() ([]byte, error) { panic("unresolved ref") },
+	}
+	for _, parallel := range []bool{false, true} {
+		_, err := generateSections(sections, parallel)
+		expected := "golang: section 1: unresolved ref"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("parallel=%v: error was %v, expected %v", parallel, err, expected)
+		}
+	}
+}
+
+// Benchmarks generating the sources of a large spec sequentially and in parallel
+func BenchmarkServerSrc(b *testing.B) {
+	spec, err := jsonmsg.Parse([]byte(largeSpec(300)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, parallel := range []bool{false, true} {
+		b.Run(fmt.Sprintf("parallel=%v", parallel), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				_, err := serverSrc(spec, Options{Client: true}, parallel)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// returns a synthetic spec with n messages, each with its own in and out definition
+func largeSpec(n int) string {
+	msgs := make([]string, n)
+	defs := make([]string, 0, 2*n)
+	for i := 0; i < n; i++ {
+		msgs[i] = fmt.Sprintf(`"message%v": {"in": "#/definitions/query%v", "outs": ["#/definitions/result%v"]}`, i, i, i)
+		defs = append(defs,
+			fmt.Sprintf(`"query%v": {"type": "object", "properties": {"id": {"type": "string"}, "limit": {"type": "integer", "minimum": 1}}, "required": ["id"]}`, i),
+			fmt.Sprintf(`"result%v": {"type": "object", "properties": {"id": {"type": "string"}, "names": {"type": "array", "items": {"type": "string"}}}}`, i),
+		)
+	}
+	return fmt.Sprintf(`{"endpoints": {"http": "http://api.specc.io/v1"}, "messages": {%v}, "definitions": {%v}}`,
+		strings.Join(msgs, ", "), strings.Join(defs, ", "))
+}
+
+// Generated test scaffolds compile next to the server and call each API method
+func TestServerTestPackageSrc(t *testing.T) {
+	table := []struct {
+		name    string
+		fixture string
+		options Options
+		code    string
+		out     string
+	}{
+		{
+			"implementation under test",
+			fixture.TestSchemaSimpleLoginHTTPandWebsocket,
+			Options{},
+			`package main
+
+func main() {}
+
+type server struct{}
+
+func (s *server) LoginWithCredentials(c *Credentials) (*LoginWithCredentialsOuts, error) {
+	return &LoginWithCredentialsOuts{Session: &Session{ID: c.Name}}, nil
+}
+
+func (s *server) Logout(*Session) (*LogoutOuts, error) {
+	return &LogoutOuts{Message: &Message{Message: newString("bye")}}, nil
+}
+
+func init() {
+	testAPI = &server{}
+}
+`,
+			"--- PASS: TestLoginWithCredentials/sample",
+		},
+		{
+			"skipped without implementation",
+			fixture.TestSchemaEmptyMessages,
+			Options{Sessions: true},
+			`package main
+
+func main() {}
+`,
+			"--- SKIP: TestSubscribeOutsOnly",
+		},
+	}
+
+	for _, ts := range table {
+		spec, err := jsonmsg.Parse([]byte(ts.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		src, err := ServerPackageSrcWithOptions(spec, "main", ts.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test, err := ServerTestPackageSrcWithOptions(spec, "main", ts.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, m := range spec.Messages {
+			if !bytes.Contains(test, []byte("testAPI."+m.Name+"(")) {
+				t.Fatalf("%v: scaffold does not call %v:\n%s", ts.name, m.Name, test)
+			}
+		}
+
+		out, err := compileAndTest([]byte(ts.code), src, test)
+		if err != nil {
+			t.Fatalf("%v: %v", ts.name, err)
+		}
+		if !strings.Contains(out, ts.out) {
+			t.Fatalf("%v: expected %q in test output:\n%s", ts.name, ts.out, out)
+		}
+	}
+}
+
+// Benchmarks generating the handler of the same spec repeatedly, parsing the template
+// on every generation as before it was cached and parsing it once
+func BenchmarkHTTPHandlerTemplate(b *testing.B) {
+	spec, err := jsonmsg.Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cached=%v", cached), func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if !cached {
+					handlerTemplateOnce = sync.Once{}
+				}
+				_, err := generateHTTPHandler(spec, Options{})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Benchmarks marshaling a user with and without the reflection-free fast path.
+// Each generated package is benchmarked within its own process, which prints the result.
+func BenchmarkFastJSON(b *testing.B) {
+	spec, err := jsonmsg.Parse([]byte(fixture.TestSchemaUserProfile))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, o := range []Options{{}, {FastJSON: true}} {
+		src, err := ServerPackageSrcWithOptions(spec, "main", o)
+		if err != nil {
+			b.Fatal(err)
+		}
+		out, err := compileAndRun([]byte(fastJSONBenchmark), src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Logf("FastJSON %v: %s", o.FastJSON, out)
+	}
+}
+
+const fastJSONBenchmark = `
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"testing"
+)
+
+func main() {
+	score := 4.5
+	u := &User{
+		ID:      newString("1"),
+		Name:    newString("John Doe"),
+		Age:     newInt64(42),
+		Score:   &score,
+		Active:  newBool(true),
+		Tags:    []string{"admin", "beta"},
+		Address: &UserAddress{Street: newString("Main St."), Lat: &score},
+		Contacts: []Contact{{ID: newString("2"), Primary: newBool(true)}, {ID: newString("3")}},
+	}
+	r := testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			_, err := json.Marshal(u)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	})
+	fmt.Println(r.String(), r.MemString())
+}
+`
+
+// Benchmarks a large array response with and without streaming.
+// Each generated server is benchmarked within its own process, which prints the result.
+func BenchmarkArrayOuts(b *testing.B) {
+	spec, err := jsonmsg.Parse([]byte(fixture.TestSchemaArrayOuts))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, o := range []Options{{}, {StreamArrays: true}} {
+		src, err := ServerPackageSrcWithOptions(spec, "main", o)
+		if err != nil {
+			b.Fatal(err)
+		}
+		out, err := compileAndRun([]byte(arrayOutsBenchmark), src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Logf("StreamArrays %v: %s", o.StreamArrays, out)
+	}
+}
+
+const arrayOutsBenchmark = `
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"log"
+	"strconv"
+	"testing"
+)
+
+type Server struct{}
+
+func(s *Server) ListUsers(q *ListQuery) (*ListUsersOuts, error) {
+	users := make(Users, *q.Limit)
+	for i := range users {
+		users[i] = User{ID: newString(strconv.Itoa(i)), Name: newString("user")}
+	}
+	return &ListUsersOuts{Users: &users}, nil
+}
+
+func main() {
+	s := httptest.NewServer(NewAPIMux(&Server{}))
+	defer s.Close()
+
+	limit := int64(10000)
+	r := testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			res, err := http.Post(s.URL+"/v1/http", "application/json", newMessageReader("listUsers", &ListQuery{Limit: &limit}))
+			if err != nil {
+				log.Fatal(err)
+			}
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+	})
+	fmt.Println(r.String(), r.MemString())
+}
+`
+
+// compiles the given code along with the generated package src, runs it and returns the response
+func compileAndRun(code []byte, src []byte) (string, error) {
 	const name = "tmp"
 	os.RemoveAll(name)
 	err := os.Mkdir(name, 0700)
@@ -929,6 +6041,10 @@ func compileAndRun(code []byte) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	err = ioutil.WriteFile(name+"/api.gen.go", src, 0700)
+	if err != nil {
+		return "", err
+	}
 
 	// get deps
 	cmd := exec.Command("bash", "-c", "go get .")
@@ -957,3 +6073,46 @@ func compileAndRun(code []byte) (string, error) {
 	os.RemoveAll(name)
 	return string(out), nil
 }
+
+// compiles the given code and test scaffold along with the generated package src, runs the tests and returns their verbose output
+func compileAndTest(code []byte, src []byte, test []byte) (string, error) {
+	const name = "tmp"
+	os.RemoveAll(name)
+	err := os.Mkdir(name, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	// write src
+	err = ioutil.WriteFile(name+"/main.go", code, 0700)
+	if err != nil {
+		return "", err
+	}
+	err = ioutil.WriteFile(name+"/api.gen.go", src, 0700)
+	if err != nil {
+		return "", err
+	}
+	err = ioutil.WriteFile(name+"/api_test.go", test, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	// get deps
+	cmd := exec.Command("bash", "-c", "go get .")
+	cmd.Dir = name
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, out)
+	}
+
+	// compile and test
+	cmd = exec.Command("bash", "-c", "go test -v")
+	cmd.Dir = name
+	out, err = cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, out)
+	}
+
+	os.RemoveAll(name)
+	return string(out), nil
+}
@@ -8,6 +8,11 @@ Generators
 
 go: https://godoc.org/github.com/tfkhsr/jsonmsg/golang
 
+python: https://godoc.org/github.com/tfkhsr/jsonmsg/python
+
+Generators register themselves with RegisterGenerator and are run by name with Generate, e.g. by the jsonmsgc command.
+Third-party packages can register further generators the same way.
+
 
 Parse a spec:
 
@@ -71,6 +76,70 @@ Parse a spec:
 	// spc now contains:
 	// spc.Messages["findUser"]        : *Message{...}
 	// spc.Definitions["user"]         : *jsonschema.Schema{...}
+
+Specs sharing definitions reference them in other files, e.g. "$ref": "common.json#/definitions/error".
+ParseFile resolves these relative to the spec file and inlines the definitions:
+
+	spc, err := ParseFile("api/spec.json")
+
+Service specs can extend a base spec with shared endpoints and definitions, resolved by ParseFile relative to the spec file:
+
+	"extends": "base.json"
+
+Endpoints, definitions and messages of the base are merged under those of the spec,
+whose endpoints and definitions override the base. Redeclaring a message of the base is an error.
+
+Endpoints sharing a host can be given as host with a list of protocols, tls selects https and wss:
+
+	"endpoints": {
+	  "host": "jsonmsg.github.io/v1",
+	  "protocols": ["http", "websocket"],
+	  "tls": true
+	}
+
+Endpoints given as path without scheme and host, e.g. {"http": "/v1"}, are relative to the host serving the spec.
+
+Declaring the "groups" of a spec validates the group of every message against them, so mistyped groups fail to parse:
+
+	"groups": ["login", "logout"]
+
+Unknown top-level keys are ignored, ParseOptions.DisallowUnknownKeys rejects them, e.g. a misspelled "endpints":
+
+	spc, err := ParseWithOptions(spec, ParseOptions{DisallowUnknownKeys: true})
+
+Messages marked "form": true also accept multipart/form-data, e.g. for file uploads,
+binding form fields and files to the properties of their in schema.
+
+Messages marked "batch": true are answered with all outs returned at once instead of the first one.
+
+Annotations with "$comment" are ignored for code generation. JSONSpecWithOptions strips them from the served spec:
+
+	raw, err := spc.JSONSpecWithOptions(JSONSpecOptions{StripComments: true})
+
+Messages may declare static headers of their successful HTTP responses, e.g. for caching:
+
+	"findUser": {
+	  "in": "#/definitions/userQuery",
+	  "outs": ["#/definitions/user"],
+	  "headers": {"Cache-Control": "max-age=60"}
+	}
+
+The HTML spec lists the allowed values of enum properties, described by "x-enum-descriptions" in the same order:
+
+	"role": {
+	  "type": "string",
+	  "enum": ["admin", "member"],
+	  "x-enum-descriptions": ["Manages all users", "Reads and writes own data"]
+	}
+
+Specs can also be built programmatically with a SpecBuilder, resolving pointers like Parse:
+
+	spc, err := jsonmsg.NewSpec().
+		AddEndpoint("http", "https://api.example.com/v1").
+		AddDefinition("userQuery", json.RawMessage(`{"type": "object", "properties": {"id": {"type": "string"}}}`)).
+		AddDefinition("user", userSchema).
+		AddMessage("findUser", "#/definitions/userQuery", []string{"#/definitions/user"}, "user").
+		Build()
 */
 package jsonmsg
 
@@ -78,11 +147,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"text/template"
+	"unicode"
 
 	"github.com/tfkhsr/jsonschema"
 )
@@ -93,221 +164,2041 @@ type Spec struct {
 	// General title of API
 	Title string
 
-	// Further information
-	Description string
+	// Further information
+	Description string
+
+	// Map of protocols to URLs (urlString embeds url.URL for unmarshaling),
+	// given in spec either as such a map or as a host with a list of protocols
+	Endpoints endpointURLs
+
+	// Map of message names to Messages
+	Messages map[string]*Message
+
+	// Map of groups of message names to Messages
+	GroupedMessages map[string]map[string]*Message
+
+	// Optional: declared group names, the group of every message must be one of them
+	Groups []string
+
+	// Optional: JSON Pointer to the schema of error payloads
+	ErrorSchema string
+
+	// Pointer to parsed error schema
+	ErrorDefinition *jsonschema.Schema `json:"-"`
+
+	// JSON Schema definitions for data definition
+	Definitions jsonschema.Index `json:"-"`
+
+	// Raw spec
+	Raw []byte
+
+	// Pointers of definitions and properties allowing null with a type array, e.g. ["string", "null"]
+	Nullable map[string]bool `json:"-"`
+
+	// Tuple definitions by pointer, whose items are parsed as properties named by position
+	Tuples map[string]*Tuple `json:"-"`
+
+	// Examples of definitions by definition pointer, as given by "examples" in spec
+	Examples map[string][]json.RawMessage `json:"-"`
+
+	// Pointers of properties marked "readOnly", which are never accepted as input
+	ReadOnly map[string]bool `json:"-"`
+
+	// Pointers of properties marked "writeOnly", which are never sent as output, e.g. passwords
+	WriteOnly map[string]bool `json:"-"`
+
+	// Go field names of properties overridden with "x-go-name" by property pointer
+	GoNames map[string]string `json:"-"`
+
+	// Pointers of definitions marked "deprecated"
+	Deprecated map[string]bool `json:"-"`
+
+	// Allowed values of enum properties by property pointer, described by "x-enum-descriptions"
+	EnumValues map[string][]EnumValue `json:"-"`
+
+	// Default values of properties by property pointer, as given by "default" in spec
+	Defaults map[string]json.RawMessage `json:"-"`
+
+	// Map of message-local pointers to their hoisted definition pointers
+	aliases map[string]string
+}
+
+// Map of protocols to URLs, unmarshaled from either form of endpoints in spec
+type endpointURLs map[string]*urlString
+
+// Unmarshals endpoints given as map of protocols to URLs, e.g. {"http": "https://host/v1"},
+// or as host with protocols, e.g. {"host": "host/v1", "protocols": ["http"], "tls": true}
+func (e *endpointURLs) UnmarshalJSON(b []byte) error {
+	var keys map[string]json.RawMessage
+	err := json.Unmarshal(b, &keys)
+	if err != nil {
+		return err
+	}
+	if _, ok := keys["host"]; !ok {
+		return json.Unmarshal(b, (*map[string]*urlString)(e))
+	}
+
+	var hp struct {
+		Host      string
+		Protocols []string
+		TLS       bool
+	}
+	err = json.Unmarshal(b, &hp)
+	if err != nil {
+		return err
+	}
+	if hp.Host == "" {
+		return fmt.Errorf("jsonmsg: endpoints are missing a host")
+	}
+	if len(hp.Protocols) == 0 {
+		return fmt.Errorf("jsonmsg: endpoints of host %v list no protocols", hp.Host)
+	}
+
+	*e = make(endpointURLs, len(hp.Protocols))
+	for _, p := range hp.Protocols {
+		schemes, ok := endpointSchemes[p]
+		if !ok {
+			return fmt.Errorf("jsonmsg: endpoint protocol %q is unknown", p)
+		}
+		scheme := schemes[0]
+		if hp.TLS {
+			scheme = schemes[1]
+		}
+		raw := scheme + "://" + hp.Host
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		(*e)[p] = &urlString{URL: *u, raw: raw}
+	}
+	return nil
+}
+
+type urlString struct {
+	url.URL
+
+	// URL as authored in spec, without the appended protocol path
+	raw string
+}
+
+func (s *urlString) UnmarshalJSON(b []byte) error {
+	us := string(b)
+	u, err := url.Parse(us[1 : len(us)-1])
+	if err != nil {
+		return err
+	}
+	*s = urlString{URL: *u, raw: us[1 : len(us)-1]}
+	return nil
+}
+
+// Reports whether the URL is a path relative to the serving host, e.g. /v1
+func (s *urlString) Relative() bool {
+	return s.Scheme == "" && s.Host == "" && strings.HasPrefix(s.Path, "/")
+}
+
+// Marshals the URL as authored in spec, so a parsed spec round-trips
+func (s *urlString) MarshalJSON() ([]byte, error) {
+	if s.raw == "" {
+		return json.Marshal(s.String())
+	}
+	return json.Marshal(s.raw)
+}
+
+// A Message holds details about name, in- and out parameters
+type Message struct {
+	// Short description
+	Title string
+
+	// Detailed description
+	Description string
+
+	// Literal message name as defined in spec
+	Msg string
+
+	// Camel-Cased name
+	Name string
+
+	// JSON Pointer to input schema
+	In string
+
+	// JSON Pointers to possible output schemas
+	Outs []string
+
+	// Optional descriptions of output schemas, indexed like Outs
+	OutDescriptions []string
+
+	// Pointer to parsed input schema
+	InSchema *jsonschema.Schema
+
+	// Optional: JSON Pointers to alternative input schemas, given as an array for in.
+	// Data must match one of them (oneOf), In and InSchema are empty then.
+	Ins []string
+
+	// Pointers to parsed alternative input schemas
+	InSchemas []*jsonschema.Schema
+
+	// Pointers to parsed output schemas
+	OutSchemas []*jsonschema.Schema
+
+	// Pointer to parent spec
+	Spec *Spec
+
+	// Optional: Group name associating message to spec.GroupedMessages
+	Group string
+
+	// Optional: marks a message without in and outs as intended, see ParseOptions.Strict
+	Empty bool
+
+	// Optional: example data of the message
+	Examples []json.RawMessage
+
+	// Optional: marks a message as deprecated, clients should migrate to other messages
+	Deprecated bool
+
+	// Optional: static HTTP headers of successful responses to the message, e.g. {"Cache-Control": "max-age=60"}
+	Headers map[string]string
+
+	// Optional: the message is accepted as multipart/form-data over HTTP besides JSON,
+	// binding form fields and files to the properties of its in schema
+	Form bool
+
+	// Optional: the message is answered with all outs returned at once instead of the first,
+	// over HTTP as a JSON array of out messages and over websocket with a frame each
+	Batch bool
+}
+
+// Unmarshals a message, accepting an inline schema object or an array of alternative pointers for in
+// and annotated outs of the form {"$ref": "...", "description": "..."}.
+// Inline schemas are resolved by Parse, which sets In to the message-local pointer.
+func (m *Message) UnmarshalJSON(b []byte) error {
+	type message Message
+	var raw struct {
+		message
+		In   json.RawMessage
+		Outs []json.RawMessage
+	}
+	err := json.Unmarshal(b, &raw)
+	if err != nil {
+		return err
+	}
+	*m = Message(raw.message)
+
+	for _, o := range raw.Outs {
+		var out struct {
+			Ref         string `json:"$ref"`
+			Description string
+		}
+		o = bytes.TrimSpace(o)
+		if len(o) > 0 && o[0] == '{' {
+			err = json.Unmarshal(o, &out)
+		} else {
+			err = json.Unmarshal(o, &out.Ref)
+		}
+		if err != nil {
+			return err
+		}
+		m.Outs = append(m.Outs, out.Ref)
+		m.OutDescriptions = append(m.OutDescriptions, out.Description)
+	}
+
+	in := bytes.TrimSpace(raw.In)
+	if len(in) > 0 && in[0] == '"' {
+		return json.Unmarshal(in, &m.In)
+	}
+	if len(in) > 0 && in[0] == '[' {
+		err = json.Unmarshal(in, &m.Ins)
+		if err != nil {
+			return err
+		}
+		// a single alternative is a plain in
+		if len(m.Ins) == 1 {
+			m.In, m.Ins = m.Ins[0], nil
+		}
+	}
+	return nil
+}
+
+// ParseOptions configure parsing of specs
+type ParseOptions struct {
+	// Reject messages declaring neither in nor outs unless marked with "empty": true
+	Strict bool
+
+	// Reject unknown top-level keys, e.g. a misspelled "endpints"
+	DisallowUnknownKeys bool
+}
+
+// Parses a raw schema into a Spec
+func Parse(b []byte) (*Spec, error) {
+	return ParseWithOptions(b, ParseOptions{})
+}
+
+// Parses a raw schema into a Spec with ParseOptions
+func ParseWithOptions(b []byte, o ParseOptions) (*Spec, error) {
+	b = cleanSpec(b)
+
+	if o.DisallowUnknownKeys {
+		err := checkTopLevelKeys(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var spec Spec
+	err := json.Unmarshal(b, &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// base specs are resolved relative to the spec file
+	var ext struct {
+		Extends string
+	}
+	if json.Unmarshal(b, &ext) == nil && ext.Extends != "" {
+		return nil, fmt.Errorf("jsonmsg: spec extends %v, which only ParseFile resolves", ext.Extends)
+	}
+
+	// raw
+	spec.Raw = b
+
+	// endpoints
+	for k, _ := range spec.Endpoints {
+		err = validateEndpoint(k, spec.Endpoints[k])
+		if err != nil {
+			return nil, err
+		}
+		spec.Endpoints[k].Path += "/" + k
+	}
+
+	// definitions, including inline message schemas
+	defs, aliases, err := hoistMessageSchemas(b)
+	if err != nil {
+		return nil, err
+	}
+	spec.Examples, err = definitionExamples(defs)
+	if err != nil {
+		return nil, err
+	}
+	spec.ReadOnly, spec.WriteOnly, err = accessModifiers(defs)
+	if err != nil {
+		return nil, err
+	}
+	spec.GoNames, err = goNameOverrides(defs)
+	if err != nil {
+		return nil, err
+	}
+	spec.Deprecated, err = deprecatedDefinitions(defs)
+	if err != nil {
+		return nil, err
+	}
+	spec.EnumValues, err = enumValues(defs)
+	if err != nil {
+		return nil, err
+	}
+	spec.Defaults, err = propertyDefaults(defs)
+	if err != nil {
+		return nil, err
+	}
+	defs, spec.Tuples, err = normalizeTuples(defs)
+	if err != nil {
+		return nil, err
+	}
+	defs, spec.Nullable, err = normalizeNullableTypes(defs)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := jsonschema.Parse(defs)
+	if err != nil {
+		return nil, err
+	}
+	spec.Definitions = *idx
+	spec.aliases = aliases
+
+	// error schema
+	spec.ErrorDefinition, err = spec.resolvePointerToSchema(spec.ErrorSchema)
+	if err != nil {
+		return nil, fmt.Errorf("jsonmsg: errorSchema: %v does not exist", spec.ErrorSchema)
+	}
+
+	// messages
+	spec.GroupedMessages = make(map[string]map[string]*Message)
+
+	for k, _ := range spec.Messages {
+		spec.Messages[k].Msg = k
+		spec.Messages[k].Name = goIdentifier(GoName(k))
+		spec.Messages[k].Spec = &spec
+
+		if _, ok := aliases["#/messages/"+k+"/in"]; ok {
+			spec.Messages[k].In = "#/messages/" + k + "/in"
+		}
+
+		InSchema, err := spec.resolvePointerToSchema(spec.Messages[k].In)
+		if err != nil {
+			return nil, fmt.Errorf("jsonmsg: message %q in: %v does not exist", k, spec.Messages[k].In)
+		}
+		spec.Messages[k].InSchema = InSchema
+
+		for i, in := range spec.Messages[k].Ins {
+			inSchema, err := spec.resolvePointerToSchema(in)
+			if err != nil || inSchema == nil {
+				return nil, fmt.Errorf("jsonmsg: message %q in[%d]: %v does not exist", k, i, in)
+			}
+			for j, o := range spec.Messages[k].InSchemas {
+				if o == inSchema {
+					return nil, fmt.Errorf("jsonmsg: message %q in[%d]: %v is already listed as in[%d]", k, i, in, j)
+				}
+				if o.Name == inSchema.Name {
+					return nil, fmt.Errorf("jsonmsg: message %q in[%d]: %v is named %v, which collides with in[%d]", k, i, in, inSchema.Name, j)
+				}
+			}
+			spec.Messages[k].InSchemas = append(spec.Messages[k].InSchemas, inSchema)
+		}
+
+		spec.Messages[k].OutSchemas = make([]*jsonschema.Schema, 0)
+		for i, _ := range spec.Messages[k].Outs {
+			outSchema, err := spec.resolvePointerToSchema(spec.Messages[k].Outs[i])
+			if err != nil {
+				return nil, fmt.Errorf("jsonmsg: message %q outs[%d]: %v does not exist", k, i, spec.Messages[k].Outs[i])
+			}
+			for j, o := range spec.Messages[k].OutSchemas {
+				if o == outSchema {
+					return nil, fmt.Errorf("jsonmsg: message %q outs[%d]: %v is already listed as outs[%d]", k, i, spec.Messages[k].Outs[i], j)
+				}
+				if o.Name == outSchema.Name {
+					return nil, fmt.Errorf("jsonmsg: message %q outs[%d]: %v is named %v, which collides with outs[%d]", k, i, spec.Messages[k].Outs[i], outSchema.Name, j)
+				}
+			}
+			spec.Messages[k].OutSchemas = append(spec.Messages[k].OutSchemas, outSchema)
+		}
+
+		if spec.Messages[k].Form && (InSchema == nil || InSchema.Type != "object") {
+			return nil, fmt.Errorf("jsonmsg: message %q is a form, but has no object in schema to bind the form to", k)
+		}
+
+		err = validateHeaders(spec.Messages[k].Headers)
+		if err != nil {
+			return nil, fmt.Errorf("jsonmsg: message %q headers: %v", k, err)
+		}
+
+		if _, ok := spec.GroupedMessages[spec.Messages[k].Group]; !ok {
+			spec.GroupedMessages[spec.Messages[k].Group] = make(map[string]*Message)
+		}
+		spec.GroupedMessages[spec.Messages[k].Group][k] = spec.Messages[k]
+	}
+
+	// mistyped groups
+	if spec.Groups != nil {
+		var keys []string
+		for k, _ := range spec.Messages {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if g := spec.Messages[k].Group; g != "" && !stringsContain(spec.Groups, g) {
+				return nil, fmt.Errorf("jsonmsg: message %q has group %q, which is not declared in groups %v", k, g, strings.Join(spec.Groups, ", "))
+			}
+		}
+	}
+
+	// accidentally empty messages
+	if o.Strict {
+		var keys []string
+		for k, _ := range spec.Messages {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			m := spec.Messages[k]
+			if m.In == "" && len(m.Ins) == 0 && len(m.Outs) == 0 && !m.Empty {
+				return nil, fmt.Errorf("jsonmsg: message %q declares neither in nor outs, mark it with \"empty\": true if intended", k)
+			}
+		}
+	}
+
+	return &spec, nil
+}
+
+// Returns an HTML website version of the spec that must be served on {{ BaseURL }}/spec by servers
+func (s *Spec) HTTPSpec() ([]byte, error) {
+	tmpl, err := parsedSpecTemplate()
+	if err != nil {
+		return nil, err
+	}
+	w := &bytes.Buffer{}
+	err = tmpl.Execute(w, s)
+	if err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// Options for JSONSpecWithOptions
+type JSONSpecOptions struct {
+	// Removes "$comment" annotations, e.g. internal notes, from the spec and its schemas
+	StripComments bool
+}
+
+// Returns an indented, marshalled version of the spec that must be served on {{ BaseURL }}/spec.json by servers
+func (s *Spec) JSONSpec() ([]byte, error) {
+	return s.JSONSpecWithOptions(JSONSpecOptions{})
+}
+
+// Returns an indented, marshalled version of the spec adjusted by JSONSpecOptions
+func (s *Spec) JSONSpecWithOptions(o JSONSpecOptions) ([]byte, error) {
+	var v interface{}
+	err := json.Unmarshal(s.Raw, &v)
+	if err != nil {
+		return nil, err
+	}
+	if o.StripComments {
+		stripComments(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// Keywords whose object holds names, e.g. property names, which are never annotations
+var namingKeywords = []string{"properties", "patternProperties", "definitions", "$defs", "messages", "endpoints"}
+
+// Keywords holding instance data, which is kept as is
+var instanceKeywords = []string{"examples", "default", "const", "enum"}
+
+// removes "$comment" keys of objects in v recursively
+func stripComments(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		delete(v, "$comment")
+		for k, c := range v {
+			if stringsContain(instanceKeywords, k) {
+				continue
+			}
+			if names, ok := c.(map[string]interface{}); ok && stringsContain(namingKeywords, k) {
+				for _, n := range names {
+					stripComments(n)
+				}
+				continue
+			}
+			stripComments(c)
+		}
+	case []interface{}:
+		for _, c := range v {
+			stripComments(c)
+		}
+	}
+}
+
+// Returns a single JSON Schema document validating raw message envelopes.
+// All definitions are placed under $defs and each message is an alternative of the top-level oneOf.
+func (s *Spec) JSONSchema() ([]byte, error) {
+	defs, err := s.rawDefinitions()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for k, _ := range s.Messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	envelopes := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		m := s.Messages[k]
+		envelope := envelopeSchema(m.Msg, s.inPointers(m)...)
+		envelope["title"] = m.Msg
+		envelope["additionalProperties"] = false
+		if m.Description != "" {
+			envelope["description"] = m.Description
+		}
+		envelopes = append(envelopes, envelope)
+	}
+
+	doc := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"oneOf":   envelopes,
+		"$defs":   defs,
+	}
+	if s.Title != "" {
+		doc["title"] = s.Title
+	}
+	if s.Description != "" {
+		doc["description"] = s.Description
+	}
+
+	return json.MarshalIndent(rewriteRefs(doc, "#/definitions/", "#/$defs/"), "", "  ")
+}
+
+// Returns an AsyncAPI document of the spec.
+// Each message becomes a channel with a publish operation for its in and a subscribe operation for its outs.
+// Definitions become schema components and endpoints become servers.
+func (s *Spec) AsyncAPI() ([]byte, error) {
+	defs, err := s.rawDefinitions()
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make(map[string]interface{})
+	for k, e := range s.Endpoints {
+		protocol := e.Scheme
+		if schemes, ok := endpointSchemes[k]; ok && e.Relative() {
+			protocol = schemes[0]
+		}
+		servers[k] = map[string]interface{}{
+			"url":      e.String(),
+			"protocol": protocol,
+		}
+	}
+
+	channels := make(map[string]interface{})
+	messages := make(map[string]interface{})
+	for k, m := range s.Messages {
+		in := map[string]interface{}{
+			"name":    m.Msg,
+			"payload": envelopeSchema(m.Msg, s.inPointers(m)...),
+		}
+		if m.Title != "" {
+			in["title"] = m.Title
+		}
+		if m.Description != "" {
+			in["description"] = m.Description
+		}
+		messages[k] = in
+
+		publish := map[string]interface{}{
+			"operationId": m.Msg,
+			"message":     map[string]interface{}{"$ref": "#/components/messages/" + k},
+		}
+		if m.Group != "" {
+			publish["tags"] = []interface{}{
+				map[string]interface{}{"name": m.Group},
+			}
+		}
+		channel := map[string]interface{}{
+			"publish": publish,
+		}
+
+		var outs []interface{}
+		for i, o := range m.OutSchemas {
+			name := k + "." + o.JSONName
+			msg := map[string]interface{}{
+				"name":    o.JSONName,
+				"payload": envelopeSchema(o.JSONName, s.resolveAlias(m.Outs[i])),
+			}
+			if d := m.OutDescription(i); d != "" {
+				msg["description"] = d
+			}
+			messages[name] = msg
+			outs = append(outs, map[string]interface{}{"$ref": "#/components/messages/" + name})
+		}
+		if len(outs) > 0 {
+			channel["subscribe"] = map[string]interface{}{
+				"operationId": m.Msg + "Outs",
+				"message":     map[string]interface{}{"oneOf": outs},
+			}
+		}
+
+		channels[k] = channel
+	}
+
+	info := map[string]interface{}{
+		"title":   s.Title,
+		"version": "1.0.0",
+	}
+	if s.Title == "" {
+		info["title"] = "API"
+	}
+	if s.Description != "" {
+		info["description"] = s.Description
+	}
+
+	doc := map[string]interface{}{
+		"asyncapi":           "2.6.0",
+		"info":               info,
+		"defaultContentType": "application/json",
+		"servers":            servers,
+		"channels":           channels,
+		"components": map[string]interface{}{
+			"schemas":  defs,
+			"messages": messages,
+		},
+	}
+
+	return json.MarshalIndent(rewriteRefs(doc, "#/definitions/", "#/components/schemas/"), "", "  ")
+}
+
+// Returns an OpenAPI document of the http endpoint of the spec.
+// Each message becomes a POST operation of the endpoint path with the fragment #{msg}, e.g. /v1/http#findUser,
+// as all messages share the path. The operationId is the message name and the tag its group,
+// so tools like Swagger UI group operations like the HTML spec. Definitions become schema components.
+func (s *Spec) OpenAPI() ([]byte, error) {
+	e, ok := s.Endpoints["http"]
+	if !ok {
+		return nil, fmt.Errorf("jsonmsg: OpenAPI requires an http endpoint")
+	}
+	defs, err := s.rawDefinitions()
+	if err != nil {
+		return nil, err
+	}
+
+	server := "/"
+	if !e.Relative() {
+		server = (&url.URL{Scheme: e.Scheme, Host: e.Host}).String()
+	}
+
+	// error messages answering failed messages
+	errorSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"msg":  map[string]interface{}{"const": "error"},
+			"data": map[string]interface{}{"type": "object", "properties": map[string]interface{}{"error": map[string]interface{}{"type": "string"}}},
+		},
+		"required": []string{"msg", "data"},
+	}
+	if s.ErrorDefinition != nil {
+		errorSchema = envelopeSchema(s.ErrorDefinition.JSONName, s.ErrorSchema)
+	}
+
+	paths := make(map[string]interface{})
+	groups := make(map[string]bool)
+	for _, m := range s.Messages {
+		op := map[string]interface{}{
+			"operationId": m.Name,
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": envelopeSchema(m.Msg, s.inPointers(m)...)},
+				},
+			},
+		}
+		if m.Title != "" {
+			op["summary"] = m.Title
+		}
+		if m.Description != "" {
+			op["description"] = m.Description
+		}
+		if m.Group != "" {
+			op["tags"] = []string{m.Group}
+			groups[m.Group] = true
+		}
+		if m.Deprecated {
+			op["deprecated"] = true
+		}
+
+		responses := make(map[string]interface{})
+		for _, c := range m.StatusCodes() {
+			res := map[string]interface{}{"description": c.Description}
+			schema := errorSchema
+			if c.Code == 200 {
+				schema = s.openAPIOuts(m)
+			}
+			if schema != nil {
+				res["content"] = map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schema},
+				}
+			}
+			responses[fmt.Sprint(c.Code)] = res
+		}
+		op["responses"] = responses
+
+		paths[e.EscapedPath()+"#"+m.Msg] = map[string]interface{}{"post": op}
+	}
+
+	// declared groups keep their order
+	var tags []interface{}
+	names := s.Groups
+	if len(names) == 0 {
+		for g := range groups {
+			names = append(names, g)
+		}
+		sort.Strings(names)
+	}
+	for _, g := range names {
+		tags = append(tags, map[string]interface{}{"name": g})
+	}
+
+	info := map[string]interface{}{
+		"title":   s.Title,
+		"version": "1.0.0",
+	}
+	if s.Title == "" {
+		info["title"] = "API"
+	}
+	if s.Description != "" {
+		info["description"] = s.Description
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.1.0",
+		"info":    info,
+		"servers": []interface{}{map[string]interface{}{"url": server}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": defs,
+		},
+	}
+	if len(tags) > 0 {
+		doc["tags"] = tags
+	}
+
+	return json.MarshalIndent(rewriteRefs(doc, "#/definitions/", "#/components/schemas/"), "", "  ")
+}
+
+// returns the schema of successful responses to a message: one of its out messages,
+// an array of them for batch messages or nil for messages without outs
+func (s *Spec) openAPIOuts(m *Message) map[string]interface{} {
+	var outs []interface{}
+	for i, o := range m.OutSchemas {
+		out := envelopeSchema(o.JSONName, s.resolveAlias(m.Outs[i]))
+		if d := m.OutDescription(i); d != "" {
+			out["description"] = d
+		}
+		outs = append(outs, out)
+	}
+	if len(outs) == 0 {
+		return nil
+	}
+	schema := map[string]interface{}{"oneOf": outs}
+	if len(outs) == 1 {
+		schema = outs[0].(map[string]interface{})
+	}
+	if m.Batch {
+		return map[string]interface{}{"type": "array", "items": schema}
+	}
+	return schema
+}
+
+// returns the decoded definitions of the raw spec including inline message schemas
+func (s *Spec) rawDefinitions() (map[string]interface{}, error) {
+	b, _, err := hoistMessageSchemas(s.Raw)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		Definitions map[string]interface{}
+	}
+	err = json.Unmarshal(b, &raw)
+	if err != nil {
+		return nil, err
+	}
+	if raw.Definitions == nil {
+		raw.Definitions = make(map[string]interface{})
+	}
+	return raw.Definitions, nil
+}
+
+// Returns the const values of all definitions and their nested properties and items by JSON Pointer,
+// e.g. #/definitions/circle/properties/kind
+func (s *Spec) Consts() (map[string]interface{}, error) {
+	return s.keywordValues("const")
+}
+
+// Returns the enum values of all definitions and their nested properties and items by JSON Pointer,
+// e.g. #/definitions/user/properties/roles/items
+func (s *Spec) Enums() (map[string][]interface{}, error) {
+	vs, err := s.keywordValues("enum")
+	if err != nil {
+		return nil, err
+	}
+	enums := make(map[string][]interface{})
+	for p, v := range vs {
+		if e, ok := v.([]interface{}); ok {
+			enums[p] = e
+		}
+	}
+	return enums, nil
+}
+
+// Returns the formats of all definitions and their nested properties and items by JSON Pointer,
+// e.g. "email" for #/definitions/user/properties/email
+func (s *Spec) Formats() (map[string]string, error) {
+	vs, err := s.keywordValues("format")
+	if err != nil {
+		return nil, err
+	}
+	formats := make(map[string]string)
+	for p, v := range vs {
+		f, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("jsonmsg: format of %v must be a string", p)
+		}
+		formats[p] = f
+	}
+	return formats, nil
+}
+
+// A conditional schema (if/then/else) of an object definition.
+// If matches if each of its properties is absent or has one of its values (const or enum),
+// and each of IfRequired is present. Then lists the properties required if If matches,
+// Else the properties required otherwise.
+type Conditional struct {
+	If         map[string][]interface{}
+	IfRequired []string
+	Then       []string
+	Else       []string
+}
+
+// Returns the conditional schemas of all top-level definitions by JSON Pointer, e.g. #/definitions/payment.
+// Only if schemas of properties with const or enum values and then and else schemas of required
+// properties are supported, other keywords result in an error.
+func (s *Spec) Conditionals() (map[string]*Conditional, error) {
+	defs, err := s.rawDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	conds := make(map[string]*Conditional)
+	for k, d := range defs {
+		m, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := m["if"]; !ok {
+			continue
+		}
+		p := "#/definitions/" + k
+		c, err := parseConditional(m, p)
+		if err != nil {
+			return nil, err
+		}
+		conds[p] = c
+	}
+	return conds, nil
+}
+
+// parses the if, then and else keywords of a decoded definition
+func parseConditional(m map[string]interface{}, p string) (*Conditional, error) {
+	c := &Conditional{If: make(map[string][]interface{})}
+
+	cond, ok := m["if"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("jsonmsg: if of %v must be an object", p)
+	}
+	for kw, v := range cond {
+		switch kw {
+		case "properties":
+			props, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("jsonmsg: if of %v must have properties of an object", p)
+			}
+			for prop, ps := range props {
+				vs, ok := conditionValues(ps)
+				if !ok {
+					return nil, fmt.Errorf("jsonmsg: if of %v must only constrain %v by const or enum", p, prop)
+				}
+				c.If[prop] = vs
+			}
+		case "required":
+			req, ok := stringList(v)
+			if !ok {
+				return nil, fmt.Errorf("jsonmsg: if of %v must have required of an array of strings", p)
+			}
+			c.IfRequired = req
+		case "title", "description", "$comment":
+		default:
+			return nil, fmt.Errorf("jsonmsg: if of %v does not support %v", p, kw)
+		}
+	}
+
+	for _, kw := range []string{"then", "else"} {
+		v, ok := m[kw]
+		if !ok {
+			continue
+		}
+		branch, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("jsonmsg: %v of %v must be an object", kw, p)
+		}
+		var req []string
+		for bk, bv := range branch {
+			switch bk {
+			case "required":
+				req, ok = stringList(bv)
+				if !ok {
+					return nil, fmt.Errorf("jsonmsg: %v of %v must have required of an array of strings", kw, p)
+				}
+			case "title", "description", "$comment":
+			default:
+				return nil, fmt.Errorf("jsonmsg: %v of %v does not support %v", kw, p, bk)
+			}
+		}
+		if kw == "then" {
+			c.Then = req
+		} else {
+			c.Else = req
+		}
+	}
+	return c, nil
+}
+
+// returns the values a decoded property schema of an if schema allows by const or enum,
+// besides which it may only have type and annotations
+func conditionValues(v interface{}) ([]interface{}, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	_, hasConst := m["const"]
+	_, hasEnum := m["enum"]
+	if hasConst && hasEnum {
+		return nil, false
+	}
+	var vs []interface{}
+	for kw, e := range m {
+		switch kw {
+		case "const":
+			vs = append(vs, e)
+		case "enum":
+			es, ok := e.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			vs = append(vs, es...)
+		case "type", "title", "description", "$comment":
+		default:
+			return nil, false
+		}
+	}
+	return vs, len(vs) > 0
+}
+
+// returns a decoded array of strings
+func stringList(v interface{}) ([]string, bool) {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	var l []string
+	for _, e := range vs {
+		s, ok := e.(string)
+		if !ok {
+			return nil, false
+		}
+		l = append(l, s)
+	}
+	return l, true
+}
+
+// Returns the minProperties of all definitions and their nested properties and items by JSON Pointer
+func (s *Spec) MinProperties() (map[string]int, error) {
+	return s.keywordCounts("minProperties")
+}
+
+// Returns the maxProperties of all definitions and their nested properties and items by JSON Pointer
+func (s *Spec) MaxProperties() (map[string]int, error) {
+	return s.keywordCounts("maxProperties")
+}
+
+// Returns the values of a keyword holding a count, which must be a non-negative integer
+func (s *Spec) keywordCounts(keyword string) (map[string]int, error) {
+	vs, err := s.keywordValues(keyword)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for p, v := range vs {
+		n, ok := v.(float64)
+		if !ok || n < 0 || n != float64(int(n)) {
+			return nil, fmt.Errorf("jsonmsg: %v of %v must be a non-negative integer", keyword, p)
+		}
+		counts[p] = int(n)
+	}
+	return counts, nil
+}
+
+// Returns the values of a keyword in all definitions and their nested properties and items by JSON Pointer
+func (s *Spec) keywordValues(keyword string) (map[string]interface{}, error) {
+	defs, err := s.rawDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	vs := make(map[string]interface{})
+	for k, d := range defs {
+		collectKeyword(d, "#/definitions/"+k, keyword, vs)
+	}
+	return vs, nil
+}
+
+// collects the values of a keyword in a decoded schema and its properties and items
+func collectKeyword(v interface{}, p string, keyword string, vs map[string]interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if c, ok := m[keyword]; ok {
+		vs[p] = c
+	}
+	if props, ok := m["properties"].(map[string]interface{}); ok {
+		for k, e := range props {
+			collectKeyword(e, p+"/properties/"+k, keyword, vs)
+		}
+	}
+	if items, ok := m["items"]; ok {
+		collectKeyword(items, p+"/items", keyword, vs)
+	}
+}
+
+// returns the schema of a message envelope with a constant msg and data referencing the given pointer,
+// or one of the given pointers for alternative in schemas
+func envelopeSchema(msg string, data ...string) map[string]interface{} {
+	props := map[string]interface{}{
+		"msg": map[string]interface{}{"const": msg},
+	}
+	required := []string{"msg"}
+	switch len(data) {
+	case 0:
+	case 1:
+		props["data"] = map[string]interface{}{"$ref": data[0]}
+		required = append(required, "data")
+	default:
+		var oneOf []interface{}
+		for _, d := range data {
+			oneOf = append(oneOf, map[string]interface{}{"$ref": d})
+		}
+		props["data"] = map[string]interface{}{"oneOf": oneOf}
+		required = append(required, "data")
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+}
+
+// returns the definition pointers of the in schema or the alternative in schemas of a message
+func (s *Spec) inPointers(m *Message) []string {
+	if m.In != "" {
+		return []string{s.resolveAlias(m.In)}
+	}
+	var ps []string
+	for _, in := range m.Ins {
+		ps = append(ps, s.resolveAlias(in))
+	}
+	return ps
+}
+
+// Creates sample out messages conforming to each out schema of the message
+func (m *Message) NewOutputInstances() ([]interface{}, error) {
+	var insts []interface{}
+	for _, o := range m.OutSchemas {
+		inst, err := m.NewInstanceFor(o)
+		if err != nil {
+			return nil, err
+		}
+		// outs may share the in schema, but are always named after their schema
+		inst.(map[string]interface{})["msg"] = o.JSONName
+		insts = append(insts, inst)
+	}
+	return insts, nil
+}
+
+// A StatusCode documents an HTTP status code a message can be answered with
+type StatusCode struct {
+	Code        int
+	Description string
+}
+
+// Returns the HTTP status codes generated servers answer the message with,
+// besides codes not specific to a message, e.g. 404 for unknown messages
+func (m *Message) StatusCodes() []StatusCode {
+	var codes []StatusCode
+	if len(m.OutSchemas) > 0 {
+		codes = append(codes, StatusCode{200, "Success with one of the outs"})
+	} else {
+		codes = append(codes, StatusCode{200, "Success without out message"})
+	}
+	if m.InSchema != nil || len(m.InSchemas) > 0 {
+		codes = append(codes, StatusCode{422, "Data is unparsable or invalid"})
+	}
+	if len(m.OutSchemas) > 0 {
+		codes = append(codes, StatusCode{500, "Handler failed, returned no out or an invalid out"})
+	} else {
+		codes = append(codes, StatusCode{500, "Handler failed"})
+	}
+	return codes
+}
+
+// Creates in messages from the examples of the message followed by the examples of its in schema
+func (m *Message) NewExampleInstances() []interface{} {
+	examples := m.Examples
+	for _, p := range m.Spec.inPointers(m) {
+		examples = append(examples[:len(examples):len(examples)], m.Spec.Examples[p]...)
+	}
+	var insts []interface{}
+	for _, e := range examples {
+		insts = append(insts, map[string]interface{}{"msg": m.Msg, "data": e})
+	}
+	return insts
+}
+
+// Message names reserved for built-in messages of generated servers
+var reservedMessages = []string{"fetchSpec"}
+
+// Checks the spec for messages which parse but clash in generated sources:
+// messages whose Camel-Cased names collide and messages using reserved names.
+// Returns an error per finding, sorted by message.
+func (s *Spec) Lint() []error {
+	var keys []string
+	for k, _ := range s.Messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	names := make(map[string]*Message)
+	for _, k := range keys {
+		m := s.Messages[k]
+		if o, ok := names[m.Name]; ok {
+			errs = append(errs, fmt.Errorf("jsonmsg: message %q (group %q) shadows message %q (group %q), both are named %v", m.Msg, m.Group, o.Msg, o.Group, m.Name))
+		} else {
+			names[m.Name] = m
+		}
+		if stringsContain(reservedMessages, m.Msg) {
+			errs = append(errs, fmt.Errorf("jsonmsg: message %q uses a reserved name", m.Msg))
+		}
+	}
+	return errs
+}
+
+// Returns the message with the literal msg name as defined in spec
+func (s *Spec) MessageByMsg(msg string) (*Message, bool) {
+	m, ok := s.Messages[msg]
+	return m, ok
+}
+
+// Returns the message with the Camel-Cased name
+func (s *Spec) MessageByName(name string) (*Message, bool) {
+	for _, m := range s.Messages {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// Returns the schema at a JSON Pointer, e.g. #/definitions/user or the message-local #/messages/findUser/in
+func (s *Spec) Definition(pointer string) (*jsonschema.Schema, bool) {
+	d, ok := s.Definitions[s.resolveAlias(pointer)]
+	return d, ok
+}
+
+// Returns the schema with the Camel-Cased go name, e.g. User or UserAddress for #/definitions/user/properties/address
+func (s *Spec) DefinitionByName(name string) (*jsonschema.Schema, bool) {
+	var keys []string
+	for k, _ := range s.Definitions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if s.Definitions[k].Name == name {
+			return s.Definitions[k], true
+		}
+	}
+	return nil, false
+}
+
+// Returns the description of the i-th out schema or an empty string
+func (m *Message) OutDescription(i int) string {
+	if i < 0 || i >= len(m.OutDescriptions) {
+		return ""
+	}
+	return m.OutDescriptions[i]
+}
+
+// Creates a new message instance conforming to the message schema,
+// the first alternative for messages with alternative in schemas
+func (m *Message) NewInstance() (interface{}, error) {
+	if m.InSchema == nil && len(m.InSchemas) > 0 {
+		return m.NewInstanceFor(m.InSchemas[0])
+	}
+	return m.NewInstanceFor(m.InSchema)
+}
+
+// Creates a new message instance with data conforming to the given schema.
+// For in schemas the msg is the message name, for other schemas (e.g. outs) it is the schema name.
+// A nil schema creates a message without data.
+func (m *Message) NewInstanceFor(schema *jsonschema.Schema) (interface{}, error) {
+	nm := make(map[string]interface{})
+	nm["msg"] = m.Msg
+	if schema == nil {
+		return nm, nil
+	}
+	if schema != m.InSchema && !m.isInAlternative(schema) {
+		nm["msg"] = schema.JSONName
+	}
+	data, err := schema.NewInstance(&m.Spec.Definitions)
+	if err != nil {
+		return nil, err
+	}
+	nm["data"], err = m.Spec.withDefaults(schema, data)
+	if err != nil {
+		return nil, err
+	}
+	return nm, nil
+}
+
+// replaces placeholder values of an instance of schema by the defaults of its properties, also when nested
+func (s *Spec) withDefaults(schema *jsonschema.Schema, v interface{}) (interface{}, error) {
+	if schema == nil || len(s.Defaults) == 0 {
+		return v, nil
+	}
+	if schema.Type == "ref" {
+		return s.withDefaults(s.Definitions[schema.Ref], v)
+	}
+
+	switch inst := v.(type) {
+	case map[string]interface{}:
+		for p, ps := range schema.Properties {
+			if _, ok := inst[p]; !ok {
+				continue
+			}
+			if d, ok := s.Defaults[schema.Pointer+"/properties/"+p]; ok {
+				var dv interface{}
+				err := json.Unmarshal(d, &dv)
+				if err != nil {
+					return nil, err
+				}
+				inst[p] = dv
+				continue
+			}
+			pv, err := s.withDefaults(ps, inst[p])
+			if err != nil {
+				return nil, err
+			}
+			inst[p] = pv
+		}
+	case []interface{}:
+		for i, e := range inst {
+			ev, err := s.withDefaults(schema.Items, e)
+			if err != nil {
+				return nil, err
+			}
+			inst[i] = ev
+		}
+	}
+	return v, nil
+}
+
+// reports whether schema is one of the alternative in schemas
+func (m *Message) isInAlternative(schema *jsonschema.Schema) bool {
+	for _, in := range m.InSchemas {
+		if in == schema {
+			return true
+		}
+	}
+	return false
+}
+
+// GoName creates a go friendly name from string parts, e.g. "find", "user", "id" becomes FindUserID.
+// Generators name messages and fields with it like Parse does.
+func GoName(parts ...string) string {
+	name := ""
+	re := regexp.MustCompile("{|}|-|_")
+	for _, p := range parts {
+		c := re.ReplaceAllString(p, "")
+		switch c {
+		case "id":
+			name += "ID"
+		case "url":
+			name += "URL"
+		case "api":
+			name += "API"
+		default:
+			name += strings.Title(c)
+		}
+	}
+	return name
+}
+
+// ensures a go friendly name is a valid exported identifier by dropping invalid characters
+// and prefixing names which do not start with a letter
+func goIdentifier(name string) string {
+	name = regexp.MustCompile(`[^\pL\pN]`).ReplaceAllString(name, "")
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// URL schemes allowed for endpoint protocols, without and with TLS
+var endpointSchemes = map[string][]string{
+	"http":      []string{"http", "https"},
+	"websocket": []string{"ws", "wss"},
+}
+
+// checks names and values of static response headers
+func validateHeaders(headers map[string]string) error {
+	var names []string
+	for n, _ := range headers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		if n == "" || strings.IndexFunc(n, func(r rune) bool {
+			return r <= ' ' || r >= 0x7f || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", r)
+		}) >= 0 {
+			return fmt.Errorf("%q is not a valid header name", n)
+		}
+		if strings.ContainsAny(headers[n], "\r\n") {
+			return fmt.Errorf("value of %v must not contain line breaks", n)
+		}
+	}
+	return nil
+}
+
+// checks that an endpoint URL has a host and a scheme matching its protocol,
+// unless it is a path relative to the serving host
+func validateEndpoint(protocol string, u *urlString) error {
+	if u == nil {
+		return fmt.Errorf("jsonmsg: endpoint %q has no URL", protocol)
+	}
+	if u.Relative() {
+		return nil
+	}
+	if schemes, ok := endpointSchemes[protocol]; ok && !stringsContain(schemes, u.Scheme) {
+		return fmt.Errorf("jsonmsg: endpoint %q (%v) must have scheme %v", protocol, u.String(), strings.Join(schemes, " or "))
+	}
+	if u.Host == "" {
+		return fmt.Errorf("jsonmsg: endpoint %q (%v) is missing a host", protocol, u.String())
+	}
+	return nil
+}
+
+// returns a schema or referenced schema
+func (s *Spec) resolvePointerToSchema(p string) (*jsonschema.Schema, error) {
+	if p == "" {
+		return nil, nil
+	}
+	sch, ok := s.Definitions[s.resolveAlias(p)]
+	if !ok {
+		return nil, fmt.Errorf("jsonmsg: %v does not exist in index", p)
+	}
+	return sch, nil
+}
+
+// returns the definition pointer for a message-local or inline pointer or the pointer itself
+func (s *Spec) resolveAlias(p string) string {
+	return resolveAliases(s.aliases, p)
+}
+
+// resolves a pointer through aliases until it no longer points into an aliased schema
+func resolveAliases(aliases map[string]string, p string) string {
+	for i := 0; i <= len(aliases); i++ {
+		resolved := p
+		for a, d := range aliases {
+			if p == a || strings.HasPrefix(p, a+"/") {
+				resolved = d + strings.TrimPrefix(p, a)
+				break
+			}
+		}
+		if resolved == p {
+			return p
+		}
+		p = resolved
+	}
+	return p
+}
+
+// Moves inline message in schemas and inline object properties into definitions, so they are indexed like any other definition.
+// Returns the rewritten spec and a map of message-local and inline pointers to definition pointers.
+func hoistMessageSchemas(b []byte) ([]byte, map[string]string, error) {
+	var doc map[string]json.RawMessage
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var msgs map[string]map[string]json.RawMessage
+	if raw, ok := doc["messages"]; ok {
+		err = json.Unmarshal(raw, &msgs)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	defs := make(map[string]interface{})
+	if raw, ok := doc["definitions"]; ok {
+		err = json.Unmarshal(raw, &defs)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	aliases := make(map[string]string)
+	for k, m := range msgs {
+		in := bytes.TrimSpace(m["in"])
+		if len(in) == 0 || in[0] != '{' {
+			continue
+		}
+		name := k + "In"
+		if _, ok := defs[name]; ok {
+			return nil, nil, fmt.Errorf("jsonmsg: inline in schema of message %q collides with definition %q", k, name)
+		}
+		var sch interface{}
+		err = json.Unmarshal(in, &sch)
+		if err != nil {
+			return nil, nil, err
+		}
+		defs[name] = sch
+		aliases["#/messages/"+k+"/in"] = "#/definitions/" + name
+	}
+
+	err = hoistObjectProperties(defs, aliases)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(aliases) == 0 {
+		return b, aliases, nil
+	}
+
+	// point references to message-local and inline schemas at their definitions
+	raw, err := json.Marshal(rewriteAliasRefs(defs, aliases))
+	if err != nil {
+		return nil, nil, err
+	}
+	doc["definitions"] = raw
+
+	h, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, aliases, nil
+}
+
+// Moves inline object properties of definitions into their own definitions named after definition and property,
+// e.g. #/definitions/user/properties/address becomes #/definitions/userAddress. Nested objects are hoisted recursively.
+func hoistObjectProperties(defs map[string]interface{}, aliases map[string]string) error {
+	var queue []string
+	for k, _ := range defs {
+		queue = append(queue, k)
+	}
+	sort.Strings(queue)
+
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
 
-	// Map of protocols to URLs (urlString embeds url.URL for unmarshaling)
-	Endpoints map[string]*urlString
+		d, ok := defs[k].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		props, ok := d["properties"].(map[string]interface{})
+		if !ok {
+			continue
+		}
 
-	// Map of message names to Messages
-	Messages map[string]*Message
+		var keys []string
+		for p, _ := range props {
+			keys = append(keys, p)
+		}
+		sort.Strings(keys)
 
-	// Map of groups of message names to Messages
-	GroupedMessages map[string]map[string]*Message
+		for _, p := range keys {
+			ps, ok := props[p].(map[string]interface{})
+			if !ok || ps["type"] != "object" {
+				continue
+			}
+			if _, ok := ps["properties"].(map[string]interface{}); !ok {
+				continue
+			}
+			name := k + GoName(p)
+			if _, ok := defs[name]; ok {
+				return fmt.Errorf("jsonmsg: inline object property %q of %q collides with definition %q", p, k, name)
+			}
+			defs[name] = ps
+			props[p] = map[string]interface{}{"$ref": "#/definitions/" + name}
+			aliases["#/definitions/"+k+"/properties/"+p] = "#/definitions/" + name
+			queue = append(queue, name)
+		}
+	}
+	return nil
+}
 
-	// JSON Schema definitions for data definition
-	Definitions jsonschema.Index `json:"-"`
+// UTF-8 byte order mark written by some editors
+var utf8BOM = []byte("\xef\xbb\xbf")
 
-	// Raw spec
-	Raw []byte
+// strips a leading UTF-8 byte order mark and leading whitespace, which json.Unmarshal rejects or Raw should not carry
+func cleanSpec(b []byte) []byte {
+	return bytes.TrimLeft(bytes.TrimPrefix(b, utf8BOM), " \t\r\n")
 }
 
-type urlString struct {
-	url.URL
+// The top-level keys of a spec, values are decoded by Parse
+type topLevelKeys struct {
+	Schema      json.RawMessage `json:"$schema"`
+	Comment     json.RawMessage `json:"$comment"`
+	Title       json.RawMessage
+	Description json.RawMessage
+	Endpoints   json.RawMessage
+	Messages    json.RawMessage
+	Groups      json.RawMessage
+	ErrorSchema json.RawMessage
+	Definitions json.RawMessage
+	Extends     json.RawMessage
 }
 
-func (s *urlString) UnmarshalJSON(b []byte) error {
-	us := string(b)
-	u, err := url.Parse(us[1 : len(us)-1])
+// Rejects keys of a raw spec which are not top-level keys
+func checkTopLevelKeys(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var keys topLevelKeys
+	err := dec.Decode(&keys)
+	if err != nil && strings.HasPrefix(err.Error(), "json: unknown field ") {
+		return fmt.Errorf("jsonmsg: unknown top-level key %v", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return err
+}
+
+// Returns the examples of definitions by definition pointer
+func definitionExamples(b []byte) (map[string][]json.RawMessage, error) {
+	var doc struct {
+		Definitions map[string]struct {
+			Examples []json.RawMessage
+		}
+	}
+	err := json.Unmarshal(b, &doc)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	*s = urlString{*u}
-	return nil
+
+	examples := make(map[string][]json.RawMessage)
+	for k, d := range doc.Definitions {
+		if len(d.Examples) > 0 {
+			examples["#/definitions/"+k] = d.Examples
+		}
+	}
+	return examples, nil
 }
 
-// A Message holds details about name, in- and out parameters
-type Message struct {
-	// Short description
-	Title string
+// Returns the pointers of readOnly and writeOnly properties of definitions
+func accessModifiers(b []byte) (map[string]bool, map[string]bool, error) {
+	var doc struct {
+		Definitions map[string]struct {
+			Properties map[string]struct {
+				ReadOnly  bool
+				WriteOnly bool
+			}
+		}
+	}
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Detailed description
-	Description string
+	readOnly := make(map[string]bool)
+	writeOnly := make(map[string]bool)
+	for k, d := range doc.Definitions {
+		for p, ps := range d.Properties {
+			ptr := "#/definitions/" + k + "/properties/" + p
+			if ps.ReadOnly && ps.WriteOnly {
+				return nil, nil, fmt.Errorf("jsonmsg: %v is both readOnly and writeOnly", ptr)
+			}
+			if ps.ReadOnly {
+				readOnly[ptr] = true
+			}
+			if ps.WriteOnly {
+				writeOnly[ptr] = true
+			}
+		}
+	}
+	return readOnly, writeOnly, nil
+}
 
-	// Literal message name as defined in spec
-	Msg string
+// Returns the pointers of definitions marked "deprecated"
+func deprecatedDefinitions(b []byte) (map[string]bool, error) {
+	var doc struct {
+		Definitions map[string]struct {
+			Deprecated bool
+		}
+	}
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, err
+	}
 
-	// Camel-Cased name
-	Name string
+	deprecated := make(map[string]bool)
+	for k, d := range doc.Definitions {
+		if d.Deprecated {
+			deprecated["#/definitions/"+k] = true
+		}
+	}
+	return deprecated, nil
+}
 
-	// JSON Pointer to input schema
-	In string
+// Returns the default values of properties of definitions by property pointer
+func propertyDefaults(b []byte) (map[string]json.RawMessage, error) {
+	var doc struct {
+		Definitions map[string]struct {
+			Properties map[string]struct {
+				Default json.RawMessage
+			}
+		}
+	}
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, err
+	}
 
-	// JSON Pointers to possible output schemas
-	Outs []string
+	defaults := make(map[string]json.RawMessage)
+	for k, d := range doc.Definitions {
+		for p, ps := range d.Properties {
+			if len(ps.Default) > 0 {
+				defaults["#/definitions/"+k+"/properties/"+p] = ps.Default
+			}
+		}
+	}
+	return defaults, nil
+}
 
-	// Pointer to parsed input schema
-	InSchema *jsonschema.Schema
+// Returns the "x-go-name" overrides of properties of definitions by property pointer
+func goNameOverrides(b []byte) (map[string]string, error) {
+	var doc struct {
+		Definitions map[string]struct {
+			Properties map[string]map[string]json.RawMessage
+		}
+	}
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, err
+	}
 
-	// Pointers to parsed output schemas
-	OutSchemas []*jsonschema.Schema
+	names := make(map[string]string)
+	for k, d := range doc.Definitions {
+		for p, ps := range d.Properties {
+			raw, ok := ps["x-go-name"]
+			if !ok {
+				continue
+			}
+			ptr := "#/definitions/" + k + "/properties/" + p
+			var name string
+			err = json.Unmarshal(raw, &name)
+			if err != nil || name == "" {
+				return nil, fmt.Errorf("jsonmsg: x-go-name of %v must be a non-empty string", ptr)
+			}
+			names[ptr] = name
+		}
+	}
+	return names, nil
+}
 
-	// Pointer to parent spec
-	Spec *Spec
+// An allowed value of an enum property
+type EnumValue struct {
+	Value interface{}
 
-	// Optional: Group name associating message to spec.GroupedMessages
-	Group string
+	// Meaning of the value, as given by "x-enum-descriptions" in spec
+	Description string
 }
 
-// Parses a raw schema into a Spec
-func Parse(b []byte) (*Spec, error) {
-	var spec Spec
-	err := json.Unmarshal(b, &spec)
+// Returns the allowed values of enum properties of definitions by property pointer,
+// along with their descriptions given in the same order by "x-enum-descriptions"
+func enumValues(b []byte) (map[string][]EnumValue, error) {
+	var doc struct {
+		Definitions map[string]struct {
+			Properties map[string]struct {
+				Enum         []interface{}
+				Descriptions json.RawMessage `json:"x-enum-descriptions"`
+			}
+		}
+	}
+	err := json.Unmarshal(b, &doc)
 	if err != nil {
 		return nil, err
 	}
 
-	// raw
-	spec.Raw = b
+	values := make(map[string][]EnumValue)
+	for k, d := range doc.Definitions {
+		for p, ps := range d.Properties {
+			ptr := "#/definitions/" + k + "/properties/" + p
+			var descs []string
+			if ps.Descriptions != nil {
+				err = json.Unmarshal(ps.Descriptions, &descs)
+				if err != nil || len(descs) != len(ps.Enum) {
+					return nil, fmt.Errorf("jsonmsg: x-enum-descriptions of %v must be an array of a string per enum value", ptr)
+				}
+			}
+			if len(ps.Enum) == 0 {
+				continue
+			}
+			vs := make([]EnumValue, len(ps.Enum))
+			for i, v := range ps.Enum {
+				vs[i].Value = v
+				if descs != nil {
+					vs[i].Description = descs[i]
+				}
+			}
+			values[ptr] = vs
+		}
+	}
+	return values, nil
+}
 
-	// endpoints
-	for k, _ := range spec.Endpoints {
-		spec.Endpoints[k].Path += "/" + k
+// Replaces type arrays of a single type and "null" by the type, e.g. ["string", "null"] by "string",
+// and returns the pointers of these nullable schemas. Required nullable properties stay required,
+// an explicit null is present but an absent property is not.
+func normalizeNullableTypes(b []byte) ([]byte, map[string]bool, error) {
+	var doc map[string]json.RawMessage
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defs := make(map[string]interface{})
+	if raw, ok := doc["definitions"]; ok {
+		err = json.Unmarshal(raw, &defs)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	nullable := make(map[string]bool)
+	changed := false
+	for k, d := range defs {
+		c, err := normalizeNullableType(d, "#/definitions/"+k, nullable)
+		if err != nil {
+			return nil, nil, err
+		}
+		changed = changed || c
+	}
+	if !changed {
+		return b, nullable, nil
 	}
 
-	// definitions
-	idx, err := jsonschema.Parse(b)
+	raw, err := json.Marshal(defs)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	spec.Definitions = *idx
+	doc["definitions"] = raw
 
-	// messages
-	spec.GroupedMessages = make(map[string]map[string]*Message)
+	h, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, nullable, nil
+}
 
-	for k, _ := range spec.Messages {
-		spec.Messages[k].Msg = k
-		spec.Messages[k].Name = goNameFromStrings(k)
-		spec.Messages[k].Spec = &spec
+// A tuple definition, an array with items of fixed positions given as array,
+// e.g. {"type": "array", "items": [{"type": "number"}, {"type": "number"}]}.
+// It is parsed as object definition with a required property per item, named item0, item1 and so on.
+type Tuple struct {
+	// names of the properties of the items in order
+	Items []string
 
-		InSchema, err := resolvePointerToSchema(spec.Messages[k].In, &spec.Definitions)
+	// whether items beyond the positions are allowed, false for "additionalItems": false
+	AdditionalItems bool
+}
+
+// Rewrites top-level tuple definitions of a raw spec into object definitions with a property per item,
+// returns the rewritten spec and the tuples by definition pointer.
+// Tuple items anywhere else are not supported.
+func normalizeTuples(b []byte) ([]byte, map[string]*Tuple, error) {
+	var doc map[string]json.RawMessage
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defs := make(map[string]interface{})
+	if raw, ok := doc["definitions"]; ok {
+		err = json.Unmarshal(raw, &defs)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		spec.Messages[k].InSchema = InSchema
+	}
 
-		spec.Messages[k].OutSchemas = make([]*jsonschema.Schema, 0)
-		for i, _ := range spec.Messages[k].Outs {
-			outSchema, err := resolvePointerToSchema(spec.Messages[k].Outs[i], &spec.Definitions)
+	tuples := make(map[string]*Tuple)
+	for k, d := range defs {
+		ptr := "#/definitions/" + k
+		s, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		items, ok := s["items"].([]interface{})
+		if !ok {
+			err = checkNoTuples(s, ptr)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
-			spec.Messages[k].OutSchemas = append(spec.Messages[k].OutSchemas, outSchema)
+			continue
 		}
 
-		if _, ok := spec.GroupedMessages[spec.Messages[k].Group]; !ok {
-			spec.GroupedMessages[spec.Messages[k].Group] = make(map[string]*Message)
+		t := &Tuple{AdditionalItems: true}
+		if a, ok := s["additionalItems"]; ok {
+			t.AdditionalItems, ok = a.(bool)
+			if !ok {
+				return nil, nil, fmt.Errorf("jsonmsg: additionalItems of tuple %v must be a boolean", ptr)
+			}
 		}
-		spec.GroupedMessages[spec.Messages[k].Group][k] = spec.Messages[k]
+		props := make(map[string]interface{})
+		var required []interface{}
+		for i, item := range items {
+			name := fmt.Sprintf("item%d", i)
+			err = checkNoTuples(item, fmt.Sprintf("%v/items/%d", ptr, i))
+			if err != nil {
+				return nil, nil, err
+			}
+			props[name] = item
+			required = append(required, name)
+			t.Items = append(t.Items, name)
+		}
+		for _, kw := range []string{"items", "additionalItems", "minItems", "maxItems"} {
+			delete(s, kw)
+		}
+		s["type"] = "object"
+		s["properties"] = props
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		tuples[ptr] = t
+	}
+	if len(tuples) == 0 {
+		return b, tuples, nil
 	}
 
-	return &spec, nil
-}
-
-// Returns an HTML website version of the spec that must be served on {{ BaseURL }}/spec by servers
-func (s *Spec) HTTPSpec() ([]byte, error) {
-	w := &bytes.Buffer{}
-	err := writeTemplate(s, httpSpecTemplate, w)
+	raw, err := json.Marshal(defs)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return w.Bytes(), nil
-}
+	doc["definitions"] = raw
 
-// Returns an indented, marshalled version of the spec that must be served on {{ BaseURL }}/spec.json by servers
-func (s *Spec) JSONSpec() ([]byte, error) {
-	var o interface{}
-	err := json.Unmarshal(s.Raw, &o)
+	h, err := json.Marshal(doc)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return json.MarshalIndent(o, "", "  ")
+	return h, tuples, nil
 }
 
-// Creates a new message instance conforming to the message schema
-func (m *Message) NewInstance() (interface{}, error) {
-	nm := make(map[string]interface{})
-	nm["msg"] = m.Msg
-	if m.InSchema == nil {
-		return nm, nil
+// rejects tuple items within a decoded schema at ptr and its properties and items
+func checkNoTuples(v interface{}, ptr string) error {
+	s, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
 	}
-	data, err := m.InSchema.NewInstance(&m.Spec.Definitions)
-	if err != nil {
-		return nil, err
+	if _, ok := s["items"].([]interface{}); ok {
+		return fmt.Errorf("jsonmsg: tuple items of %v are only supported for definitions, reference a tuple definition instead", ptr)
 	}
-	nm["data"] = data
-	return nm, nil
+	props, _ := s["properties"].(map[string]interface{})
+	for p, ps := range props {
+		err := checkNoTuples(ps, ptr+"/properties/"+p)
+		if err != nil {
+			return err
+		}
+	}
+	return checkNoTuples(s["items"], ptr+"/items")
 }
 
-// creates a go friendly name from string parts
-func goNameFromStrings(parts ...string) string {
-	name := ""
-	re := regexp.MustCompile("{|}|-|_")
-	for _, p := range parts {
-		c := re.ReplaceAllString(p, "")
-		switch c {
-		case "id":
-			name += "ID"
-		case "url":
-			name += "URL"
-		case "api":
-			name += "API"
+// normalizes type arrays of a decoded schema at ptr and its properties and items, returns if any changed
+func normalizeNullableType(v interface{}, ptr string, nullable map[string]bool) (bool, error) {
+	s, ok := v.(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+
+	changed := false
+	if ts, ok := s["type"].([]interface{}); ok {
+		var types []string
+		null := false
+		for _, t := range ts {
+			if t == "null" {
+				null = true
+				continue
+			}
+			if t, ok := t.(string); ok {
+				types = append(types, t)
+			}
+		}
+		switch {
+		case len(types) == 1 && null:
+			s["type"] = types[0]
+			nullable[ptr] = true
+		case len(types) == 0 && null:
+			s["type"] = "null"
 		default:
-			name += strings.Title(c)
+			return false, fmt.Errorf("jsonmsg: %v: type %v is not supported, only a single type optionally with null", ptr, ts)
 		}
+		changed = true
 	}
-	return name
-}
 
-// returns a schema or referenced schema
-func resolvePointerToSchema(p string, idx *jsonschema.Index) (*jsonschema.Schema, error) {
-	if p == "" {
-		return nil, nil
+	props, _ := s["properties"].(map[string]interface{})
+	for p, ps := range props {
+		c, err := normalizeNullableType(ps, ptr+"/properties/"+p, nullable)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || c
 	}
-	s, ok := (*idx)[p]
-	if !ok {
-		return nil, fmt.Errorf("jsonmsg: %v does not exist in index", p)
+	if items, ok := s["items"]; ok {
+		c, err := normalizeNullableType(items, ptr+"/items", nullable)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || c
 	}
-	return s, nil
+
+	return changed, nil
 }
 
-// Parses the spec input, applies the template and writes it to the writer
-func writeTemplate(s *Spec, t string, w io.Writer) error {
-	tmpl, err := template.New("spec").Funcs(template.FuncMap{
-		"Contains": stringsContain,
-		"Add": func(a int, b int) int {
-			return a + b
-		},
-		"JSON": func(in interface{}) string {
-			jsn, err := json.MarshalIndent(in, "", "  ")
-			if err != nil {
-				panic(err)
+// points all $ref pointers into aliased schemas in a decoded JSON value at their definitions
+func rewriteAliasRefs(v interface{}, aliases map[string]string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if r, ok := e.(string); ok && k == "$ref" {
+				t[k] = resolveAliases(aliases, r)
+				continue
 			}
-			return string(jsn)
-		},
-		"SubstringRight": func(a string, n int) string {
-			return a[0 : len(a)-n]
-		},
-	}).Parse(t)
-	if err != nil {
-		return err
+			t[k] = rewriteAliasRefs(e, aliases)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = rewriteAliasRefs(e, aliases)
+		}
 	}
-	err = tmpl.Execute(w, s)
-	if err != nil {
-		return err
+	return v
+}
+
+// replaces the prefix of all $ref pointers in a decoded JSON value
+func rewriteRefs(v interface{}, from string, to string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if r, ok := e.(string); ok && k == "$ref" && strings.HasPrefix(r, from) {
+				t[k] = to + strings.TrimPrefix(r, from)
+				continue
+			}
+			t[k] = rewriteRefs(e, from, to)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = rewriteRefs(e, from, to)
+		}
 	}
+	return v
+}
 
-	return nil
+var (
+	specTemplateOnce sync.Once
+	specTemplate     *template.Template
+	specTemplateErr  error
+)
+
+// Returns httpSpecTemplate, which is parsed once for all specs
+func parsedSpecTemplate() (*template.Template, error) {
+	specTemplateOnce.Do(func() {
+		specTemplate, specTemplateErr = template.New("spec").Funcs(template.FuncMap{
+			"Contains": stringsContain,
+			"Add": func(a int, b int) int {
+				return a + b
+			},
+			"JSON": func(in interface{}) string {
+				jsn, err := json.MarshalIndent(in, "", "  ")
+				if err != nil {
+					panic(err)
+				}
+				return string(jsn)
+			},
+			"SubstringRight": func(a string, n int) string {
+				return a[0 : len(a)-n]
+			},
+		}).Parse(httpSpecTemplate)
+	})
+	return specTemplate, specTemplateErr
 }
 
 const httpSpecTemplate = `
@@ -345,6 +2236,10 @@ const httpSpecTemplate = `
 				font-size: 1em;
 				font-weight: normal;
 			}
+
+			h3 span.deprecated {
+				color: #a94442;
+			}
 			
 			h4 {
     		color: #555d73;
@@ -374,6 +2269,16 @@ const httpSpecTemplate = `
 				text-decoration: underline;
 			}
 
+			table.status {
+				margin-left: 2em;
+				border-collapse: collapse;
+			}
+
+			table.status td {
+				padding: 0.2em 1em 0.2em 0;
+				vertical-align: top;
+			}
+
 			p.level1 {
 				padding-left: 1em;
 				padding-right: 1em;
@@ -417,6 +2322,12 @@ const httpSpecTemplate = `
 				padding-left: 0.5em;
 			}
 
+			ul.enum {
+				margin: 0 0 0 1em;
+				max-width: 48em;
+				line-height: 1.4em;
+			}
+
 			pre.code,
 			textarea.code {
 				width: 100%;
@@ -584,25 +2495,47 @@ const httpSpecTemplate = `
 		{{ $k }}
 		{{ if $g }}<span>{{ $g }}</span>{{ end }}
 		<span>{{ $v.Title }}</span>
+		{{ if $v.Deprecated }}<span class="deprecated">deprecated</span>{{ end }}
 		</h3>
 		<p class="level1">{{ $v.Description }}</p>
 		<dl>
 		<dd>In</dd>
 		{{ if $v.InSchema }}
 		<dd class="level1"><a href="#data-{{ $v.InSchema.PointerName }}">{{ $v.InSchema.PointerName }}</a></dd>
+		{{ else if $v.InSchemas }}
+		{{ range $v.InSchemas }}
+		<dd class="level1"><a href="#data-{{ .PointerName }}">{{ .PointerName }}</a> <span>one of</span></dd>
+		{{ end }}
 		{{ else }}
 		<dd class="level1">None</dd>
 		{{ end }}
 		<dd>Outs</dd>
-		{{ range $v.OutSchemas }}
-		<dd class="level1"><a href="#data-{{ .PointerName }}">{{ .PointerName }}</a></dd>
+		{{ range $i, $o := $v.OutSchemas }}
+		<dd class="level1"><a href="#data-{{ $o.PointerName }}">{{ $o.PointerName }}</a>{{ with $v.OutDescription $i }} <span>{{ . }}</span>{{ end }}</dd>
 		{{ end }}
 		</dl>
 
+		<h4>Status Codes</h4>
+		<table class="status">
+		{{ range $v.StatusCodes }}
+		<tr><td>{{ .Code }}</td><td>{{ .Description }}</td></tr>
+		{{ end }}
+		</table>
+
 		<h4>Test</h4>
 		<div class="row">
 		<textarea class="code" id="input-{{ $k }}" spellcheck="false" rows={{ if not $v.InSchema }}5{{ else }}{{ Add 5 (len $v.InSchema.Properties) }}{{ end }}>{{ JSON $v.NewInstance }}</textarea>
 		</div>
+		{{ with $v.NewExampleInstances }}
+		<div class="row">
+		<select onchange="document.querySelector('#input-{{ $k }}').value = this.value">
+			<option value="{{ html (JSON $v.NewInstance) }}">Generated</option>
+			{{ range $i, $e := . }}
+			<option value="{{ html (JSON $e) }}">Example {{ Add $i 1 }}</option>
+			{{ end }}
+		</select>
+		</div>
+		{{ end }}
 		<div class="row right">
 			{{ if (index $.Endpoints "websocket") }}
 			<button onclick="jsonmsg.websocket.sendFromInputToOutput('#input-{{ $k }}', '#output-{{ $k }}')">Send WebSocket</button>
@@ -614,6 +2547,15 @@ const httpSpecTemplate = `
 		<div class="row">
 		<pre class="code invisible" id="output-{{ $k }}"></pre>
 		</div>
+
+		{{ with $v.NewOutputInstances }}
+		<h4>Examples</h4>
+		{{ range . }}
+		<div class="row">
+		<pre class="code">{{ JSON . }}</pre>
+		</div>
+		{{ end }}
+		{{ end }}
 		{{ end }}
 		{{ end }}
 
@@ -626,6 +2568,7 @@ const httpSpecTemplate = `
 			{{ $v.PointerName }}
 			<span>{{ $v.Type }}</span>
 			<span>{{ $v.Title }}</span>
+			{{ if index $.Deprecated $k }}<span class="deprecated">deprecated</span>{{ end }}
 		</h3>
 		<p class="level1">{{ $v.Description }}</p>
 		
@@ -666,6 +2609,13 @@ const httpSpecTemplate = `
 			</span></div>
 			<div style="width: 33%">{{ $pv.Description }}</div>
 		</div>
+		{{ with index $.EnumValues (printf "%v/properties/%v" $k $pk) }}
+		<ul class="enum">
+		{{ range . }}
+		<li><code>{{ JSON .Value }}</code>{{ with .Description }} {{ . }}{{ end }}</li>
+		{{ end }}
+		</ul>
+		{{ end }}
 		{{ end }}
 		{{ end }}
 
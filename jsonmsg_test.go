@@ -1,9 +1,13 @@
 package jsonmsg
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/tfkhsr/jsonmsg/fixture"
+	"github.com/tfkhsr/jsonschema"
 )
 
 func TestSimpleLogin(t *testing.T) {
@@ -63,6 +67,1110 @@ func TestSimpleLoginWithHTTPAndWebsockets(t *testing.T) {
 	}
 }
 
+func TestEndpointMarshaling(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaSimpleLoginHTTPandWebsocket))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(spc.Endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"http":"https://api.specc.io/v1","websocket":"wss://api.specc.io/v1"}`
+	if string(b) != expected {
+		t.Fatalf("marshaled endpoints were %s, expected %s", b, expected)
+	}
+
+	var rt map[string]*urlString
+	err = json.Unmarshal(b, &rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt["http"].String() != "https://api.specc.io/v1" {
+		t.Fatalf("invalid round-tripped http url: %v", rt["http"].String())
+	}
+}
+
+func TestHostEndpoints(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaHostEndpoints))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spc.Endpoints) != 2 {
+		t.Fatalf("endpoints were %v", spc.Endpoints)
+	}
+	if u := spc.Endpoints["http"].String(); u != "https://api.specc.io/v1/http" {
+		t.Fatalf("http endpoint was %v", u)
+	}
+	if u := spc.Endpoints["websocket"].String(); u != "wss://api.specc.io/v1/websocket" {
+		t.Fatalf("websocket endpoint was %v", u)
+	}
+
+	// without tls
+	raw := strings.Replace(fixture.TestSchemaHostEndpoints, `"tls": true`, `"tls": false`, 1)
+	spc, err = Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := spc.Endpoints["http"].String(); u != "http://api.specc.io/v1/http" {
+		t.Fatalf("http endpoint was %v", u)
+	}
+	if u := spc.Endpoints["websocket"].String(); u != "ws://api.specc.io/v1/websocket" {
+		t.Fatalf("websocket endpoint was %v", u)
+	}
+}
+
+func TestMessageLocalSchemas(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaMessageLocalSchemas))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	find := spc.Messages["findUser"]
+	if find.In != "#/messages/findUser/in" {
+		t.Fatalf("invalid message in: %v", find.In)
+	}
+	if find.InSchema == nil {
+		t.Fatal("missing in schema")
+	}
+	if find.InSchema.Name != "FindUserIn" {
+		t.Fatalf("invalid in schema name: %v", find.InSchema.Name)
+	}
+	if _, ok := find.InSchema.Properties["id"]; !ok {
+		t.Fatalf("in schema is missing property id: %v", find.InSchema.Properties)
+	}
+	if find.InSchema != spc.Definitions["#/definitions/findUserIn"] {
+		t.Fatal("in schema was not indexed")
+	}
+
+	del := spc.Messages["deleteUser"]
+	if del.In != "#/messages/findUser/in" {
+		t.Fatalf("invalid message in: %v", del.In)
+	}
+	if del.InSchema != find.InSchema {
+		t.Fatal("in schema pointer did not resolve to findUser in schema")
+	}
+
+	_, err = Parse([]byte(strings.Replace(fixture.TestSchemaMessageLocalSchemas, `"#/messages/findUser/in"`, `"#/messages/findUser/out"`, 1)))
+	if err == nil {
+		t.Fatal("unknown message-local pointer should not resolve")
+	}
+}
+
+func TestNewInstanceFor(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaMessageLocalSchemas))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := spc.Messages["findUser"]
+
+	tbl := []struct {
+		Name     string
+		Schema   *jsonschema.Schema
+		Expected string
+	}{
+		{"out", m.OutSchemas[0], `{"data":{"id":"string","name":"string"},"msg":"user"}`},
+		{"in", m.InSchema, `{"data":{"id":"string"},"msg":"findUser"}`},
+		{"none", nil, `{"msg":"findUser"}`},
+	}
+	for _, e := range tbl {
+		inst, err := m.NewInstanceFor(e.Schema)
+		if err != nil {
+			t.Fatal(e.Name, err)
+		}
+		b, err := json.Marshal(inst)
+		if err != nil {
+			t.Fatal(e.Name, err)
+		}
+		if string(b) != e.Expected {
+			t.Fatalf("%v: instance was %s, expected %s", e.Name, b, e.Expected)
+		}
+	}
+
+	// NewInstance delegates to the in schema
+	inst, err := m.NewInstance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != tbl[1].Expected {
+		t.Fatalf("instance was %s, expected %s", b, tbl[1].Expected)
+	}
+}
+
+func TestInlineObjectProperties(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaInlineObjectProperties))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tbl := []struct {
+		Pointer string
+		Name    string
+	}{
+		{"#/definitions/user/properties/address", "UserAddress"},
+		{"#/definitions/user/properties/address/properties/geo", "UserAddressGeo"},
+	}
+	for _, e := range tbl {
+		sch, err := spc.resolvePointerToSchema(e.Pointer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sch.Name != e.Name {
+			t.Fatalf("%v resolved to %v, expected %v", e.Pointer, sch.Name, e.Name)
+		}
+		if sch.Type != "object" {
+			t.Fatalf("%v was not an object: %v", e.Pointer, sch.Type)
+		}
+	}
+
+	addr := spc.Definitions["#/definitions/user"].Properties["address"]
+	if addr.Type != "ref" || addr.Ref != "#/definitions/userAddress" {
+		t.Fatalf("address was not replaced by a reference: %v %v", addr.Type, addr.Ref)
+	}
+
+	_, err = Parse([]byte(strings.Replace(fixture.TestSchemaInlineObjectProperties, `"definitions": {`, `"definitions": {
+		"userAddress": {
+			"type": "string"
+		},`, 1)))
+	if err == nil {
+		t.Fatal("colliding inline object property should not parse")
+	}
+}
+
+func TestInvalidPointers(t *testing.T) {
+	table := []struct {
+		Name  string
+		From  string
+		To    string
+		Error string
+	}{
+		{
+			"bad in pointer",
+			`"in": "#/definitions/credentials"`,
+			`"in": "#/definitions/credential"`,
+			`jsonmsg: message "loginWithCredentials" in: #/definitions/credential does not exist`,
+		},
+		{
+			"bad outs pointer",
+			`"#/definitions/error"`,
+			`"#/definitions/eror"`,
+			`jsonmsg: message "loginWithCredentials" outs[1]: #/definitions/eror does not exist`,
+		},
+	}
+	for _, ts := range table {
+		spec := strings.Replace(fixture.TestSchemaSimpleLogin, ts.From, ts.To, 1)
+		_, err := Parse([]byte(spec))
+		if err == nil {
+			t.Fatalf("%v: should not parse", ts.Name)
+		}
+		if err.Error() != ts.Error {
+			t.Fatalf("%v: error was %q, expected %q", ts.Name, err.Error(), ts.Error)
+		}
+	}
+}
+
+func TestDuplicateOuts(t *testing.T) {
+	_, err := Parse([]byte(fixture.TestSchemaDuplicateOuts))
+	if err == nil {
+		t.Fatal("duplicate outs should not parse")
+	}
+	expected := `jsonmsg: message "fetchUser" outs[1]: #/definitions/user-info is already listed as outs[0]`
+	if err.Error() != expected {
+		t.Fatalf("error was %q, expected %q", err.Error(), expected)
+	}
+
+	// distinct outs with the same name
+	spec := strings.Replace(fixture.TestSchemaDuplicateOuts, `"#/definitions/user-info"
+`, `"#/definitions/user_info"
+`, 1)
+	_, err = Parse([]byte(spec))
+	if err == nil {
+		t.Fatal("outs with colliding names should not parse")
+	}
+	expected = `jsonmsg: message "fetchUser" outs[1]: #/definitions/user_info is named Userinfo, which collides with outs[0]`
+	if err.Error() != expected {
+		t.Fatalf("error was %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestNullableTypes(t *testing.T) {
+	spec, err := Parse([]byte(fixture.TestSchemaNullableTypes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"#/definitions/profile/properties/nickname", "#/definitions/profile/properties/email"} {
+		if !spec.Nullable[p] {
+			t.Fatalf("%v should be nullable", p)
+		}
+		if typ := spec.Definitions[p].Type; typ != "string" {
+			t.Fatalf("%v has type %v, expected string", p, typ)
+		}
+	}
+	if spec.Nullable["#/definitions/profile/properties/name"] {
+		t.Fatal("name should not be nullable")
+	}
+
+	// required nullable properties stay required
+	req := spec.Definitions["#/definitions/profile"].Required
+	if len(req) != 2 || req[0] != "name" || req[1] != "email" {
+		t.Fatalf("required properties were %v", req)
+	}
+
+	// unions of types are not supported
+	raw := strings.Replace(fixture.TestSchemaNullableTypes, `["string", "null"]`, `["string", "integer"]`, 1)
+	_, err = Parse([]byte(raw))
+	if err == nil {
+		t.Fatal("type union should not parse")
+	}
+	expected := `jsonmsg: #/definitions/profile/properties/nickname: type [string integer] is not supported, only a single type optionally with null`
+	if err.Error() != expected {
+		t.Fatalf("error was %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tbl := []struct {
+		Parts []string
+		Name  string
+	}{
+		{[]string{"findUser"}, "FindUser"},
+		{[]string{"user", "id"}, "UserID"},
+		{[]string{"api", "url"}, "APIURL"},
+		{[]string{"{user_name}"}, "Username"},
+	}
+	for _, e := range tbl {
+		if n := GoName(e.Parts...); n != e.Name {
+			t.Fatalf("name of %v was %v, expected %v", e.Parts, n, e.Name)
+		}
+	}
+}
+
+func TestParseByteOrderMark(t *testing.T) {
+	spec, err := Parse([]byte("\xef\xbb\xbf\r\n" + fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := spec.Messages["loginWithCredentials"]; !ok {
+		t.Fatal("missing message loginWithCredentials")
+	}
+	if len(spec.Raw) == 0 || spec.Raw[0] != '{' {
+		t.Fatalf("raw spec starts with %q", spec.Raw[:4])
+	}
+}
+
+func TestAlternativeIns(t *testing.T) {
+	spec, err := Parse([]byte(fixture.TestSchemaAlternativeIns))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := spec.Messages["login"]
+	if m.InSchema != nil {
+		t.Fatalf("in schema was %v", m.InSchema)
+	}
+	if len(m.InSchemas) != 2 || m.InSchemas[0] != spec.Definitions["#/definitions/credentials"] || m.InSchemas[1] != spec.Definitions["#/definitions/token"] {
+		t.Fatalf("in schemas were %v", m.InSchemas)
+	}
+	inst, err := m.NewInstance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := inst.(map[string]interface{})["msg"]; msg != "login" {
+		t.Fatalf("instance msg was %v", msg)
+	}
+
+	table := []struct {
+		Name  string
+		To    string
+		Error string
+	}{
+		{
+			"missing alternative",
+			`"#/definitions/tokn"`,
+			`jsonmsg: message "login" in[1]: #/definitions/tokn does not exist`,
+		},
+		{
+			"duplicate alternative",
+			`"#/definitions/credentials"`,
+			`jsonmsg: message "login" in[1]: #/definitions/credentials is already listed as in[0]`,
+		},
+	}
+	for _, ts := range table {
+		raw := strings.Replace(fixture.TestSchemaAlternativeIns, `"#/definitions/token"`, ts.To, 1)
+		_, err := Parse([]byte(raw))
+		if err == nil {
+			t.Fatalf("%v: should not parse", ts.Name)
+		}
+		if err.Error() != ts.Error {
+			t.Fatalf("%v: error was %q, expected %q", ts.Name, err.Error(), ts.Error)
+		}
+	}
+}
+
+func TestReadWriteOnly(t *testing.T) {
+	spec, err := Parse([]byte(fixture.TestSchemaReadWriteOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.ReadOnly) != 1 || !spec.ReadOnly["#/definitions/account/properties/id"] {
+		t.Fatalf("readOnly properties were %v", spec.ReadOnly)
+	}
+	if len(spec.WriteOnly) != 1 || !spec.WriteOnly["#/definitions/credentials/properties/password"] {
+		t.Fatalf("writeOnly properties were %v", spec.WriteOnly)
+	}
+
+	raw := strings.Replace(fixture.TestSchemaReadWriteOnly, `"writeOnly": true`, `"writeOnly": true, "readOnly": true`, 1)
+	_, err = Parse([]byte(raw))
+	if err == nil {
+		t.Fatal("readOnly and writeOnly property should not parse")
+	}
+	expected := `jsonmsg: #/definitions/credentials/properties/password is both readOnly and writeOnly`
+	if err.Error() != expected {
+		t.Fatalf("error was %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestGoNames(t *testing.T) {
+	spec, err := Parse([]byte(fixture.TestSchemaGoNames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.GoNames) != 2 || spec.GoNames["#/definitions/user/properties/url_slug"] != "Slug" || spec.GoNames["#/definitions/user/properties/id"] != "UserID" {
+		t.Fatalf("go names were %v", spec.GoNames)
+	}
+
+	raw := strings.Replace(fixture.TestSchemaGoNames, `"x-go-name": "Slug"`, `"x-go-name": 7`, 1)
+	_, err = Parse([]byte(raw))
+	if err == nil {
+		t.Fatal("non-string x-go-name should not parse")
+	}
+	expected := `jsonmsg: x-go-name of #/definitions/user/properties/url_slug must be a non-empty string`
+	if err.Error() != expected {
+		t.Fatalf("error was %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestDisallowUnknownKeys(t *testing.T) {
+	raw := strings.Replace(fixture.TestSchemaSimpleLogin, `"endpoints"`, `"endpints"`, 1)
+
+	// ignored by default
+	_, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseWithOptions([]byte(raw), ParseOptions{DisallowUnknownKeys: true})
+	expected := `jsonmsg: unknown top-level key "endpints"`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("error was %v, expected %v", err, expected)
+	}
+
+	// known keys
+	for _, f := range []string{fixture.TestSchemaSimpleLogin, fixture.TestSchemaComments, fixture.TestSchemaCustomErrorSchema} {
+		_, err = ParseWithOptions([]byte(f), ParseOptions{DisallowUnknownKeys: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStrictEmptyMessages(t *testing.T) {
+	// not strict by default
+	_, err := Parse([]byte(fixture.TestSchemaStrictEmptyMessages))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseWithOptions([]byte(fixture.TestSchemaStrictEmptyMessages), ParseOptions{Strict: true})
+	if err == nil {
+		t.Fatal("message without in and outs should not parse in strict mode")
+	}
+	expected := `jsonmsg: message "logout" declares neither in nor outs, mark it with "empty": true if intended`
+	if err.Error() != expected {
+		t.Fatalf("error was %q, expected %q", err.Error(), expected)
+	}
+
+	// marked as intended
+	raw := strings.Replace(fixture.TestSchemaStrictEmptyMessages, `"description": "Ends the session"`, `"empty": true`, 1)
+	spec, err := ParseWithOptions([]byte(raw), ParseOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spec.Messages["ping"].Empty || !spec.Messages["logout"].Empty {
+		t.Fatal("messages should be marked empty")
+	}
+}
+
+func TestResponseHeaders(t *testing.T) {
+	spec, err := Parse([]byte(fixture.TestSchemaResponseHeaders))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := spec.Messages["findUser"].Headers["cache-control"]; h != "max-age=60, public" {
+		t.Fatalf("cache control was %q", h)
+	}
+	if hs := spec.Messages["deleteUser"].Headers; hs != nil {
+		t.Fatalf("headers of deleteUser were %v", hs)
+	}
+
+	table := []struct {
+		Name    string
+		Headers string
+		Error   string
+	}{
+		{
+			"header name with space",
+			`{"Cache Control": "no-cache"}`,
+			`jsonmsg: message "findUser" headers: "Cache Control" is not a valid header name`,
+		},
+		{
+			"header value with line break",
+			`{"X-Hint": "a\r\nSet-Cookie: b"}`,
+			`jsonmsg: message "findUser" headers: value of X-Hint must not contain line breaks`,
+		},
+	}
+	for _, ts := range table {
+		raw := strings.Replace(fixture.TestSchemaResponseHeaders, `"headers": {`, `"headers": `+ts.Headers+`, "unused": {`, 1)
+		_, err := Parse([]byte(raw))
+		if err == nil {
+			t.Fatalf("%v: should not parse", ts.Name)
+		}
+		if err.Error() != ts.Error {
+			t.Fatalf("%v: error should be '%v' but is '%v'", ts.Name, ts.Error, err)
+		}
+	}
+}
+
+func TestFormMessages(t *testing.T) {
+	spec, err := Parse([]byte(fixture.TestSchemaFormUpload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spec.Messages["uploadAvatar"].Form || spec.Messages["findAvatar"].Form {
+		t.Fatal("only uploadAvatar should be a form")
+	}
+
+	_, err = Parse([]byte(strings.Replace(fixture.TestSchemaFormUpload, `"in": "#/definitions/avatarUpload",`, "", 1)))
+	expected := `jsonmsg: message "uploadAvatar" is a form, but has no object in schema to bind the form to`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("error was %v, expected %v", err, expected)
+	}
+}
+
+func TestRelativeEndpoints(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaRelativeEndpoints))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, e := range spc.Endpoints {
+		if !e.Relative() || e.String() != "/v1/"+k {
+			t.Fatalf("endpoint %v was %v", k, e.String())
+		}
+	}
+
+	b, err := spc.JSONSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"http": "/v1"`) {
+		t.Fatalf("relative endpoint did not round-trip:\n%s", b)
+	}
+
+	b, err = spc.AsyncAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"protocol": "ws"`) {
+		t.Fatalf("relative websocket server has no protocol:\n%s", b)
+	}
+}
+
+func TestDeclaredGroups(t *testing.T) {
+	_, err := Parse([]byte(fixture.TestSchemaMistypedGroup))
+	expected := `jsonmsg: message "loginWithCredentials" has group "logn", which is not declared in groups login, logout`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("error was %v, expected %v", err, expected)
+	}
+
+	// messages without group are valid
+	spc, err := Parse([]byte(strings.Replace(fixture.TestSchemaMistypedGroup, `"group": "logn"`, `"group": "login"`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spc.Groups) != 2 || len(spc.GroupedMessages["login"]) != 1 || len(spc.GroupedMessages[""]) != 1 {
+		t.Fatalf("groups were %v with messages %v", spc.Groups, spc.GroupedMessages)
+	}
+
+	// groups are not validated without declaration
+	_, err = Parse([]byte(strings.Replace(fixture.TestSchemaMistypedGroup, `"groups": ["login", "logout"],`, "", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidEndpoints(t *testing.T) {
+	table := []struct {
+		Name     string
+		Endpoint string
+		Error    string
+	}{
+		{
+			"schemeless http endpoint",
+			`"http": "api.specc.io/v1"`,
+			`jsonmsg: endpoint "http" (api.specc.io/v1) must have scheme http or https`,
+		},
+		{
+			"mismatched http scheme",
+			`"http": "wss://api.specc.io/v1"`,
+			`jsonmsg: endpoint "http" (wss://api.specc.io/v1) must have scheme http or https`,
+		},
+		{
+			"mismatched websocket scheme",
+			`"websocket": "https://api.specc.io/v1"`,
+			`jsonmsg: endpoint "websocket" (https://api.specc.io/v1) must have scheme ws or wss`,
+		},
+		{
+			"relative path without leading slash",
+			`"http": "v1"`,
+			`jsonmsg: endpoint "http" (v1) must have scheme http or https`,
+		},
+		{
+			"missing host",
+			`"http": "http:///v1"`,
+			`jsonmsg: endpoint "http" (http:///v1) is missing a host`,
+		},
+		{
+			"unknown protocol of host",
+			`"host": "api.specc.io/v1", "protocols": ["ftp"]`,
+			`jsonmsg: endpoint protocol "ftp" is unknown`,
+		},
+		{
+			"host without protocols",
+			`"host": "api.specc.io/v1"`,
+			`jsonmsg: endpoints of host api.specc.io/v1 list no protocols`,
+		},
+	}
+	for _, ts := range table {
+		spec := strings.Replace(fixture.TestSchemaSimpleLogin, `"http": "http://api.specc.io/v1"`, ts.Endpoint, 1)
+		_, err := Parse([]byte(spec))
+		if err == nil {
+			t.Fatalf("%v: should not parse", ts.Name)
+		}
+		if err.Error() != ts.Error {
+			t.Fatalf("%v: error should be '%v' but is '%v'", ts.Name, ts.Error, err)
+		}
+	}
+}
+
+func TestMessageLookup(t *testing.T) {
+	tbl := []struct {
+		Schema string
+		Msg    string
+		Name   string
+		Found  bool
+	}{
+		{fixture.TestSchemaSimpleLogin, "loginWithCredentials", "LoginWithCredentials", true},
+		{fixture.TestSchemaSimpleLogin, "logout", "Logout", true},
+		{fixture.TestSchemaSimpleLogin, "login", "Login", false},
+		{fixture.TestSchemaSimpleLogin, "LoginWithCredentials", "loginWithCredentials", false},
+		{fixture.TestSchemaEmptyMessages, "subscribeInOnly", "SubscribeInOnly", true},
+		{fixture.TestSchemaMessageLocalSchemas, "findUser", "FindUser", true},
+		{fixture.TestSchemaMessageLocalSchemas, "findUsers", "FindUsers", false},
+	}
+
+	for _, e := range tbl {
+		spc, err := Parse([]byte(e.Schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, ok := spc.MessageByMsg(e.Msg)
+		if ok != e.Found {
+			t.Fatalf("MessageByMsg(%q) found was %v, expected %v", e.Msg, ok, e.Found)
+		}
+		if ok && m.Msg != e.Msg {
+			t.Fatalf("MessageByMsg(%q) returned %v", e.Msg, m.Msg)
+		}
+
+		m, ok = spc.MessageByName(e.Name)
+		if ok != e.Found {
+			t.Fatalf("MessageByName(%q) found was %v, expected %v", e.Name, ok, e.Found)
+		}
+		if ok && m.Name != e.Name {
+			t.Fatalf("MessageByName(%q) returned %v", e.Name, m.Name)
+		}
+		if !ok && m != nil {
+			t.Fatalf("MessageByName(%q) returned a message on miss", e.Name)
+		}
+	}
+}
+
+func TestDefinitionLookup(t *testing.T) {
+	tbl := []struct {
+		Schema  string
+		Pointer string
+		Name    string
+		Found   bool
+	}{
+		{fixture.TestSchemaSimpleLogin, "#/definitions/credentials", "Credentials", true},
+		{fixture.TestSchemaSimpleLogin, "#/definitions/credentials/properties/name", "CredentialsName", true},
+		{fixture.TestSchemaSimpleLogin, "#/definitions/user", "User", false},
+		{fixture.TestSchemaSimpleLogin, "credentials", "credentials", false},
+		{fixture.TestSchemaMessageLocalSchemas, "#/definitions/user", "User", true},
+		{fixture.TestSchemaMessageLocalSchemas, "#/messages/findUser/in", "FindUserIn", true},
+	}
+	for _, e := range tbl {
+		spc, err := Parse([]byte(e.Schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, ok := spc.Definition(e.Pointer)
+		if ok != e.Found {
+			t.Fatalf("Definition(%q) found was %v, expected %v", e.Pointer, ok, e.Found)
+		}
+		if ok && d.Name != e.Name {
+			t.Fatalf("Definition(%q) returned %v", e.Pointer, d.Name)
+		}
+
+		byName, ok := spc.DefinitionByName(e.Name)
+		if ok != e.Found {
+			t.Fatalf("DefinitionByName(%q) found was %v, expected %v", e.Name, ok, e.Found)
+		}
+		if ok && byName != d {
+			t.Fatalf("DefinitionByName(%q) returned %v", e.Name, byName.Pointer)
+		}
+		if !ok && byName != nil {
+			t.Fatalf("DefinitionByName(%q) returned a schema on miss", e.Name)
+		}
+	}
+}
+
+func TestAnnotatedOuts(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaAnnotatedOuts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := spc.Messages["loginWithCredentials"]
+	outs := []string{"#/definitions/session", "#/definitions/error", "#/definitions/message"}
+	descs := []string{"Returned when the credentials are valid", "Returned when the credentials are invalid", ""}
+	for i, o := range outs {
+		if m.Outs[i] != o {
+			t.Fatalf("out %v was %v, expected %v", i, m.Outs[i], o)
+		}
+		if m.OutSchemas[i] != spc.Definitions[o] {
+			t.Fatalf("out schema %v did not resolve to %v", i, o)
+		}
+		if m.OutDescription(i) != descs[i] {
+			t.Fatalf("out description %v was %q, expected %q", i, m.OutDescription(i), descs[i])
+		}
+	}
+
+	b, err := spc.HTTPSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	section := htmlMessageSection(t, string(b), "loginWithCredentials")
+	for _, d := range descs[:2] {
+		if !strings.Contains(section, "<span>"+d+"</span>") {
+			t.Fatalf("missing out description %q: %v", d, section)
+		}
+	}
+}
+
+func TestHTTPSpecExamples(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := spc.HTTPSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	section := htmlMessageSection(t, string(b), "loginWithCredentials")
+	examples := strings.Index(section, "<h4>Examples</h4>")
+	if examples == -1 {
+		t.Fatalf("missing examples for loginWithCredentials: %v", section)
+	}
+	for _, e := range []string{`"msg": "session"`, `"msg": "error"`} {
+		if !strings.Contains(section[examples:], e) {
+			t.Fatalf("missing example %v for loginWithCredentials: %v", e, section)
+		}
+	}
+
+	// messages without outs have no examples
+	spc, err = Parse([]byte(fixture.TestSchemaEmptyMessages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = spc.HTTPSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	section = htmlMessageSection(t, string(b), "subscribeInOnly")
+	if strings.Contains(section, "<h4>Examples</h4>") {
+		t.Fatalf("message without outs has examples: %v", section)
+	}
+}
+
+func TestHTTPSpecExamplePresets(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaExamples))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := spc.Messages["findUser"]
+	if len(m.Examples) != 1 || string(m.Examples[0]) != `{"id": "42"}` {
+		t.Fatalf("message examples were %s", m.Examples)
+	}
+	if e := spc.Examples["#/definitions/userQuery"]; len(e) != 1 || string(e[0]) != `{"id": "7"}` {
+		t.Fatalf("definition examples were %s", e)
+	}
+
+	b, err := spc.HTTPSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	section := htmlMessageSection(t, string(b), "findUser")
+	generated := strings.Index(section, `">Generated</option>`)
+	example1 := strings.Index(section, `&#34;id&#34;: &#34;42&#34;`)
+	example2 := strings.Index(section, `&#34;id&#34;: &#34;7&#34;`)
+	if generated == -1 || example1 < generated || example2 < example1 {
+		t.Fatalf("missing presets for findUser: %v", section)
+	}
+	if !strings.Contains(section[example2:], `&#34;msg&#34;: &#34;findUser&#34;`) {
+		t.Fatalf("example preset is not a findUser message: %v", section)
+	}
+
+	// messages without examples have no presets
+	spc, err = Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = spc.HTTPSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	section = htmlMessageSection(t, string(b), "loginWithCredentials")
+	if strings.Contains(section, "<select") {
+		t.Fatalf("message without examples has presets: %v", section)
+	}
+}
+
+func TestHTTPSpecStatusCodes(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaRequiredCredentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := spc.HTTPSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	section := htmlMessageSection(t, string(b), "loginWithCredentials")
+	for _, c := range []string{"<td>200</td>", "<td>422</td>", "<td>500</td>"} {
+		if !strings.Contains(section, c) {
+			t.Fatalf("missing status code %v for loginWithCredentials: %v", c, section)
+		}
+	}
+
+	// messages without in are never invalid
+	section = htmlMessageSection(t, string(b), "logout")
+	if strings.Contains(section, "<td>422</td>") {
+		t.Fatalf("message without in has status code 422: %v", section)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaDeprecated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spc.Messages["findUserByName"].Deprecated || spc.Messages["findUser"].Deprecated {
+		t.Fatal("only findUserByName should be deprecated")
+	}
+	if len(spc.Deprecated) != 1 || !spc.Deprecated["#/definitions/nameQuery"] {
+		t.Fatalf("deprecated definitions were %v", spc.Deprecated)
+	}
+
+	b, err := spc.HTTPSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	badge := `<span class="deprecated">deprecated</span>`
+	if !strings.Contains(htmlMessageSection(t, string(b), "findUserByName"), badge) {
+		t.Fatal("deprecated message has no badge")
+	}
+	if strings.Contains(htmlMessageSection(t, string(b), "findUser"), badge) {
+		t.Fatal("message findUser has a deprecation badge")
+	}
+	if strings.Count(string(b), badge) != 2 {
+		t.Fatalf("expected badges of the message and the definition:\n%s", b)
+	}
+}
+
+func TestHTTPSpecEnumDescriptions(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaEnumDescriptions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	role := spc.EnumValues["#/definitions/user/properties/role"]
+	if len(spc.EnumValues) != 2 || len(role) != 3 || role[1].Value != "member" || role[1].Description != "Reads and writes own data" {
+		t.Fatalf("enum values were %v", spc.EnumValues)
+	}
+
+	b, err := spc.HTTPSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{
+		`<li><code>"admin"</code> Manages all users</li>`,
+		`<li><code>"member"</code> Reads and writes own data</li>`,
+		`<li><code>"guest"</code> Reads public data only</li>`,
+		`<li><code>"active"</code></li>`,
+		`<li><code>"blocked"</code></li>`,
+	} {
+		if !strings.Contains(string(b), v) {
+			t.Fatalf("missing enum value %v:\n%s", v, b)
+		}
+	}
+
+	raw := strings.Replace(fixture.TestSchemaEnumDescriptions, `"Reads public data only"`, "", 1)
+	raw = strings.Replace(raw, `"Reads and writes own data",`, `"Reads and writes own data"`, 1)
+	_, err = Parse([]byte(raw))
+	if err == nil {
+		t.Fatal("missing enum description should not parse")
+	}
+	expected := `jsonmsg: x-enum-descriptions of #/definitions/user/properties/role must be an array of a string per enum value`
+	if err.Error() != expected {
+		t.Fatalf("error was %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaDefaults))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spc.Defaults) != 4 || string(spc.Defaults["#/definitions/query/properties/order"]) != `"relevance"` {
+		t.Fatalf("defaults were %v", spc.Defaults)
+	}
+
+	inst, err := spc.Messages["search"].NewInstance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"data":{"exact":true,"limit":10,"order":"relevance","page":{"size":2.5},"term":"string"},"msg":"search"}`
+	if string(b) != expected {
+		t.Fatalf("instance was %s, expected %s", b, expected)
+	}
+}
+
+// returns the html of a message section up to the next message
+func htmlMessageSection(t *testing.T, html string, msg string) string {
+	start := strings.Index(html, `<a name="message-`+msg+`">`)
+	if start == -1 {
+		t.Fatalf("missing %v section", msg)
+	}
+	section := html[start+1:]
+	if end := strings.Index(section, `<a name="message-`); end != -1 {
+		section = section[:end]
+	}
+	if end := strings.Index(section, `<a name="data">`); end != -1 {
+		section = section[:end]
+	}
+	return section
+}
+
+func TestLint(t *testing.T) {
+	table := []struct {
+		Name   string
+		Schema string
+		Errors []string
+	}{
+		{
+			"clean spec",
+			fixture.TestSchemaSimpleLogin,
+			nil,
+		},
+		{
+			"reserved name",
+			fixture.TestSchemaReservedFetchSpec,
+			[]string{`jsonmsg: message "fetchSpec" uses a reserved name`},
+		},
+		{
+			"shadowed name",
+			strings.Replace(fixture.TestSchemaSimpleLogin, `"logout": {`, `"login-WithCredentials": {
+			"group": "other"
+		},
+		"logout": {`, 1),
+			[]string{`jsonmsg: message "loginWithCredentials" (group "login") shadows message "login-WithCredentials" (group "other"), both are named LoginWithCredentials`},
+		},
+	}
+	for _, ts := range table {
+		spc, err := Parse([]byte(ts.Schema))
+		if err != nil {
+			t.Fatal(ts.Name, err)
+		}
+		errs := spc.Lint()
+		if len(errs) != len(ts.Errors) {
+			t.Fatalf("%v: lint returned %v, expected %v", ts.Name, errs, ts.Errors)
+		}
+		for i, err := range errs {
+			if err.Error() != ts.Errors[i] {
+				t.Fatalf("%v: lint error was %q, expected %q", ts.Name, err.Error(), ts.Errors[i])
+			}
+		}
+	}
+}
+
+func TestEnums(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaEnumValues))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enums, err := spc.Enums()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tbl := map[string]string{
+		"#/definitions/user/properties/status":      "[active blocked]",
+		"#/definitions/user/properties/roles/items": "[admin member]",
+	}
+	if len(enums) != len(tbl) {
+		t.Fatalf("enums were %v", enums)
+	}
+	for p, e := range tbl {
+		if fmt.Sprint(enums[p]) != e {
+			t.Fatalf("enum of %v was %v, expected %v", p, enums[p], e)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaFormats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	formats, err := spc.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(formats) != 5 || formats["#/definitions/signup/properties/email"] != "email" || formats["#/definitions/signup/properties/ips/items"] != "ipv4" {
+		t.Fatalf("formats were %v", formats)
+	}
+
+	raw := strings.Replace(fixture.TestSchemaFormats, `"format": "uuid"`, `"format": 4`, 1)
+	spc, err = Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = spc.Formats()
+	expected := "jsonmsg: format of #/definitions/signup/properties/id must be a string"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("error was %v, expected %v", err, expected)
+	}
+}
+
+func TestConditionals(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaConditionalRequired))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conds, err := spc.Conditionals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := conds["#/definitions/payment"]
+	if len(conds) != 1 || !ok {
+		t.Fatalf("conditionals were %v", conds)
+	}
+	if fmt.Sprint(c.If) != "map[type:[card]]" || len(c.IfRequired) != 0 {
+		t.Fatalf("if was %v required %v", c.If, c.IfRequired)
+	}
+	if fmt.Sprint(c.Then) != "[cardNumber]" || fmt.Sprint(c.Else) != "[iban]" {
+		t.Fatalf("then was %v, else %v", c.Then, c.Else)
+	}
+
+	raw := strings.Replace(fixture.TestSchemaConditionalRequired, `"const": "card"`, `"minLength": 4`, 1)
+	spc, err = Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = spc.Conditionals()
+	expected := "jsonmsg: if of #/definitions/payment must only constrain type by const or enum"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("error was %v, expected %v", err, expected)
+	}
+}
+
+func TestTuples(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaTuples))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tuple, ok := spc.Tuples["#/definitions/coordinates"]
+	if len(spc.Tuples) != 1 || !ok || fmt.Sprint(tuple.Items) != "[item0 item1]" || tuple.AdditionalItems {
+		t.Fatalf("tuples were %v", spc.Tuples)
+	}
+	if d := spc.Definitions["#/definitions/coordinates/properties/item1"]; d == nil || d.Type != "number" {
+		t.Fatalf("item1 was %v", d)
+	}
+
+	// the served spec keeps the tuple
+	if !strings.Contains(string(spc.Raw), `"additionalItems": false`) {
+		t.Fatal("raw spec should keep the tuple")
+	}
+
+	raw := strings.Replace(fixture.TestSchemaTuples, `"$ref": "#/definitions/coordinates"`, `"type": "array", "items": [{"type": "number"}]`, 1)
+	_, err = Parse([]byte(raw))
+	expected := "jsonmsg: tuple items of #/definitions/place/properties/location are only supported for definitions, reference a tuple definition instead"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("error was %v, expected %v", err, expected)
+	}
+}
+
+func TestPropertyCounts(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaPropertyCounts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mins, err := spc.MinProperties()
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxs, err := spc.MaxProperties()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := "#/definitions/item/properties/labels"
+	if len(mins) != 1 || mins[p] != 1 || len(maxs) != 1 || maxs[p] != 2 {
+		t.Fatalf("bounds were %v and %v", mins, maxs)
+	}
+
+	raw := strings.Replace(fixture.TestSchemaPropertyCounts, `"maxProperties": 2`, `"maxProperties": 1.5`, 1)
+	spc, err = Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = spc.MaxProperties()
+	expected := "jsonmsg: maxProperties of " + p + " must be a non-negative integer"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("error was %v, expected %v", err, expected)
+	}
+}
+
 func TestJSONSpec(t *testing.T) {
 	spc, err := Parse([]byte(fixture.TestSchemaSimpleLogin))
 	if err != nil {
@@ -82,3 +1190,302 @@ func TestJSONSpec(t *testing.T) {
 		t.Fatal("different urls")
 	}
 }
+
+func TestJSONSpecStripComments(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaComments))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// comments are kept by default
+	out, err := spc.JSONSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(out), `"$comment"`); n != 5 {
+		t.Fatalf("spec had %v comments, expected 5:\n%s", n, out)
+	}
+
+	out, err = spc.JSONSpecWithOptions(JSONSpecOptions{StripComments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "internal:") {
+		t.Fatalf("stripped spec contains comments:\n%s", out)
+	}
+	if !strings.Contains(string(out), "example data is kept") {
+		t.Fatalf("stripped spec lost example data:\n%s", out)
+	}
+
+	// the stripped spec parses to the same messages
+	spc2, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := spc2.Messages["findUser"]; m == nil || m.InSchema.Name != "UserQuery" {
+		t.Fatalf("findUser was %v", m)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := spc.JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema map[string]interface{}
+	err = json.Unmarshal(out, &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := schema["$defs"].(map[string]interface{})["credentials"]; !ok {
+		t.Fatalf("credentials missing in $defs: %s", out)
+	}
+	if strings.Contains(string(out), "#/definitions/") {
+		t.Fatalf("schema still contains definitions pointers: %s", out)
+	}
+
+	table := []struct {
+		Msg   string
+		Valid bool
+	}{
+		{`{"msg": "loginWithCredentials", "data": {"name": "john", "password": "snow"}}`, true},
+		{`{"msg": "loginWithCredentials", "data": {"name": 5}}`, false},
+		{`{"msg": "loginWithCredentials"}`, false},
+		{`{"msg": "unknown", "data": {}}`, false},
+		{`{"msg": "logout", "data": {"id": "foo"}, "extra": true}`, false},
+	}
+	for _, ts := range table {
+		var inst interface{}
+		err = json.Unmarshal([]byte(ts.Msg), &inst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if validateInstance(schema, schema, inst) != ts.Valid {
+			t.Fatalf("%s: valid should be %v", ts.Msg, ts.Valid)
+		}
+	}
+}
+
+// validates an instance against the JSON Schema subset emitted by Spec.JSONSchema
+func validateInstance(root map[string]interface{}, s map[string]interface{}, v interface{}) bool {
+	if ref, ok := s["$ref"].(string); ok {
+		def, ok := root["$defs"].(map[string]interface{})[strings.TrimPrefix(ref, "#/$defs/")]
+		if !ok {
+			return false
+		}
+		return validateInstance(root, def.(map[string]interface{}), v)
+	}
+
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		n := 0
+		for _, o := range oneOf {
+			if validateInstance(root, o.(map[string]interface{}), v) {
+				n++
+			}
+		}
+		return n == 1
+	}
+
+	if c, ok := s["const"]; ok && c != v {
+		return false
+	}
+
+	switch s["type"] {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "object":
+		o, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		if req, ok := s["required"].([]interface{}); ok {
+			for _, r := range req {
+				if _, ok := o[r.(string)]; !ok {
+					return false
+				}
+			}
+		}
+		for k, e := range o {
+			p, ok := props[k]
+			if !ok {
+				if s["additionalProperties"] == false {
+					return false
+				}
+				continue
+			}
+			if !validateInstance(root, p.(map[string]interface{}), e) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestAsyncAPI(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaSimpleLoginHTTPandWebsocket))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := spc.AsyncAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		AsyncAPI string
+		Servers  map[string]struct {
+			URL      string
+			Protocol string
+		}
+		Channels map[string]struct {
+			Publish struct {
+				OperationID string
+				Message     map[string]interface{}
+			}
+			Subscribe struct {
+				Message struct {
+					OneOf []map[string]string
+				}
+			}
+		}
+		Components struct {
+			Schemas  map[string]interface{}
+			Messages map[string]interface{}
+		}
+	}
+	err = json.Unmarshal(out, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.AsyncAPI == "" {
+		t.Fatalf("missing asyncapi version: %s", out)
+	}
+	if doc.Servers["websocket"].URL != "wss://api.specc.io/v1/websocket" || doc.Servers["websocket"].Protocol != "wss" {
+		t.Fatalf("invalid websocket server: %v", doc.Servers)
+	}
+
+	ch, ok := doc.Channels["loginWithCredentials"]
+	if !ok {
+		t.Fatalf("missing channel loginWithCredentials: %s", out)
+	}
+	if ch.Publish.OperationID != "loginWithCredentials" {
+		t.Fatalf("invalid operation id: %v", ch.Publish.OperationID)
+	}
+	if len(ch.Subscribe.Message.OneOf) != 2 {
+		t.Fatalf("invalid number of out messages: %v", ch.Subscribe.Message.OneOf)
+	}
+	for _, ref := range ch.Subscribe.Message.OneOf {
+		if _, ok := doc.Components.Messages[strings.TrimPrefix(ref["$ref"], "#/components/messages/")]; !ok {
+			t.Fatalf("unresolvable message reference: %v", ref)
+		}
+	}
+
+	if _, ok := doc.Components.Schemas["credentials"]; !ok {
+		t.Fatalf("missing schema component credentials: %s", out)
+	}
+	if strings.Contains(string(out), "#/definitions/") {
+		t.Fatalf("document still contains definitions pointers: %s", out)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	spc, err := Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := spc.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		OpenAPI string
+		Servers []struct {
+			URL string
+		}
+		Paths map[string]struct {
+			Post struct {
+				OperationID string
+				Tags        []string
+				Responses   map[string]struct {
+					Content map[string]struct {
+						Schema struct {
+							OneOf []interface{}
+						}
+					}
+				}
+			}
+		}
+		Tags []struct {
+			Name string
+		}
+		Components struct {
+			Schemas map[string]interface{}
+		}
+	}
+	err = json.Unmarshal(out, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.OpenAPI == "" {
+		t.Fatalf("missing openapi version: %s", out)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != "http://api.specc.io" {
+		t.Fatalf("invalid servers: %v", doc.Servers)
+	}
+
+	p, ok := doc.Paths["/v1/http#loginWithCredentials"]
+	if !ok {
+		t.Fatalf("missing operation of loginWithCredentials: %s", out)
+	}
+	if p.Post.OperationID != "LoginWithCredentials" {
+		t.Fatalf("invalid operation id: %v", p.Post.OperationID)
+	}
+	if len(p.Post.Tags) != 1 || p.Post.Tags[0] != "login" {
+		t.Fatalf("invalid tags: %v", p.Post.Tags)
+	}
+	if outs := p.Post.Responses["200"].Content["application/json"].Schema.OneOf; len(outs) != 2 {
+		t.Fatalf("invalid number of out messages: %v", outs)
+	}
+	if _, ok := p.Post.Responses["422"]; !ok {
+		t.Fatalf("missing response 422: %v", p.Post.Responses)
+	}
+	if len(doc.Tags) != 2 || doc.Tags[0].Name != "login" || doc.Tags[1].Name != "logout" {
+		t.Fatalf("invalid tags: %v", doc.Tags)
+	}
+
+	if _, ok := doc.Components.Schemas["credentials"]; !ok {
+		t.Fatalf("missing schema component credentials: %s", out)
+	}
+	if strings.Contains(string(out), "#/definitions/") {
+		t.Fatalf("document still contains definitions pointers: %s", out)
+	}
+
+	// operations need an http endpoint
+	spc, err = Parse([]byte(strings.Replace(fixture.TestSchemaSimpleLogin, `"http": "http://api.specc.io/v1"`, `"websocket": "ws://api.specc.io/v1"`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = spc.OpenAPI()
+	if err == nil || err.Error() != "jsonmsg: OpenAPI requires an http endpoint" {
+		t.Fatalf("error was %v", err)
+	}
+}
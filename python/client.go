@@ -0,0 +1,356 @@
+package python
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/tfkhsr/jsonmsg"
+	"github.com/tfkhsr/jsonschema"
+)
+
+func init() {
+	jsonmsg.RegisterGenerator("py-client", func(s *jsonmsg.Spec, pack string) ([]byte, error) {
+		return ClientSrc(s)
+	})
+}
+
+// Generates python src for a client from a jsonmsg.Spec as a complete module
+func ClientSrc(s *jsonmsg.Spec) ([]byte, error) {
+	w := &bytes.Buffer{}
+	fmt.Fprintf(w, "# %s\n%s", jsonmsg.GeneratedStamp(s), clientHeader)
+
+	for _, k := range definitionKeys(s) {
+		d := s.Definitions[k]
+		if d.Type != "object" {
+			continue
+		}
+		err := writeDataclass(w, s, k, d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := writeClient(w, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.Bytes(), nil
+}
+
+const clientHeader = `
+from dataclasses import dataclass
+from typing import Any, Dict, List, Optional, Tuple, Union
+
+import requests
+
+
+def _compact(d):
+    return {k: v for k, v in d.items() if v is not None}
+
+
+def _to_json(v):
+    if v is None:
+        return None
+    if isinstance(v, list):
+        return [_to_json(e) for e in v]
+    if hasattr(v, "to_json"):
+        return v.to_json()
+    return v
+`
+
+// writes a dataclass with json conversion for an object definition at ptr
+func writeDataclass(w *bytes.Buffer, s *jsonmsg.Spec, ptr string, d *jsonschema.Schema) error {
+	keys := propertyKeys(d)
+
+	// required nullable properties encode None as null
+	nulls := make(map[string]bool)
+	for _, p := range d.Required {
+		nulls[p] = s.Nullable[ptr+"/properties/"+p]
+	}
+
+	fmt.Fprintf(w, "\n\n@dataclass\n")
+	fmt.Fprintf(w, "class %s:\n", d.Name)
+	if d.Description != "" {
+		fmt.Fprintf(w, "    %q\n\n", d.Description)
+	}
+	if len(keys) == 0 {
+		fmt.Fprintf(w, "    pass\n")
+	}
+	for _, p := range keys {
+		typ, err := pyType(s, d.Properties[p])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "    %s: Optional[%s] = None\n", pyName(p), typ)
+	}
+
+	fmt.Fprintf(w, "\n    def to_json(self) -> Dict[str, Any]:\n")
+	var required []string
+	for _, p := range d.Required {
+		if !nulls[p] {
+			required = append(required, p)
+		}
+	}
+	if len(required) == len(d.Required) {
+		fmt.Fprintf(w, "        return _compact({\n")
+	} else {
+		fmt.Fprintf(w, "        d = _compact({\n")
+	}
+	for _, p := range keys {
+		if !nulls[p] {
+			fmt.Fprintf(w, "            %q: _to_json(self.%s),\n", p, pyName(p))
+		}
+	}
+	fmt.Fprintf(w, "        })\n")
+	if len(required) != len(d.Required) {
+		for _, p := range keys {
+			if nulls[p] {
+				fmt.Fprintf(w, "        d[%q] = _to_json(self.%s)\n", p, pyName(p))
+			}
+		}
+		fmt.Fprintf(w, "        return d\n")
+	}
+
+	fmt.Fprintf(w, "\n    def validate(self) -> None:\n")
+	if len(required) == 0 {
+		fmt.Fprintf(w, "        pass\n")
+	}
+	for _, p := range required {
+		fmt.Fprintf(w, "        if self.%s is None:\n", pyName(p))
+		fmt.Fprintf(w, "            raise ValueError(%q)\n", d.Name+": "+p+" is required")
+	}
+
+	fmt.Fprintf(w, "\n    @classmethod\n")
+	fmt.Fprintf(w, "    def from_json(cls, d: Dict[str, Any]) -> \"%s\":\n", d.Name)
+	fmt.Fprintf(w, "        return cls(\n")
+	for _, p := range keys {
+		conv, err := pyFromJSON(s, d.Properties[p], fmt.Sprintf("d.get(%q)", p))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "            %s=%s,\n", pyName(p), conv)
+	}
+	fmt.Fprintf(w, "        )\n")
+
+	return nil
+}
+
+// writes the Client class with a method per message
+func writeClient(w *bytes.Buffer, s *jsonmsg.Spec) error {
+	endpoint := ""
+	if e, ok := s.Endpoints["http"]; ok {
+		endpoint = e.String()
+	}
+
+	fmt.Fprintf(w, "\n\nclass Client:\n")
+	fmt.Fprintf(w, "    def __init__(self, endpoint: str = %q, session: Optional[requests.Session] = None):\n", endpoint)
+	fmt.Fprintf(w, "        self.endpoint = endpoint\n")
+	fmt.Fprintf(w, "        self.session = session or requests.Session()\n")
+
+	fmt.Fprintf(w, "\n    def send(self, msg: str, data: Any = None) -> Tuple[Optional[str], Any]:\n")
+	fmt.Fprintf(w, "        payload = {\"msg\": msg}\n")
+	fmt.Fprintf(w, "        if data is not None:\n")
+	fmt.Fprintf(w, "            payload[\"data\"] = _to_json(data)\n")
+	fmt.Fprintf(w, "        res = self.session.post(self.endpoint, json=payload)\n")
+	fmt.Fprintf(w, "        if not res.content:\n")
+	fmt.Fprintf(w, "            res.raise_for_status()\n")
+	fmt.Fprintf(w, "            return None, None\n")
+	fmt.Fprintf(w, "        body = res.json()\n")
+	fmt.Fprintf(w, "        return body.get(\"msg\"), body.get(\"data\")\n")
+
+	var keys []string
+	for k, _ := range s.Messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		m := s.Messages[k]
+
+		fmt.Fprintln(w, "")
+		if m.InSchema != nil {
+			typ, err := pyType(s, m.InSchema)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "    def %s(self, data: %s) -> Tuple[Optional[str], Any]:\n", pyName(m.Msg), typ)
+		} else if len(m.InSchemas) > 0 {
+			var typs []string
+			for _, in := range m.InSchemas {
+				typ, err := pyType(s, in)
+				if err != nil {
+					return err
+				}
+				typs = append(typs, typ)
+			}
+			fmt.Fprintf(w, "    def %s(self, data: Union[%s]) -> Tuple[Optional[str], Any]:\n", pyName(m.Msg), strings.Join(typs, ", "))
+		} else {
+			fmt.Fprintf(w, "    def %s(self) -> Tuple[Optional[str], Any]:\n", pyName(m.Msg))
+		}
+		if doc := strings.TrimSpace(m.Title + "\n\n" + m.Description); doc != "" {
+			fmt.Fprintf(w, "        %q\n", doc)
+		}
+		if m.InSchema != nil && m.InSchema.Type == "object" && isDefinition(s, m.InSchema) {
+			fmt.Fprintf(w, "        data.validate()\n")
+		}
+		for _, in := range m.InSchemas {
+			if in.Type == "object" && isDefinition(s, in) {
+				fmt.Fprintf(w, "        if isinstance(data, %s):\n", in.Name)
+				fmt.Fprintf(w, "            data.validate()\n")
+			}
+		}
+		if m.InSchema != nil || len(m.InSchemas) > 0 {
+			fmt.Fprintf(w, "        msg, data = self.send(%q, data)\n", m.Msg)
+		} else {
+			fmt.Fprintf(w, "        msg, data = self.send(%q)\n", m.Msg)
+		}
+		for _, o := range m.OutSchemas {
+			if o.Type != "object" {
+				continue
+			}
+			fmt.Fprintf(w, "        if msg == %q and data is not None:\n", o.JSONName)
+			fmt.Fprintf(w, "            return msg, %s.from_json(data)\n", o.Name)
+		}
+		fmt.Fprintf(w, "        return msg, data\n")
+	}
+
+	return nil
+}
+
+// returns the python type hint of a schema
+func pyType(s *jsonmsg.Spec, p *jsonschema.Schema) (string, error) {
+	switch p.Type {
+	case "string":
+		return "str", nil
+	case "number":
+		return "float", nil
+	case "integer":
+		return "int", nil
+	case "boolean":
+		return "bool", nil
+	case "null":
+		return "None", nil
+	case "array":
+		if p.Items == nil {
+			return "List[Any]", nil
+		}
+		t, err := pyType(s, p.Items)
+		if err != nil {
+			return "", err
+		}
+		return "List[" + t + "]", nil
+	case "object":
+		if isDefinition(s, p) {
+			return fmt.Sprintf("%q", p.Name), nil
+		}
+		return "Dict[str, Any]", nil
+	case "ref":
+		ref, ok := s.Definitions[p.Ref]
+		if !ok {
+			return "", fmt.Errorf("python: %v does not exist in index", p.Ref)
+		}
+		return pyType(s, ref)
+	}
+	return "Any", nil
+}
+
+// returns a python expression converting the decoded json expression v into the type of a schema
+func pyFromJSON(s *jsonmsg.Spec, p *jsonschema.Schema, v string) (string, error) {
+	switch p.Type {
+	case "array":
+		if p.Items == nil {
+			return v, nil
+		}
+		conv, err := pyFromJSON(s, p.Items, "e")
+		if err != nil {
+			return "", err
+		}
+		if conv == "e" {
+			return v, nil
+		}
+		return fmt.Sprintf("[%s for e in %s] if %s is not None else None", conv, v, v), nil
+	case "object":
+		if isDefinition(s, p) {
+			return fmt.Sprintf("%s.from_json(%s) if %s is not None else None", p.Name, v, v), nil
+		}
+	case "ref":
+		ref, ok := s.Definitions[p.Ref]
+		if !ok {
+			return "", fmt.Errorf("python: %v does not exist in index", p.Ref)
+		}
+		return pyFromJSON(s, ref, v)
+	}
+	return v, nil
+}
+
+// Checks if a schema is a top-level definition, which is generated as dataclass
+func isDefinition(s *jsonmsg.Spec, p *jsonschema.Schema) bool {
+	for _, k := range definitionKeys(s) {
+		if s.Definitions[k] == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the sorted pointers of all top-level definitions
+func definitionKeys(s *jsonmsg.Spec) []string {
+	var keys []string
+	for k, _ := range s.Definitions {
+		n := strings.TrimPrefix(k, "#/definitions/")
+		if n != k && !strings.Contains(n, "/") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns the sorted property names of a schema
+func propertyKeys(d *jsonschema.Schema) []string {
+	var keys []string
+	for k, _ := range d.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// python keywords and builtins that must not be used as identifiers
+var pyReserved = []string{
+	"False", "None", "True", "and", "as", "assert", "async", "await", "break",
+	"class", "continue", "def", "del", "elif", "else", "except", "finally", "for",
+	"from", "global", "if", "import", "in", "is", "lambda", "nonlocal", "not",
+	"or", "pass", "raise", "return", "try", "while", "with", "yield",
+	"cls", "self", "send",
+}
+
+// creates a python friendly snake_case name from a string
+func pyName(n string) string {
+	n = regexp.MustCompile("[^a-zA-Z0-9_]").ReplaceAllString(n, "_")
+	w := &bytes.Buffer{}
+	for i, r := range n {
+		if unicode.IsUpper(r) {
+			if i > 0 && !unicode.IsUpper(rune(n[i-1])) && n[i-1] != '_' {
+				w.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		w.WriteRune(r)
+	}
+	name := w.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+	for _, r := range pyReserved {
+		if name == r {
+			return name + "_"
+		}
+	}
+	return name
+}
@@ -0,0 +1,105 @@
+package python
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tfkhsr/jsonmsg"
+	"github.com/tfkhsr/jsonmsg/fixture"
+)
+
+func TestClientSrc(t *testing.T) {
+	spc, err := jsonmsg.Parse([]byte(fixture.TestSchemaMessageLocalSchemas))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := ClientSrc(spc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tbl := []string{
+		"class User:",
+		"class FindUserIn:",
+		"class Client:",
+		"def find_user(self, data: \"FindUserIn\")",
+		"def delete_user(self, data: \"FindUserIn\")",
+		"self.send(\"findUser\", data)",
+		"return msg, User.from_json(data)",
+		"endpoint: str = \"http://api.specc.io/v1/http\"",
+	}
+	for _, s := range tbl {
+		if !strings.Contains(string(src), s) {
+			t.Fatalf("src is missing '%s':\n%s", s, src)
+		}
+	}
+
+	stamp := "# " + jsonmsg.GeneratedStamp(spc) + "\n"
+	if !strings.HasPrefix(string(src), stamp) {
+		t.Fatalf("src should start with %q:\n%s", stamp, src)
+	}
+}
+
+func TestClientSrcValidation(t *testing.T) {
+	spc, err := jsonmsg.Parse([]byte(fixture.TestSchemaRequiredCredentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := ClientSrc(spc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tbl := []string{
+		"        if self.name is None:\n            raise ValueError(\"Credentials: name is required\")\n",
+		"        if self.password is None:\n            raise ValueError(\"Credentials: password is required\")\n",
+		"        data.validate()\n        msg, data = self.send(\"loginWithCredentials\", data)\n",
+	}
+	for _, s := range tbl {
+		if !strings.Contains(string(src), s) {
+			t.Fatalf("src is missing '%s':\n%s", s, src)
+		}
+	}
+}
+
+func TestClientSrcNullable(t *testing.T) {
+	spc, err := jsonmsg.Parse([]byte(fixture.TestSchemaNullableTypes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := ClientSrc(spc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a required nullable property is sent as null but not validated
+	tbl := []string{
+		"        d = _compact({\n            \"name\": _to_json(self.name),\n            \"nickname\": _to_json(self.nickname),\n        })\n",
+		"        d[\"email\"] = _to_json(self.email)\n        return d\n",
+		"        if self.name is None:\n            raise ValueError(\"Profile: name is required\")\n\n",
+	}
+	for _, s := range tbl {
+		if !strings.Contains(string(src), s) {
+			t.Fatalf("src is missing '%s':\n%s", s, src)
+		}
+	}
+}
+
+func TestPyName(t *testing.T) {
+	tbl := map[string]string{
+		"findUser":             "find_user",
+		"loginWithCredentials": "login_with_credentials",
+		"id":                   "id",
+		"first-name":           "first_name",
+		"class":                "class_",
+		"2fa":                  "_2fa",
+	}
+	for in, out := range tbl {
+		if pyName(in) != out {
+			t.Fatalf("name of %s should be %s but is %s", in, out, pyName(in))
+		}
+	}
+}
@@ -0,0 +1,33 @@
+/*
+Package python generates python sources implementing a jsonmsg.Spec.
+
+Client
+
+The generated sources for a client will include a dataclass for each definition and a Client class with a method per message.
+Each method posts the message to the http endpoint using the requests library and returns the response message name and decoded data:
+
+	spc, err := jsonmsg.Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+
+	// generate client source
+	src, err := ClientSrc(spc)
+	if err != nil {
+		panic(err)
+	}
+
+	// write to file
+	err = ioutil.WriteFile("api.py", src, 0644)
+	if err != nil {
+		panic(err)
+	}
+
+The api.py file can then be used like:
+
+	client = Client()
+	msg, user = client.find_user(UserQuery(id="visurgif"))
+	if msg == "user":
+		print(user.name)
+*/
+package python
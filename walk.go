@@ -0,0 +1,53 @@
+package jsonmsg
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tfkhsr/jsonschema"
+)
+
+// Visits every schema of the spec once with its pointer, including nested properties and items.
+// Definitions are visited in order of their pointers, each followed depth-first by its properties
+// in order of their names and its items.
+func (s *Spec) Walk(fn func(pointer string, s *jsonschema.Schema)) {
+	var keys []string
+	for k, _ := range s.Definitions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	visited := make(map[string]bool)
+	for _, k := range keys {
+		n := strings.TrimPrefix(k, "#/definitions/")
+		if n != k && !strings.Contains(n, "/") {
+			walkSchema(k, s.Definitions[k], visited, fn)
+		}
+	}
+
+	// schemas of the index not reachable from a definition
+	for _, k := range keys {
+		if !visited[k] {
+			walkSchema(k, s.Definitions[k], visited, fn)
+		}
+	}
+}
+
+// visits a schema and all schemas it contains, which have not been visited yet
+func walkSchema(ptr string, sch *jsonschema.Schema, visited map[string]bool, fn func(string, *jsonschema.Schema)) {
+	if sch == nil || visited[ptr] {
+		return
+	}
+	visited[ptr] = true
+	fn(ptr, sch)
+
+	var props []string
+	for p, _ := range sch.Properties {
+		props = append(props, p)
+	}
+	sort.Strings(props)
+	for _, p := range props {
+		walkSchema(ptr+"/properties/"+p, sch.Properties[p], visited, fn)
+	}
+	walkSchema(ptr+"/items", sch.Items, visited, fn)
+}
@@ -0,0 +1,40 @@
+package jsonmsg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tfkhsr/jsonmsg/fixture"
+	"github.com/tfkhsr/jsonschema"
+)
+
+func TestSpecWalk(t *testing.T) {
+	spec, err := Parse([]byte(fixture.TestSchemaSimpleLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var visited []string
+	spec.Walk(func(ptr string, s *jsonschema.Schema) {
+		if s != spec.Definitions[ptr] {
+			t.Fatalf("schema of %v is not the indexed schema", ptr)
+		}
+		visited = append(visited, ptr)
+	})
+
+	// 4 definitions with 5 properties
+	expected := []string{
+		"#/definitions/credentials",
+		"#/definitions/credentials/properties/name",
+		"#/definitions/credentials/properties/password",
+		"#/definitions/error",
+		"#/definitions/error/properties/error",
+		"#/definitions/message",
+		"#/definitions/message/properties/message",
+		"#/definitions/session",
+		"#/definitions/session/properties/id",
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("visited %v, expected %v", visited, expected)
+	}
+}